@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/apikey"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+)
+
+// InitAPIKeys returns the API key manager for the configured store, or nil when API keys are not configured.
+func InitAPIKeys(ctx context.Context, appConfig appconfig.AppConfig) (*apikey.Manager, error) {
+	conf := appConfig.APIKeyConfig
+	if conf == nil {
+		return nil, nil
+	}
+
+	var store apikey.Store = &apikey.FileStore{Path: conf.File}
+	if conf.RedisConnectionString != "" {
+		var err error
+		store, err = apikey.NewRedisStore(conf.RedisConnectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.SQLConnectionString != "" {
+		var err error
+		store, err = apikey.NewSQLStore(ctx, conf.SQLConnectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+	health.Register(store)
+
+	return &apikey.Manager{Store: store}, nil
+}
+
+type IssueAPIKeyRequest struct {
+	Account     string     `json:"account"`
+	DataStreams []string   `json:"data_streams"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// IssueAPIKeyResponse is the only time the secret key is returned.
+type IssueAPIKeyResponse struct {
+	apikey.Key
+	Secret string `json:"key"`
+}
+
+type ListAPIKeysResponse struct {
+	Keys []apikey.Key `json:"keys"`
+}
+
+// APIKeysHandler is the admin API for issuing, listing, rotating and revoking API keys.
+type APIKeysHandler struct {
+	Keys                *apikey.Manager
+	RotationGracePeriod time.Duration
+}
+
+func (h *APIKeysHandler) Register(mux *http.ServeMux, wrap func(http.Handler) http.Handler) {
+	mux.Handle("GET /admin/api-keys", wrap(http.HandlerFunc(h.list)))
+	mux.Handle("POST /admin/api-keys", wrap(http.HandlerFunc(h.issue)))
+	mux.Handle("POST /admin/api-keys/{KeyID}/rotate", wrap(http.HandlerFunc(h.rotate)))
+	mux.Handle("DELETE /admin/api-keys/{KeyID}", wrap(http.HandlerFunc(h.revoke)))
+}
+
+func (h *APIKeysHandler) list(rw http.ResponseWriter, r *http.Request) {
+	keys, err := h.Keys.List(r.Context(), r.URL.Query().Get("account"))
+	if err != nil {
+		logger.Error("error listing api keys", "error", err)
+		http.Error(rw, "error listing api keys", http.StatusInternalServerError)
+		return
+	}
+	resp := ListAPIKeysResponse{Keys: make([]apikey.Key, len(keys))}
+	for i, k := range keys {
+		resp.Keys[i] = k.Redacted()
+	}
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+func (h *APIKeysHandler) issue(rw http.ResponseWriter, r *http.Request) {
+	var req IssueAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	secret, key, err := h.Keys.Issue(r.Context(), req.Account, req.DataStreams, req.ExpiresAt)
+	if err != nil {
+		writeAPIKeyError(rw, err)
+		return
+	}
+	auditAPIKey(r, "issued api key", key)
+	writeJSON(rw, http.StatusCreated, IssueAPIKeyResponse{Key: key.Redacted(), Secret: secret})
+}
+
+func (h *APIKeysHandler) rotate(rw http.ResponseWriter, r *http.Request) {
+	var req IssueAPIKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(rw, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	secret, key, err := h.Keys.Rotate(r.Context(), r.PathValue("KeyID"), h.RotationGracePeriod, req.ExpiresAt)
+	if err != nil {
+		writeAPIKeyError(rw, err)
+		return
+	}
+	auditAPIKey(r, "rotated api key", key)
+	writeJSON(rw, http.StatusCreated, IssueAPIKeyResponse{Key: key.Redacted(), Secret: secret})
+}
+
+func (h *APIKeysHandler) revoke(rw http.ResponseWriter, r *http.Request) {
+	key, err := h.Keys.Revoke(r.Context(), r.PathValue("KeyID"))
+	if err != nil {
+		writeAPIKeyError(rw, err)
+		return
+	}
+	auditAPIKey(r, "revoked api key", key)
+	writeJSON(rw, http.StatusOK, key.Redacted())
+}
+
+func auditAPIKey(r *http.Request, msg string, key *apikey.Key) {
+	claims, _ := oauth.FromContext(r.Context())
+	logger.Info(msg, "key_id", key.ID, "account", key.Account, "admin", claims.Subject)
+}
+
+func writeAPIKeyError(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apikey.ErrNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, apikey.ErrTooManyKeys):
+		http.Error(rw, err.Error(), http.StatusConflict)
+	case errors.Is(err, apikey.ErrNoAccountName), errors.Is(err, apikey.ErrNoDataStreams), errors.Is(err, apikey.ErrInvalidKey):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		logger.Error("error managing api keys", "error", err)
+		http.Error(rw, "error managing api keys", http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
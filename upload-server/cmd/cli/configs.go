@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+)
+
+type ListConfigsResponse struct {
+	Configs []string `json:"configs"`
+}
+
+// ConfigsHandler is the admin API for inspecting the manifest configs and reloading them after they change.
+type ConfigsHandler struct {
+	Configs *metadata.ConfigCache
+}
+
+func (h *ConfigsHandler) Register(mux *http.ServeMux, wrap func(http.Handler) http.Handler) {
+	mux.Handle("GET /admin/configs", wrap(http.HandlerFunc(h.list)))
+	mux.Handle("GET /admin/configs/{Config}", wrap(http.HandlerFunc(h.get)))
+	mux.Handle("POST /admin/configs/{Config}/reload", wrap(http.HandlerFunc(h.reload)))
+}
+
+func (h *ConfigsHandler) list(rw http.ResponseWriter, r *http.Request) {
+	configs, err := h.Configs.ListConfigs(r.Context())
+	if err != nil {
+		writeConfigError(rw, err)
+		return
+	}
+	if configs == nil {
+		configs = []string{}
+	}
+	writeJSON(rw, http.StatusOK, ListConfigsResponse{Configs: configs})
+}
+
+func (h *ConfigsHandler) get(rw http.ResponseWriter, r *http.Request) {
+	conf, err := h.Configs.GetConfig(r.Context(), r.PathValue("Config"))
+	if err != nil {
+		writeConfigError(rw, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, conf)
+}
+
+func (h *ConfigsHandler) reload(rw http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("Config")
+	conf, err := h.Configs.ReloadConfig(r.Context(), key)
+	if err != nil {
+		writeConfigError(rw, err)
+		return
+	}
+	claims, _ := oauth.FromContext(r.Context())
+	logger.Info("reloaded manifest config", "config", key, "admin", claims.Subject)
+	writeJSON(rw, http.StatusOK, conf)
+}
+
+func writeConfigError(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, validation.ErrNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, metadata.ErrInvalidConfig):
+		http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, metadata.ErrListingUnsupported):
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+	default:
+		logger.Error("error loading manifest config", "error", err)
+		http.Error(rw, "error loading manifest config", http.StatusInternalServerError)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storegcs"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/stores3"
+	"github.com/tus/tusd/v2/pkg/gcsstore"
 	"github.com/tus/tusd/v2/pkg/s3store"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
@@ -50,10 +52,18 @@ func GetDataStore(ctx context.Context, appConfig appconfig.AppConfig) (handlertu
 			return nil, nil, err
 		} // azService
 
+		containerClient, err := storeaz.NewContainerClient(appConfig.AzureConnection.Credentials(), appConfig.AzureUploadContainer)
+		if err != nil {
+			return nil, nil, err
+		}
+
 		store := azurestore.New(azService)
 		store.ObjectPrefix = appConfig.TusUploadPrefix
 		store.Container = appConfig.AzureUploadContainer
-		return store, hc, nil
+		return &storeaz.AzureStore{
+			Store:     store,
+			Container: containerClient,
+		}, hc, nil
 	} // .if
 	if appConfig.S3Connection != nil {
 		client, err := stores3.NewWithEndpoint(ctx, appConfig.S3Connection.Endpoint)
@@ -72,6 +82,23 @@ func GetDataStore(ctx context.Context, appConfig appconfig.AppConfig) (handlertu
 			Store: store,
 		}, hc, nil
 	}
+	if appConfig.GCSConnection != nil {
+		client, err := storegcs.NewClient(ctx, appConfig.GCSConnection.Endpoint, appConfig.GCSConnection.CredentialsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		hc := &storegcs.GCSHealthCheck{
+			Client:     client,
+			BucketName: appConfig.GCSConnection.BucketName,
+		}
+		store := gcsstore.New(appConfig.GCSConnection.BucketName, storegcs.NewService(client))
+		store.ObjectPrefix = appConfig.TusUploadPrefix
+
+		logger.Info("using GCS bucket", "bucket", appConfig.GCSConnection.BucketName)
+		return &storegcs.GCSStore{
+			Store: store,
+		}, hc, nil
+	}
 
 	// Create a new FileStore instance which is responsible for
 	// storing the uploaded file on disk in the specified directory.
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+)
+
+type DataStreamsResponse struct {
+	DataStreams []metadata.DataStreamConfig `json:"data_streams"`
+}
+
+// DataStreamsHandler lists the data streams and routes the sender can upload to, along with their manifest fields.
+type DataStreamsHandler struct {
+	Configs *metadata.ConfigCache
+}
+
+func (h *DataStreamsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	streams, err := h.Configs.ListAuthorizedDataStreams(r.Context())
+	if err != nil {
+		if errors.Is(err, metadata.ErrListingUnsupported) {
+			http.Error(rw, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		http.Error(rw, "error listing data streams", http.StatusInternalServerError)
+		return
+	}
+	if streams == nil {
+		streams = []metadata.DataStreamConfig{}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.Encode(&DataStreamsResponse{DataStreams: streams})
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storeaz"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storegcs"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/stores3"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -78,6 +79,17 @@ func RegisterAllSourcesAndDestinations(ctx context.Context, appConfig appconfig.
 			Prefix:     appConfig.TusUploadPrefix,
 		}
 	}
+	if appConfig.GCSConnection != nil {
+		gcsClient, err := storegcs.NewClient(ctx, appConfig.GCSConnection.Endpoint, appConfig.GCSConnection.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		src = &delivery.GCSSource{
+			FromClient: gcsClient,
+			BucketName: appConfig.GCSConnection.BucketName,
+			Prefix:     appConfig.TusUploadPrefix,
+		}
+	}
 	delivery.RegisterSource(delivery.UploadSrc, src)
 
 	if err := health.Register(src); err != nil {
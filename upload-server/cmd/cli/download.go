@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/scan"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+)
+
+// DownloadHandler streams finished uploads from the upload source, or hands out short-lived urls to download them
+// straight from storage.  Every access is audit logged, including the ones that are denied.  When Scanner is set the
+// upload is scanned first, so quarantined uploads are never handed out.
+type DownloadHandler struct {
+	Source    delivery.Source
+	URLExpiry time.Duration
+	Scanner   *scan.Stage
+}
+
+type DownloadURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (h *DownloadHandler) Register(mux *http.ServeMux, wrap func(http.Handler) http.Handler) {
+	mux.Handle("GET /uploads/{UploadID}/file", wrap(http.HandlerFunc(h.download)))
+	mux.Handle("GET /uploads/{UploadID}/file/url", wrap(http.HandlerFunc(h.presign)))
+}
+
+// manifest returns the manifest of the upload when the caller may view it, and writes the error response when
+// they may not.
+func (h *DownloadHandler) manifest(rw http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	id := r.PathValue("UploadID")
+	manifest, err := h.Source.GetMetadata(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, delivery.ErrSrcFileNotExist) {
+			http.Error(rw, "upload not found", http.StatusNotFound)
+			return nil, false
+		}
+		logger.Error("error getting upload manifest", "upload_id", id, "error", err)
+		http.Error(rw, "error getting upload manifest", http.StatusInternalServerError)
+		return nil, false
+	}
+	if claims, ok := oauth.FromContext(r.Context()); ok && !claims.CanView(manifest) {
+		auditDownload(r, "upload download denied")
+		http.Error(rw, ErrUploadForbidden.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return manifest, true
+}
+
+// scanned reports whether the upload was scanned clean, and writes the error response when it was not.
+func (h *DownloadHandler) scanned(rw http.ResponseWriter, r *http.Request, manifest map[string]string) bool {
+	if h.Scanner == nil {
+		return true
+	}
+	id := r.PathValue("UploadID")
+	clean, err := h.Scanner.Scan(r.Context(), h.Source, id, manifest)
+	if err != nil {
+		http.Error(rw, "unable to scan upload: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !clean {
+		auditDownload(r, "quarantined upload download denied")
+		http.Error(rw, "upload is quarantined", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+func (h *DownloadHandler) download(rw http.ResponseWriter, r *http.Request) {
+	manifest, ok := h.manifest(rw, r)
+	if !ok || !h.scanned(rw, r, manifest) {
+		return
+	}
+	id := r.PathValue("UploadID")
+	size, err := h.Source.GetSize(r.Context(), id)
+	if err != nil {
+		logger.Error("error getting upload size", "upload_id", id, "error", err)
+		http.Error(rw, "error getting upload size", http.StatusInternalServerError)
+		return
+	}
+
+	auditDownload(r, "upload downloaded")
+	// set so that ServeContent does not read the upload to sniff it
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	if filename := metadata.GetFilename(manifest); filename != "" {
+		rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+
+	src, ok := h.Source.(delivery.RangeSource)
+	if !ok {
+		// without range reads the whole upload is sent every time
+		body, err := h.Source.Reader(r.Context(), id)
+		if err != nil {
+			logger.Error("error reading upload", "upload_id", id, "error", err)
+			http.Error(rw, "error reading upload", http.StatusInternalServerError)
+			return
+		}
+		if c, ok := body.(io.Closer); ok {
+			defer c.Close()
+		}
+		rw.Header().Set("Accept-Ranges", "none")
+		rw.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		if _, err := io.Copy(rw, body); err != nil {
+			logger.Error("error streaming upload", "upload_id", id, "error", err)
+		}
+		return
+	}
+	content := delivery.NewReadSeeker(r.Context(), src, id, size)
+	defer content.Close()
+	http.ServeContent(rw, r, "", time.Time{}, content)
+}
+
+func (h *DownloadHandler) presign(rw http.ResponseWriter, r *http.Request) {
+	manifest, ok := h.manifest(rw, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("UploadID")
+	p, ok := h.Source.(delivery.Presigner)
+	if !ok {
+		http.Error(rw, delivery.ErrPresignUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	if !h.scanned(rw, r, manifest) {
+		return
+	}
+	expiresAt := time.Now().Add(h.URLExpiry).UTC()
+	u, err := p.PresignedURL(r.Context(), id, metadata.GetFilename(manifest), h.URLExpiry)
+	if err != nil {
+		if errors.Is(err, delivery.ErrPresignUnsupported) {
+			http.Error(rw, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		logger.Error("error presigning upload download", "upload_id", id, "error", err)
+		http.Error(rw, "error presigning upload download", http.StatusInternalServerError)
+		return
+	}
+	auditDownload(r, "upload download url issued", "expires_at", expiresAt)
+	writeJSON(rw, http.StatusOK, DownloadURLResponse{URL: u, ExpiresAt: expiresAt})
+}
+
+func auditDownload(r *http.Request, msg string, fields ...any) {
+	claims, _ := oauth.FromContext(r.Context())
+	fields = append([]any{
+		"upload_id", r.PathValue("UploadID"),
+		"subject", claims.Subject,
+		"issuer", claims.Issuer,
+		"roles", claims.Roles,
+		"range", r.Header.Get("Range"),
+		"remote_addr", r.RemoteAddr,
+	}, fields...)
+	logger.Info(msg, fields...)
+}
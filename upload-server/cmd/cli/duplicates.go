@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/dedupe"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+)
+
+// InitDuplicateDetector returns the detector that applies the duplicate policies of the data streams.  Must be
+// called after the config cache is initialized.
+func InitDuplicateDetector(appConfig appconfig.AppConfig) (*dedupe.Detector, error) {
+	conf := appConfig.Duplicates
+
+	var store dedupe.Store = dedupe.NewMemoryStore()
+	if conf.RedisConnectionString != "" {
+		var err error
+		store, err = dedupe.NewRedisStore(conf.RedisConnectionString)
+		if err != nil {
+			return nil, err
+		}
+		health.Register(store)
+	}
+
+	return &dedupe.Detector{
+		Store:    store,
+		Configs:  metadata.Cache,
+		StateTTL: conf.StateTTL,
+	}, nil
+}
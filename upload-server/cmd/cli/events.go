@@ -23,6 +23,7 @@ func NewEventPublisher[T event.Identifiable](ctx context.Context, appConfig appc
 		if err != nil {
 			return p, err
 		}
+		health.RegisterCritical(snsPub)
 		p = append(p, snsPub)
 	}
 
@@ -35,7 +36,7 @@ func NewEventPublisher[T event.Identifiable](ctx context.Context, appConfig appc
 		if err != nil {
 			return p, err
 		}
-		health.Register(ap)
+		health.RegisterCritical(ap)
 		p = append(p, ap)
 		return p, err
 	}
@@ -2,9 +2,11 @@ package cli
 
 import (
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/dedupe"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/logutil"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/postprocessing"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/scan"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storeaz"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/upload"
 	prebuilthooks "github.com/cdcgov/data-exchange-upload/upload-server/pkg/hooks"
@@ -16,11 +18,20 @@ type RegisterableHookHandler interface {
 	Register(t tusHooks.HookType, hookFuncs ...prebuilthooks.HookHandlerFunc)
 }
 
-func GetHookHandler(appConfig appconfig.AppConfig) (RegisterableHookHandler, error) {
+func GetHookHandler(appConfig appconfig.AppConfig, detector *dedupe.Detector, scanner *scan.Stage) (RegisterableHookHandler, error) {
 
 	manifestValidator := metadata.SenderManifestVerification{
 		Configs: metadata.Cache,
 	}
+	manifestTransformer := metadata.ManifestTransformer{
+		Configs: metadata.Cache,
+	}
+	senderAuthorization := metadata.SenderAuthorization{
+		Configs: metadata.Cache,
+	}
+	sizeLimit := metadata.SizeLimit{
+		Configs: metadata.Cache,
+	}
 
 	var metadataAppender metadata.Appender = &metadata.FileMetadataAppender{
 		Path: appConfig.LocalFolderUploadsTus + "/" + appConfig.TusUploadPrefix,
@@ -43,20 +54,35 @@ func GetHookHandler(appConfig appconfig.AppConfig) (RegisterableHookHandler, err
 		metadataAppender = &metadata.NoopAppender{}
 	}
 
-	return PrebuiltHooks(manifestValidator, metadataAppender)
+	if appConfig.GCSConnection != nil {
+		// Tus GCS store sets the upload metadata on the GCS object when the upload finishes
+		metadataAppender = &metadata.NoopAppender{}
+	}
+
+	return PrebuiltHooks(manifestTransformer, senderAuthorization, sizeLimit, manifestValidator, metadataAppender, detector, scanner)
 }
 
-func PrebuiltHooks(validator metadata.SenderManifestVerification, appender metadata.Appender) (RegisterableHookHandler, error) {
+func PrebuiltHooks(transformer metadata.ManifestTransformer, authorization metadata.SenderAuthorization, sizeLimit metadata.SizeLimit, validator metadata.SenderManifestVerification, appender metadata.Appender, detector *dedupe.Detector, scanner *scan.Stage) (RegisterableHookHandler, error) {
 	handler := &prebuilthooks.PrebuiltHook{}
 
-	handler.Register(tusHooks.HookPreCreate, metadata.WithUploadId, logutil.WithUploadIdLogger, metadata.WithPreCreateManifestTransforms, validator.Verify)
-	handler.Register(tusHooks.HookPostCreate, logutil.WithUploadIdLogger, upload.ReportUploadStarted)
-	handler.Register(tusHooks.HookPostReceive, logutil.WithUploadIdLogger, upload.ReportUploadStatus)
-	handler.Register(tusHooks.HookPreFinish, logutil.WithUploadIdLogger, appender.Append)
+	// Partial uploads of a concatenation carry no manifest, so they are not transformed, validated, reported or
+	// delivered.  The final upload is, once tusd has concatenated them.
+	handler.Register(tusHooks.HookPreCreate, metadata.WithUploadId, TraceUploadCreated, logutil.WithUploadIdLogger, prebuilthooks.SkipPartialUploads(transformer.Transform), prebuilthooks.SkipPartialUploads(authorization.Authorize), prebuilthooks.SkipPartialUploads(sizeLimit.Check), prebuilthooks.SkipPartialUploads(validator.Verify))
+	handler.Register(tusHooks.HookPostCreate, logutil.WithUploadIdLogger, prebuilthooks.SkipPartialUploads(upload.ReportUploadStarted))
+	handler.Register(tusHooks.HookPostReceive, logutil.WithUploadIdLogger, prebuilthooks.SkipPartialUploads(upload.ReportUploadStatus), prebuilthooks.SkipPartialUploads(sizeLimit.Enforce))
+	handler.Register(tusHooks.HookPreFinish, logutil.WithUploadIdLogger, prebuilthooks.SkipPartialUploads(sizeLimit.Finish), prebuilthooks.SkipPartialUploads(appender.Append), prebuilthooks.SkipPartialUploads(detector.Check))
 	// note that tus sends this to a potentially blocking channel.
 	// however it immediately pulls from that channel in to a goroutine..so we're good
 
-	handler.Register(tusHooks.HookPostFinish, logutil.WithUploadIdLogger, upload.ReportUploadComplete, postprocessing.RouteAndDeliverHook())
+	// duplicates rejected by their data stream are accepted by tus, but not delivered, and neither are uploads that
+	// are not scanned clean when malware scanning is configured
+	deliver := postprocessing.RouteAndDeliverHook()
+	if scanner != nil {
+		deliver = scanner.BeforeDelivery(deliver)
+	}
+	handler.Register(tusHooks.HookPostFinish, logutil.WithUploadIdLogger, prebuilthooks.FinalUploadsOnly(appender.Append), prebuilthooks.FinalUploadsOnly(detector.Check), prebuilthooks.SkipPartialUploads(upload.ReportUploadComplete), prebuilthooks.SkipPartialUploads(detector.SkipRejected(deliver)))
+	// tusd deletes the upload and its info file, and the deliveries still pending are skipped once it is gone
+	handler.Register(tusHooks.HookPostTerminate, logutil.WithUploadIdLogger, prebuilthooks.SkipPartialUploads(appender.Remove), prebuilthooks.SkipPartialUploads(upload.ReportUploadTerminated), detector.Forget)
 
 	return handler, nil
 }
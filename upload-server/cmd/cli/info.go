@@ -5,18 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storegcs"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/stores3"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/azureinspector"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/fileinspector"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/gcsinspector"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/s3inspector"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storeaz"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/info"
 )
 
+var ErrUploadForbidden = errors.New("upload belongs to another sender or data stream")
+
 type UploadInspector interface {
 	InspectInfoFile(c context.Context, id string) (map[string]any, error)
 	InspectUploadedFile(c context.Context, id string) (map[string]any, error)
@@ -35,17 +41,32 @@ func (ih *InfoHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	fileInfo, err := ih.inspector.InspectInfoFile(r.Context(), id)
+	terminated := false
+	if errors.Is(err, info.ErrNotFound) {
+		// the info file is deleted when an upload is terminated, but its report keeps the manifest
+		if manifest, terr := ih.statusInspector.InspectTerminatedUpload(r.Context(), id); terr == nil {
+			fileInfo, err, terminated = manifest, nil, true
+		}
+	}
 	if err != nil {
 		http.Error(rw, "error getting file manifest", getStatusFromError(err))
 		return
 	}
+	if claims, ok := oauth.FromContext(r.Context()); ok && !claims.CanView(info.ManifestValues(fileInfo)) {
+		slog.Warn("upload info not visible to principal", "upload_id", id, "subject", claims.Subject, "roles", claims.Roles)
+		http.Error(rw, ErrUploadForbidden.Error(), http.StatusForbidden)
+		return
+	}
 
-	uploadedFileInfo, err := ih.inspector.InspectUploadedFile(r.Context(), id)
-	if err != nil {
-		// skip not found errors to handle deferred uploads.
-		if !errors.Is(err, info.ErrNotFound) {
-			http.Error(rw, fmt.Sprintf("error getting file info.  Manifest: %#v", fileInfo), getStatusFromError(err))
-			return
+	var uploadedFileInfo map[string]any
+	if !terminated {
+		uploadedFileInfo, err = ih.inspector.InspectUploadedFile(r.Context(), id)
+		if err != nil {
+			// skip not found errors to handle deferred uploads.
+			if !errors.Is(err, info.ErrNotFound) {
+				http.Error(rw, fmt.Sprintf("error getting file info.  Manifest: %#v", fileInfo), getStatusFromError(err))
+				return
+			}
 		}
 	}
 
@@ -105,6 +126,14 @@ func createInspector(ctx context.Context, appConfig *appconfig.AppConfig) (Uploa
 
 		return s3inspector.NewS3UploadInspector(s3Client, appConfig.S3Connection.BucketName, appConfig.TusUploadPrefix), nil
 	}
+	if appConfig.GCSConnection != nil {
+		gcsClient, err := storegcs.NewClient(ctx, appConfig.GCSConnection.Endpoint, appConfig.GCSConnection.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return gcsinspector.NewGCSUploadInspector(gcsClient, appConfig.GCSConnection.BucketName, appConfig.TusUploadPrefix), nil
+	}
 	if appConfig.LocalFolderUploadsTus != "" {
 		return fileinspector.NewFileSystemUploadInspector(appConfig.LocalFolderUploadsTus, appConfig.TusUploadPrefix), nil
 	}
@@ -112,7 +141,7 @@ func createInspector(ctx context.Context, appConfig *appconfig.AppConfig) (Uploa
 	return nil, errors.New("unable to create inspector given app configuration")
 }
 
-func GetUploadInfoHandler(ctx context.Context, appConfig *appconfig.AppConfig) (http.Handler, error) {
+func GetUploadInfoHandler(ctx context.Context, appConfig *appconfig.AppConfig) (*InfoHandler, error) {
 	i, err := createInspector(ctx, appConfig)
 	return &InfoHandler{
 		i,
@@ -12,6 +12,7 @@ import (
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storeaz"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storegcs"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/stores3"
 )
 
@@ -22,7 +23,13 @@ func InitConfigCache(ctx context.Context, appConfig appconfig.AppConfig) error {
 		},
 	}
 
-	if appConfig.AzureConnection != nil && appConfig.S3Connection != nil {
+	configured := 0
+	for _, c := range []bool{appConfig.AzureConnection != nil, appConfig.S3Connection != nil, appConfig.GCSConnection != nil} {
+		if c {
+			configured++
+		}
+	}
+	if configured > 1 {
 		return errors.New("cannot load metadata config from multiple locations")
 	}
 
@@ -53,5 +60,21 @@ func InitConfigCache(ctx context.Context, appConfig appconfig.AppConfig) error {
 		}
 	}
 
+	if appConfig.GCSConnection != nil && appConfig.GCSManifestConfigFolder != "" {
+		client, err := storegcs.NewClient(ctx, appConfig.GCSConnection.Endpoint, appConfig.GCSConnection.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		bucket := appConfig.GCSConnection.BucketName
+		if appConfig.GCSManifestConfigBucket != "" {
+			bucket = appConfig.GCSManifestConfigBucket
+		}
+		metadata.Cache.Loader = &loaders.GCSConfigLoader{
+			Client:     client,
+			BucketName: bucket,
+			Folder:     appConfig.GCSManifestConfigFolder,
+		}
+	}
+
 	return nil
 }
@@ -18,6 +18,9 @@ func setupMetrics(m ...prometheus.Collector) {
 		metrics.OpenConnections,
 		metrics.ActiveUploads,
 		metrics.UploadSpeeds,
+		metrics.RateLimitRejections,
+		metrics.MalwareScans,
+		metrics.MalwareScanDurations,
 		metrics.EventsCounter,
 		metrics.CurrentMessages,
 		// Maybe these delivery metrics can be grouped in some way
@@ -37,7 +37,7 @@ func NewEventSubscriber[T event.Identifiable](ctx context.Context, appConfig app
 		if err := s.Subscribe(ctx, topicARN); err != nil {
 			return s, fmt.Errorf("arn: %s, %w", topicARN, err)
 		}
-		health.Register(s)
+		health.RegisterCritical(s)
 		return s, nil
 
 	}
@@ -48,7 +48,7 @@ func NewEventSubscriber[T event.Identifiable](ctx context.Context, appConfig app
 			return nil, err
 		}
 
-		health.Register(sub)
+		health.RegisterCritical(sub)
 		return sub, nil
 	}
 
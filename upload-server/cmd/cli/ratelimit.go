@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/ratelimit"
+)
+
+// InitRateLimiter returns the limiter for the configured limits, or nil when rate limits are not configured.
+func InitRateLimiter(appConfig appconfig.AppConfig) (*ratelimit.Limiter, error) {
+	conf := appConfig.RateLimit
+	if conf == nil {
+		return nil, nil
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if conf.RedisConnectionString != "" {
+		var err error
+		store, err = ratelimit.NewRedisStore(conf.RedisConnectionString)
+		if err != nil {
+			return nil, err
+		}
+		health.Register(store)
+	}
+
+	return &ratelimit.Limiter{
+		Store: store,
+		Principal: ratelimit.Limits{
+			RequestsPerSecond:    conf.PrincipalRequestsPerSecond,
+			Burst:                conf.PrincipalBurst,
+			MaxConcurrentUploads: conf.PrincipalMaxConcurrentUploads,
+			MaxBytesPerDay:       conf.PrincipalMaxBytesPerDay,
+			MaxUploadSize:        conf.PrincipalMaxUploadSize,
+		},
+		DataStream: ratelimit.Limits{
+			RequestsPerSecond:    conf.DataStreamRequestsPerSecond,
+			Burst:                conf.DataStreamBurst,
+			MaxConcurrentUploads: conf.DataStreamMaxConcurrentUploads,
+			MaxBytesPerDay:       conf.DataStreamMaxBytesPerDay,
+			MaxUploadSize:        conf.DataStreamMaxUploadSize,
+		},
+		ActiveUploadTTL: conf.ActiveUploadTTL,
+	}, nil
+}
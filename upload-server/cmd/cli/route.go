@@ -8,9 +8,15 @@ import (
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/scan"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
 )
 
-type Router struct{}
+// Router delivers an upload to a target again.  When Scanner is set the upload is scanned first, so quarantined
+// uploads are not delivered by hand either.
+type Router struct {
+	Scanner *scan.Stage
+}
 type RequestBody struct {
 	Target string `json:"target"`
 	Source string `json:"source"`
@@ -68,6 +74,18 @@ func (router *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if router.Scanner != nil {
+		clean, err := router.Scanner.Scan(r.Context(), src, id, m)
+		if err != nil {
+			http.Error(rw, "unable to scan upload: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !clean {
+			http.Error(rw, "upload is quarantined", http.StatusConflict)
+			return
+		}
+	}
+
 	e := &event.FileReady{
 		Event: event.Event{
 			Type: event.FileReadyEventType,
@@ -77,6 +95,7 @@ func (router *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		DestinationTarget: body.Target,
 		Metadata:          m,
 		SrcUrl:            id,
+		Principal:         metadata.GetPrincipal(m),
 	}
 	err = event.FileReadyPublisher.Publish(r.Context(), e)
 	if err != nil {
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/scan"
+)
+
+// InitMalwareScanner returns the stage that scans uploads before they are delivered, or nil when malware scanning
+// is not configured.  Must be called after the sources are registered.
+func InitMalwareScanner(appConfig appconfig.AppConfig) (*scan.Stage, error) {
+	conf := appConfig.MalwareScan
+	if conf == nil {
+		return nil, nil
+	}
+	if conf.ClamdAddress == "" {
+		return nil, errors.New("malware scanning is configured without a clamd address")
+	}
+
+	scanner, err := scan.NewClamdScanner(conf.ClamdAddress, conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	health.Register(scanner)
+
+	src, ok := delivery.GetSource(delivery.UploadSrc)
+	if !ok {
+		return nil, errors.New("no upload source to scan uploads from")
+	}
+	return &scan.Stage{Scanner: scanner, Source: src}, nil
+}
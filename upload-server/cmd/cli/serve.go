@@ -1,18 +1,32 @@
 package cli
 
 import (
+	"cmp"
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/concat"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/dedupe"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/handlertusd"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/middleware"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/mtls"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/statusbus"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/uploadindex"
+	prebuilthooks "github.com/cdcgov/data-exchange-upload/upload-server/pkg/hooks"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/pglocker"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/redislocker"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tus/tusd/v2/pkg/filestore"
+	tusd "github.com/tus/tusd/v2/pkg/handler"
 	"github.com/tus/tusd/v2/pkg/hooks"
 	"github.com/tus/tusd/v2/pkg/memorylocker"
 )
@@ -28,7 +42,14 @@ func Serve(ctx context.Context, appConfig appconfig.AppConfig, authMiddleware *m
 		logger.Error("error starting app, error configuring storage", "error", err)
 		return nil, err
 	} // .if
-	health.Register(storeHealthCheck)
+	health.DefaultSystemHealthCheck.Timeout = appConfig.HealthCheckTimeout
+	health.DefaultSystemHealthCheck.CacheTTL = appConfig.HealthCheckCacheTTL
+	health.RegisterCritical(storeHealthCheck)
+	// partial uploads left behind by a restart or by another instance are found by listing the store
+	lister, _ := store.(concat.Lister)
+	if fs, ok := store.(filestore.FileStore); ok {
+		lister = concat.FileLister{Path: fs.Path}
+	}
 
 	uploadInfoHandler, err := GetUploadInfoHandler(ctx, &appConfig)
 	if err != nil {
@@ -38,14 +59,22 @@ func Serve(ctx context.Context, appConfig appconfig.AppConfig, authMiddleware *m
 
 	// initialize locker
 	var locker handlertusd.Locker = memorylocker.New()
-	if appConfig.TusRedisLockURI != "" {
+	if appConfig.TusSQLLockURI != "" {
+		var err error
+		locker, err = pglocker.New(appConfig.TusSQLLockURI, pglocker.WithLogger(logger))
+		if err != nil {
+			logger.Error("failed to initialize Postgres Locker", "error", err)
+			return nil, err
+		}
+		health.RegisterCritical(locker.(health.Checkable))
+	} else if appConfig.TusRedisLockURI != "" {
 		var err error
 		locker, err = redislocker.New(appConfig.TusRedisLockURI, redislocker.WithLogger(logger))
 		if err != nil {
 			logger.Error("failed to initialize Redis Locker", "error", err)
 			return nil, err
 		}
-		health.Register(locker.(health.Checkable))
+		health.RegisterCritical(locker.(health.Checkable))
 	}
 
 	manifestMetrics := metrics.NewManifestMetrics(
@@ -65,17 +94,68 @@ func Serve(ctx context.Context, appConfig appconfig.AppConfig, authMiddleware *m
 		return nil, err
 	}
 
+	detector, err := InitDuplicateDetector(appConfig)
+	if err != nil {
+		logger.Error("failed to initialize duplicate detection", "error", err)
+		return nil, err
+	}
+	// uploads to data streams that check for duplicates are hashed as they are received, so finished uploads do
+	// not have to be read again to find duplicates
+	store = &dedupe.HashingStore{DataStore: store, States: detector.Store, TTL: appConfig.Duplicates.StateTTL, Detector: detector}
+
+	scanner, err := InitMalwareScanner(appConfig)
+	if err != nil {
+		logger.Error("failed to initialize malware scanning", "error", err)
+		return nil, err
+	}
+
 	// get and initialize tusd hook handlers
-	hookHandler, err := GetHookHandler(appConfig)
+	hookHandler, err := GetHookHandler(appConfig, detector, scanner)
 	if err != nil {
 		logger.Error("error configuring tusd handler: ", "error", err)
 		return nil, err
 	}
 	hookHandler.Register(hooks.HookPostCreate, metrics.ActiveUploadIncHook)
-	hookHandler.Register(hooks.HookPostFinish, manifestMetrics.Hook, metrics.ActiveUploadDecHook, metrics.UploadSpeedsHook)
+	hookHandler.Register(hooks.HookPostFinish, prebuilthooks.SkipPartialUploads(manifestMetrics.Hook), metrics.ActiveUploadDecHook, metrics.UploadSpeedsHook)
+	hookHandler.Register(hooks.HookPostTerminate, metrics.ActiveUploadTerminatedHook)
+
+	// partial uploads carry no manifest, so only the sender that created them may concatenate them
+	composer := tusd.NewStoreComposer()
+	store.UseIn(composer)
+	locker.UseIn(composer)
+	partials := &concat.Partials{
+		Store:  composer.Core,
+		Locker: composer.Locker,
+		Lister: lister,
+		TTL:    cmp.Or(appConfig.PartialUploadTTL, concat.DefaultPartialUploadTTL),
+	}
+	partials.PostTerminate = append(partials.PostTerminate, metrics.ActiveUploadTerminatedHook)
+	if composer.UsesTerminater {
+		partials.Terminater = composer.Terminater
+		hookHandler.Register(hooks.HookPostCreate, partials.Track)
+		hookHandler.Register(hooks.HookPostFinish, prebuilthooks.FinalUploadsOnly(partials.Remove))
+		hookHandler.Register(hooks.HookPostTerminate, partials.Remove)
+	}
+	hookHandler.Register(hooks.HookPreCreate, partials.Record, partials.Authorize)
+
+	limiter, err := InitRateLimiter(appConfig)
+	if err != nil {
+		logger.Error("failed to initialize rate limits", "error", err)
+		return nil, err
+	}
+	if limiter != nil {
+		hookHandler.Register(hooks.HookPreCreate, limiter.Check)
+		hookHandler.Register(hooks.HookPostReceive, limiter.Enforce)
+		hookHandler.Register(hooks.HookPostFinish, limiter.Count, limiter.Release)
+		hookHandler.Register(hooks.HookPostTerminate, limiter.Release)
+		partials.PostTerminate = append(partials.PostTerminate, limiter.Release)
+	}
+	if partials.Terminater != nil {
+		go partials.Watch(ctx, min(partials.TTL, time.Hour))
+	}
 
 	// initialize tusd handler
-	handlerTusd, err := handlertusd.New(store, locker, hookHandler, appConfig.TusdHandlerBasePath)
+	handlerTusd, err := handlertusd.New(store, locker, hookHandler, appConfig.TusdHandlerBasePath, appConfig.TusdMaxSize)
 	if err != nil {
 		logger.Error("error starting tusd handler: ", "error", err)
 		return nil, err
@@ -90,11 +170,24 @@ func Serve(ctx context.Context, appConfig appconfig.AppConfig, authMiddleware *m
 	logger.Info("hosting tus handler", "path", appConfig.TusdHandlerBasePath)
 	pathWithoutSlash := strings.TrimSuffix(appConfig.TusdHandlerBasePath, "/")
 	pathWithSlash := pathWithoutSlash + "/"
-	mux.Handle(pathWithoutSlash, authMiddleware.VerifyOAuthTokenMiddleware(http.StripPrefix(pathWithoutSlash, handlerTusd)))
-	mux.Handle(pathWithSlash, authMiddleware.VerifyOAuthTokenMiddleware(http.StripPrefix(pathWithSlash, handlerTusd)))
+	tusRoute := func(prefix string) http.Handler {
+		var h http.Handler = http.StripPrefix(prefix, handlerTusd)
+		if limiter != nil {
+			h = limiter.Middleware(h)
+		}
+		h = authMiddleware.VerifyOAuthTokenMiddleware(h)
+		if appConfig.TLS != nil && appConfig.TLS.ClientAuth == mtls.ClientAuthRequire {
+			h = middleware.RequireClientCertificate(h)
+		}
+		return h
+	}
+	mux.Handle(pathWithoutSlash, tusRoute(pathWithoutSlash))
+	mux.Handle(pathWithSlash, tusRoute(pathWithSlash))
 
 	// initialize and route handler for DEX
 	mux.Handle("/health", health.Handler())
+	mux.Handle("/health/live", health.Live())
+	mux.Handle("/health/ready", health.ReadyHandler())
 
 	// --------------------------------------------------------------
 	// 	Prometheus metrics handler for /metrics
@@ -102,8 +195,41 @@ func Serve(ctx context.Context, appConfig appconfig.AppConfig, authMiddleware *m
 	mux.Handle("/metrics", promhttp.Handler())
 
 	mux.Handle("/info/{UploadID}", authMiddleware.VerifyOAuthTokenMiddleware(uploadInfoHandler))
+	if statusbus.Default != nil {
+		mux.Handle("GET /uploads/{UploadID}/events", authMiddleware.VerifyOAuthTokenMiddleware(&StatusEventsHandler{Inspector: uploadInfoHandler.inspector, Bus: statusbus.Default, AllowedOrigins: appConfig.StatusEventsAllowedOrigins}))
+	}
+	if uploadindex.Default != nil {
+		mux.Handle("GET /uploads", authMiddleware.VerifyOAuthTokenMiddleware(&UploadsHandler{Index: uploadindex.Default}))
+	}
+	if src, ok := delivery.GetSource(delivery.UploadSrc); ok {
+		downloadHandler := &DownloadHandler{Source: src, URLExpiry: appConfig.DownloadURLExpiry, Scanner: scanner}
+		downloadHandler.Register(mux, func(h http.Handler) http.Handler {
+			return authMiddleware.VerifyOAuthTokenMiddleware(authMiddleware.RequireRole(oauth.RoleProgramViewer, h))
+		})
+	}
+	mux.Handle("/data-streams", authMiddleware.VerifyOAuthTokenMiddleware(&DataStreamsHandler{Configs: metadata.Cache}))
+	configsHandler := &ConfigsHandler{Configs: metadata.Cache}
+	configsHandler.Register(mux, func(h http.Handler) http.Handler {
+		return authMiddleware.VerifyOAuthTokenMiddleware(authMiddleware.RequireRole(oauth.RoleAdmin, h))
+	})
+	if keys := authMiddleware.APIKeys(); keys != nil && appConfig.APIKeyConfig != nil {
+		apiKeysHandler := &APIKeysHandler{
+			Keys:                keys,
+			RotationGracePeriod: appConfig.APIKeyConfig.RotationGracePeriod,
+		}
+		apiKeysHandler.Register(mux, func(h http.Handler) http.Handler {
+			return authMiddleware.VerifyOAuthTokenMiddleware(authMiddleware.RequireRole(oauth.RoleAdmin, h))
+		})
+	}
+	if sessions := middleware.Sessions(); sessions != nil {
+		sessionsHandler := &SessionsHandler{Sessions: sessions}
+		sessionsHandler.Register(mux, func(h http.Handler) http.Handler {
+			return authMiddleware.VerifyOAuthTokenMiddleware(authMiddleware.RequireRole(oauth.RoleAdmin, h))
+		})
+	}
 	mux.Handle("/version", &VersionHandler{})
-	mux.Handle("/route/{UploadID}", &Router{})
+	// retrying a delivery is left to operators
+	mux.Handle("/route/{UploadID}", authMiddleware.VerifyOAuthTokenMiddleware(authMiddleware.RequireRole(oauth.RoleOperator, &Router{Scanner: scanner})))
 
 	mux.Handle("/{$}", appconfig.Handler())
 
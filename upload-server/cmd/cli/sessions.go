@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/session"
+)
+
+// InitSessionStore returns the configured server side store for UI sessions, or nil to keep them in memory.
+func InitSessionStore(ctx context.Context, appConfig appconfig.AppConfig) (session.Store, error) {
+	conf := appConfig.OauthConfig
+	var store session.Store
+	if conf.SessionFile != "" {
+		store = &session.FileStore{Path: conf.SessionFile}
+	}
+	if conf.SessionRedisConnectionString != "" {
+		var err error
+		store, err = session.NewRedisStore(conf.SessionRedisConnectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.SessionSQLConnectionString != "" {
+		var err error
+		store, err = session.NewSQLStore(ctx, conf.SessionSQLConnectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if store != nil {
+		health.Register(store)
+	}
+	return store, nil
+}
+
+type RevokeSessionsResponse struct {
+	Subject string `json:"subject"`
+	Revoked int    `json:"revoked"`
+}
+
+// SessionsHandler is the admin API for signing a user out of every UI session.
+type SessionsHandler struct {
+	Sessions *session.Manager
+}
+
+func (h *SessionsHandler) Register(mux *http.ServeMux, wrap func(http.Handler) http.Handler) {
+	mux.Handle("DELETE /admin/sessions", wrap(http.HandlerFunc(h.revoke)))
+}
+
+func (h *SessionsHandler) revoke(rw http.ResponseWriter, r *http.Request) {
+	subject := r.URL.Query().Get("subject")
+	if subject == "" {
+		http.Error(rw, "missing subject", http.StatusBadRequest)
+		return
+	}
+	n, err := h.Sessions.RevokeSubject(r.Context(), subject)
+	if err != nil {
+		logger.Error("error revoking sessions", "error", err)
+		http.Error(rw, "error revoking sessions", http.StatusInternalServerError)
+		return
+	}
+	claims, _ := oauth.FromContext(r.Context())
+	logger.Info("revoked sessions", "subject", subject, "revoked", n, "admin", claims.Subject)
+	writeJSON(rw, http.StatusOK, RevokeSessionsResponse{Subject: subject, Revoked: n})
+}
@@ -9,4 +9,7 @@ import (
 type UploadStatusInspector interface {
 	InspectFileDeliveryStatus(ctx context.Context, id string) ([]info.FileDeliveryStatus, error)
 	InspectFileUploadStatus(ctx context.Context, id string) (info.FileUploadStatus, error)
+	// InspectTerminatedUpload returns the manifest of an upload that was terminated, which is deleted along with
+	// the upload's info file.
+	InspectTerminatedUpload(ctx context.Context, id string) (map[string]any, error)
 }
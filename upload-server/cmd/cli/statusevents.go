@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/statusbus"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/info"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+)
+
+// StatusEventsKeepAlive is how often an idle status stream is sent a comment, so proxies do not close it.
+var StatusEventsKeepAlive = 15 * time.Second
+
+// InitStatusBus sets up the bus of upload status updates and registers it as a reporter.  It returns the
+// subscriber to the updates of the other instances, or nil when updates are not shared between instances.
+func InitStatusBus(ctx context.Context, appConfig appconfig.AppConfig) (event.Subscribable[*statusbus.Update], error) {
+	var remote []event.Publisher[*statusbus.Update]
+	if conf := appConfig.SNSStatusPublisherConnection; conf != nil {
+		p, err := event.NewSNSPublisher[*statusbus.Update](ctx, conf.EventArn)
+		if err != nil {
+			return nil, err
+		}
+		health.Register(p)
+		remote = append(remote, p)
+	}
+	if conf := appConfig.StatusPublisherConnection; conf != nil {
+		topic := conf.Topic
+		if topic == "" {
+			topic = conf.Queue
+		}
+		p, err := event.NewAzurePublisher[*statusbus.Update](ctx, conf.ConnectionString, topic)
+		if err != nil {
+			return nil, err
+		}
+		health.Register(p)
+		remote = append(remote, p)
+	}
+	statusbus.Default = statusbus.New(remote...)
+	reports.Register(statusbus.Default)
+
+	if conf := appConfig.SQSStatusSubscriberConnection; conf != nil {
+		batchMax := conf.MaxMessages
+		if batchMax == 0 {
+			batchMax = event.MaxMessages
+		}
+		maxRetries := conf.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = 5
+		}
+		s, err := event.NewSQSSubscriber[*statusbus.Update](ctx, conf.EventArn, batchMax, maxRetries)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Subscribe(ctx, conf.TopicArn); err != nil {
+			return nil, fmt.Errorf("arn: %s, %w", conf.TopicArn, err)
+		}
+		health.Register(s)
+		return s, nil
+	}
+	if conf := appConfig.StatusSubscriberConnection; conf != nil {
+		s, err := event.NewAzureSubscriber[*statusbus.Update](ctx, conf.ConnectionString, conf.Topic, conf.Subscription, conf.MaxMessages)
+		if err != nil {
+			return nil, err
+		}
+		health.Register(s)
+		return s, nil
+	}
+	return nil, nil
+}
+
+// StatusEventsHandler streams the status updates of an upload as server-sent events, named after the stage of
+// the update.  Only updates made after the stream starts are sent, so clients read the current status from
+// /info/{UploadID} first.
+// Only browser clients from AllowedOrigins, such as the UI, may listen with the user's session cookie.
+type StatusEventsHandler struct {
+	Inspector      UploadInspector
+	Bus            *statusbus.Bus
+	AllowedOrigins []string
+}
+
+func (h *StatusEventsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		rw.Header().Add("Vary", "Origin")
+		if slices.ContainsFunc(h.AllowedOrigins, func(o string) bool { return strings.EqualFold(strings.TrimSuffix(o, "/"), origin) }) {
+			rw.Header().Set("Access-Control-Allow-Origin", origin)
+			rw.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	id := r.PathValue("UploadID")
+	manifest, err := h.Inspector.InspectInfoFile(r.Context(), id)
+	if err != nil {
+		http.Error(rw, "error getting file manifest", getStatusFromError(err))
+		return
+	}
+	if claims, ok := oauth.FromContext(r.Context()); ok && !claims.CanView(info.ManifestValues(manifest)) {
+		slog.Warn("upload status not visible to principal", "upload_id", id, "subject", claims.Subject, "roles", claims.Roles)
+		http.Error(rw, ErrUploadForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	updates, cancel := h.Bus.Subscribe(id)
+	defer cancel()
+
+	rc := http.NewResponseController(rw)
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		logger.Error("status stream can not be flushed", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(StatusEventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(rw, ": keep-alive\n\n")
+		case u, ok := <-updates:
+			if !ok {
+				// the client fell behind, and reconnects to start over
+				return
+			}
+			b, err := json.Marshal(u)
+			if err != nil {
+				logger.Error("error encoding status update", "error", err)
+				continue
+			}
+			fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", u.Stage, b)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
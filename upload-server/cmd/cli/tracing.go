@@ -10,7 +10,10 @@ import (
 	"sync"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/middleware"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
@@ -100,8 +103,29 @@ func TracingProcessor[T event.Identifiable](next func(context.Context, T) error)
 	tracer := otel.Tracer("event-handling")
 	return func(ctx context.Context, e T) error {
 		c := context.WithValue(ctx, middleware.UploadID, otrace.TraceID(md5.Sum([]byte(e.GetUploadID()))))
-		_, span := tracer.Start(c, fmt.Sprintf("Handling-%s", e.Identifier()))
+		_, span := tracer.Start(c, fmt.Sprintf("Handling-%s", e.Identifier()), otrace.WithSpanKind(otrace.SpanKindConsumer))
 		defer span.End()
 		return next(c, e)
 	}
 }
+
+// TraceUploadCreated starts the upload's trace once its id is assigned.  The create request is traced before
+// there is an id, so its span is linked to the upload's trace rather than part of it, and the hooks that follow
+// run in the upload's trace.
+func TraceUploadCreated(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	tuid, err := metadata.GetUploadId(*event, resp)
+	if err != nil {
+		return resp, err
+	}
+	ctx := event.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c := context.WithValue(ctx, middleware.UploadID, otrace.TraceID(md5.Sum([]byte(tuid))))
+	c, span := otel.Tracer("upload").Start(c, "Create-upload",
+		otrace.WithNewRoot(),
+		otrace.WithLinks(otrace.LinkFromContext(ctx)))
+	defer span.End()
+	event.Context = c
+	return resp, nil
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/uploadindex"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+)
+
+// InitUploadIndex sets up the upload index and registers it as a reporter, so every report published keeps it up
+// to date.
+func InitUploadIndex(ctx context.Context, appConfig appconfig.AppConfig) error {
+	conf := appConfig.UploadIndex
+	memory := uploadindex.NewMemoryStore()
+	memory.MaxEntries = conf.MemoryMaxEntries
+	memory.TTL = conf.MemoryTTL
+	var store uploadindex.Store = memory
+	if conf.File != "" {
+		store = &uploadindex.FileStore{Path: conf.File}
+	}
+	if conf.RedisConnectionString != "" {
+		var err error
+		store, err = uploadindex.NewRedisStore(conf.RedisConnectionString)
+		if err != nil {
+			return err
+		}
+	}
+	if conf.SQLConnectionString != "" {
+		var err error
+		store, err = uploadindex.NewSQLStore(ctx, conf.SQLConnectionString)
+		if err != nil {
+			return err
+		}
+	}
+	uploadindex.Default = &uploadindex.Index{Store: store}
+	reports.Register(uploadindex.Default)
+	health.Register(store)
+	return nil
+}
+
+// UploadsHandler lists the uploads the caller may view, newest first.
+type UploadsHandler struct {
+	Index *uploadindex.Index
+}
+
+func (h *UploadsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := uploadindex.Query{
+		DataStreamID:    params.Get("data_stream_id"),
+		DataStreamRoute: params.Get("data_stream_route"),
+		SenderID:        params.Get("sender_id"),
+		Jurisdiction:    params.Get("jurisdiction"),
+		Status:          params.Get("status"),
+		Cursor:          params.Get("cursor"),
+	}
+	var err error
+	if v := params.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(rw, "invalid from, expected an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(rw, "invalid to, expected an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			http.Error(rw, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if claims, ok := oauth.FromContext(r.Context()); ok {
+		q.Viewer = viewer(claims)
+	}
+	page, err := h.Index.List(r.Context(), q)
+	if errors.Is(err, uploadindex.ErrInvalidStatus) || errors.Is(err, uploadindex.ErrInvalidCursor) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error("error listing uploads", "error", err)
+		http.Error(rw, "error listing uploads", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusOK, page)
+}
+
+// viewer limits the uploads listed to the ones the claims can view, as Claims.CanView does for a single upload.
+func viewer(claims oauth.Claims) *uploadindex.Viewer {
+	if claims.HasRole(oauth.RoleOperator) {
+		return nil
+	}
+	v := &uploadindex.Viewer{Subject: claims.Subject, Issuer: claims.Issuer}
+	if claims.HasRole(oauth.RoleProgramViewer) {
+		v.DataStreams = claims.Values(oauth.DataStreamsClaim)
+	}
+	return v
+}
@@ -13,11 +13,14 @@ import (
 	"time"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/middleware"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/mtls"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/postprocessing"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/statusbus"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/ui"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
@@ -81,6 +84,32 @@ func main() {
 	}
 	defer reports.CloseAll()
 
+	if err := cli.InitUploadIndex(ctx, appConfig); err != nil {
+		slog.Error("error creating upload index", "error", err)
+		os.Exit(appMainExitCode)
+	}
+
+	statusSubscriber, err := cli.InitStatusBus(ctx, appConfig)
+	if err != nil {
+		slog.Error("error creating upload status bus", "error", err)
+		os.Exit(appMainExitCode)
+	}
+	defer statusbus.Default.Remote.Close()
+	if statusSubscriber != nil {
+		if sc, ok := statusSubscriber.(interface {
+			Close() error
+		}); ok {
+			defer sc.Close()
+		}
+		mainWaitGroup.Add(1)
+		go func() {
+			defer mainWaitGroup.Done()
+			if err := statusSubscriber.Listen(ctx, statusbus.Default.Receive); err != nil {
+				slog.Error("upload status listener failed", "error", err)
+			}
+		}()
+	}
+
 	event.InitFileReadyChannel()
 	defer event.CloseFileReadyChannel()
 
@@ -126,12 +155,30 @@ func main() {
 
 	// the user session store should be dependent on if auth is enabled or not.  Shouldn't be able to create, read, or write a store
 	// if auth is disabled.
-	err = middleware.InitStore(*appConfig.OauthConfig)
+	sessionStore, err := cli.InitSessionStore(ctx, appConfig)
+	if err != nil {
+		slog.Error("error starting app, error initialize session store", "error", err)
+		os.Exit(appMainExitCode)
+	}
+	var storeOpts []middleware.StoreOption
+	if sessionStore != nil {
+		storeOpts = append(storeOpts, middleware.WithSessionStore(sessionStore))
+	}
+	err = middleware.InitStore(*appConfig.OauthConfig, storeOpts...)
 	if err != nil {
 		slog.Error("error starting app, error initialize session store", "error", err)
 		os.Exit(appMainExitCode)
 	}
-	authMiddleware, err := middleware.NewAuthMiddleware(ctx, *appConfig.OauthConfig)
+	apiKeys, err := cli.InitAPIKeys(ctx, appConfig)
+	if err != nil {
+		slog.Error("error starting app, error initialize api keys", "error", err)
+		os.Exit(appMainExitCode)
+	}
+	var authOpts []middleware.AuthOption
+	if apiKeys != nil {
+		authOpts = append(authOpts, middleware.WithAPIKeys(apiKeys))
+	}
+	authMiddleware, err := middleware.NewAuthMiddleware(ctx, *appConfig.OauthConfig, authOpts...)
 	if err != nil {
 		slog.Error("error starting app, error initialize auth middleware", "error", err)
 		os.Exit(appMainExitCode)
@@ -160,9 +207,26 @@ func main() {
 
 	} // .httpServer
 
+	if appConfig.TLS != nil {
+		certs, err := mtls.NewReloader(appConfig.TLS.CertFile, appConfig.TLS.KeyFile, appConfig.TLS.ClientCAFile)
+		if err != nil {
+			slog.Error("error starting app, error loading tls certificates", "error", err)
+			os.Exit(appMainExitCode)
+		}
+		health.Register(certs)
+		httpServer.TLSConfig = certs.TLSConfig(appConfig.TLS.ClientAuth)
+		go certs.Watch(ctx, appConfig.TLS.ReloadInterval)
+	}
+
 	mainWaitGroup.Add(1)
 	go func() {
-		err := httpServer.ListenAndServe()
+		var err error
+		if httpServer.TLSConfig != nil {
+			// certificates come from the tls config so they can be reloaded
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("error starting app, error starting http server", "error", err, "port", appConfig.ServerPort)
 			os.Exit(appMainExitCode)
@@ -176,7 +240,7 @@ func main() {
 		mainWaitGroup.Add(1)
 		go func() {
 			defer mainWaitGroup.Done()
-			err := ui.Start(appConfig.UIPort, *appConfig.CSRF, appConfig.ExternalServerFileEndpointUrl, appConfig.InternalServerInfoEndpointUrl, appConfig.InternalServerFileEndpointUrl, authMiddleware)
+			err := ui.Start(appConfig.UIPort, *appConfig.CSRF, appConfig.ExternalServerUrl, appConfig.ExternalServerFileEndpointUrl, appConfig.InternalServerInfoEndpointUrl, appConfig.InternalServerFileEndpointUrl, authMiddleware)
 			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				slog.Error("failed to start ui", "error", err)
 				os.Exit(appMainExitCode)
@@ -0,0 +1,291 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+)
+
+// Prefix marks a bearer token as an API key rather than a JWT.
+const Prefix = "phdo_"
+
+// Issuer is the iss claim of principals authenticated with an API key.
+const Issuer = "api-key"
+
+// MaxActiveKeys is how many unexpired, unrevoked keys an account may hold at once, so that a key can be
+// rotated without downtime.
+const MaxActiveKeys = 2
+
+// TouchInterval limits how often the last used time of a key is written.
+var TouchInterval = time.Minute
+
+var (
+	ErrNotFound      = errors.New("api key not found")
+	ErrInvalidKey    = errors.New("invalid api key")
+	ErrExpired       = errors.New("api key expired")
+	ErrRevoked       = errors.New("api key revoked")
+	ErrTooManyKeys   = errors.New("account already has the maximum number of active api keys")
+	ErrNoAccountName = errors.New("api key account is required")
+	ErrNoDataStreams = errors.New("api key data streams are required, use \"*/*\" for every data stream")
+)
+
+// Key is a stored API key.  Only a hash of the secret is kept.
+type Key struct {
+	ID      string `json:"id"`
+	Account string `json:"account"`
+	Hash    string `json:"hash,omitempty"`
+	// DataStreams limits the key to data streams written as "<data_stream_id>/<data_stream_route>".
+	// Either part may be a glob such as "dextesting/*", and "*/*" allows every data stream.  Keys without any are
+	// refused.
+	DataStreams []string   `json:"data_streams"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	RotatedFrom string     `json:"rotated_from,omitempty"`
+}
+
+func (k *Key) Active(at time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || at.Before(*k.ExpiresAt)
+}
+
+// Claims describes the key as token claims so that keys and JWTs are authorized alike.
+func (k *Key) Claims() oauth.Claims {
+	claims := oauth.Claims{
+		Subject:  k.Account,
+		Issuer:   Issuer,
+		ClientID: k.ID,
+		Method:   oauth.MethodAPIKey,
+		Raw: map[string]any{
+			"sub":       k.Account,
+			"iss":       Issuer,
+			"client_id": k.ID,
+		},
+	}
+	if k.ExpiresAt != nil {
+		claims.Expiry = k.ExpiresAt.Unix()
+	}
+	if len(k.DataStreams) > 0 {
+		streams := make([]any, len(k.DataStreams))
+		for i, s := range k.DataStreams {
+			streams[i] = s
+		}
+		claims.Raw[oauth.DataStreamsClaim] = streams
+	}
+	return claims
+}
+
+// Redacted returns a copy of the key without its hash, for API responses.
+func (k Key) Redacted() Key {
+	k.Hash = ""
+	return k
+}
+
+type Store interface {
+	health.Checkable
+	Get(ctx context.Context, id string) (*Key, error)
+	Put(ctx context.Context, key *Key) error
+	List(ctx context.Context) ([]*Key, error)
+	// Touch records the last time a key was used without rewriting the rest of the key.
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+type Manager struct {
+	Store Store
+}
+
+// Issue creates a key for the account and returns it along with the secret to hand to the sender.  The secret
+// cannot be recovered later.
+func (m *Manager) Issue(ctx context.Context, account string, dataStreams []string, expiresAt *time.Time) (string, *Key, error) {
+	if account == "" {
+		return "", nil, ErrNoAccountName
+	}
+	if len(dataStreams) == 0 {
+		return "", nil, ErrNoDataStreams
+	}
+	now := time.Now().UTC()
+	active, err := m.activeKeys(ctx, account, now)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(active) >= MaxActiveKeys {
+		return "", nil, ErrTooManyKeys
+	}
+	return m.issue(ctx, &Key{
+		Account:     account,
+		DataStreams: dataStreams,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// Rotate issues a replacement for the key with the same account and data streams.  The old key stays valid for
+// the grace period so senders can switch over.
+func (m *Manager) Rotate(ctx context.Context, id string, grace time.Duration, expiresAt *time.Time) (string, *Key, error) {
+	old, err := m.Store.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now().UTC()
+	if !old.Active(now) {
+		return "", nil, errors.Join(ErrInvalidKey, errors.New("only active keys can be rotated"))
+	}
+	active, err := m.activeKeys(ctx, old.Account, now)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(active) >= MaxActiveKeys {
+		return "", nil, ErrTooManyKeys
+	}
+
+	secret, key, err := m.issue(ctx, &Key{
+		Account:     old.Account,
+		DataStreams: old.DataStreams,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+		RotatedFrom: old.ID,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	cutoff := now.Add(grace)
+	if old.ExpiresAt == nil || cutoff.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &cutoff
+	}
+	return secret, key, m.Store.Put(ctx, old)
+}
+
+func (m *Manager) Revoke(ctx context.Context, id string) (*Key, error) {
+	key, err := m.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+	}
+	return key, m.Store.Put(ctx, key)
+}
+
+// List returns the keys of the account, or every key when account is empty.
+func (m *Manager) List(ctx context.Context, account string) ([]*Key, error) {
+	keys, err := m.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if account != "" {
+		keys = slices.DeleteFunc(keys, func(k *Key) bool {
+			return k.Account != account
+		})
+	}
+	slices.SortFunc(keys, func(a, b *Key) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys, nil
+}
+
+// Authenticate returns the key the secret belongs to if it is active.
+func (m *Manager) Authenticate(ctx context.Context, secret string) (*Key, error) {
+	id, raw, ok := parse(secret)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	key, err := m.Store.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(raw)), []byte(key.Hash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if len(key.DataStreams) == 0 {
+		// an empty data_streams claim would allow every data stream
+		return nil, errors.Join(ErrInvalidKey, ErrNoDataStreams)
+	}
+	now := time.Now().UTC()
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	if !key.Active(now) {
+		return nil, ErrExpired
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= TouchInterval {
+		if err := m.Store.Touch(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+func (m *Manager) activeKeys(ctx context.Context, account string, at time.Time) ([]*Key, error) {
+	keys, err := m.List(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(keys, func(k *Key) bool {
+		return !k.Active(at)
+	}), nil
+}
+
+func (m *Manager) issue(ctx context.Context, key *Key) (string, *Key, error) {
+	id, err := randomString(12, hex.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+	raw, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+	key.ID = id
+	key.Hash = hash(raw)
+	if err := m.Store.Put(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return Prefix + id + "_" + raw, key, nil
+}
+
+// IsKey reports whether a bearer token looks like an API key.
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+func parse(secret string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(secret, Prefix)
+	if !ok {
+		return "", "", false
+	}
+	id, raw, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || raw == "" {
+		return "", "", false
+	}
+	return id, raw, true
+}
+
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
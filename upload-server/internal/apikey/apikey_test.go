@@ -0,0 +1,149 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func testStores(t *testing.T) map[string]Store {
+	s := miniredis.RunT(t)
+	redisStore, err := NewRedisStore("redis://" + s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{
+		"file":  &FileStore{Path: filepath.Join(t.TempDir(), "api-keys.json")},
+		"redis": redisStore,
+	}
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := &Manager{Store: store}
+
+			secret, key, err := m.Issue(ctx, "ga-doh", []string{"dextesting/*"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsKey(secret) {
+				t.Errorf("expected issued key %s to have prefix %s", secret, Prefix)
+			}
+
+			authenticated, err := m.Authenticate(ctx, secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if authenticated.ID != key.ID || authenticated.Account != "ga-doh" {
+				t.Errorf("authenticated wrong key %+v", authenticated)
+			}
+			if authenticated.LastUsedAt == nil {
+				t.Error("expected last used time to be recorded")
+			}
+			stored, err := store.Get(ctx, key.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.LastUsedAt == nil {
+				t.Error("expected last used time to be stored")
+			}
+
+			if _, err := m.Authenticate(ctx, secret+"x"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("expected %v for a wrong secret but got %v", ErrInvalidKey, err)
+			}
+			if _, err := m.Authenticate(ctx, Prefix+"unknown_secret"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("expected %v for an unknown key but got %v", ErrInvalidKey, err)
+			}
+
+			if _, err := m.Revoke(ctx, key.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Authenticate(ctx, secret); !errors.Is(err, ErrRevoked) {
+				t.Errorf("expected %v but got %v", ErrRevoked, err)
+			}
+		})
+	}
+}
+
+func TestExpiredKey(t *testing.T) {
+	ctx := context.Background()
+	m := &Manager{Store: &FileStore{Path: filepath.Join(t.TempDir(), "api-keys.json")}}
+	expired := time.Now().Add(-time.Minute)
+	secret, _, err := m.Issue(ctx, "ga-doh", []string{"*/*"}, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(ctx, secret); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected %v but got %v", ErrExpired, err)
+	}
+}
+
+func TestUnscopedKey(t *testing.T) {
+	ctx := context.Background()
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "api-keys.json")}
+	m := &Manager{Store: store}
+	if _, _, err := m.Issue(ctx, "ga-doh", nil, nil); !errors.Is(err, ErrNoDataStreams) {
+		t.Errorf("expected %v for a key without data streams but got %v", ErrNoDataStreams, err)
+	}
+
+	// keys stored without data streams are refused rather than allowed every data stream
+	secret, key, err := m.Issue(ctx, "ga-doh", []string{"*/*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.DataStreams = nil
+	if err := store.Put(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected %v for a stored key without data streams but got %v", ErrInvalidKey, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := &Manager{Store: store}
+
+			oldSecret, old, err := m.Issue(ctx, "ga-doh", []string{"dextesting/testevent1"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newSecret, rotated, err := m.Rotate(ctx, old.ID, time.Hour, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rotated.RotatedFrom != old.ID || rotated.DataStreams[0] != "dextesting/testevent1" {
+				t.Errorf("expected rotated key to replace %s but got %+v", old.ID, rotated)
+			}
+
+			// both keys are valid during the grace period
+			for _, s := range []string{oldSecret, newSecret} {
+				if _, err := m.Authenticate(ctx, s); err != nil {
+					t.Errorf("expected key to be valid during rotation but got %v", err)
+				}
+			}
+			stored, err := store.Get(ctx, old.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.ExpiresAt == nil || stored.ExpiresAt.After(time.Now().Add(time.Hour)) {
+				t.Errorf("expected old key to expire after the grace period but got %v", stored.ExpiresAt)
+			}
+
+			if _, _, err := m.Issue(ctx, "ga-doh", []string{"*/*"}, nil); !errors.Is(err, ErrTooManyKeys) {
+				t.Errorf("expected %v for a third active key but got %v", ErrTooManyKeys, err)
+			}
+			if _, _, err := m.Issue(ctx, "fl-doh", []string{"*/*"}, nil); err != nil {
+				t.Errorf("expected other accounts to be unaffected but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// FileStore keeps every key in a single JSON file.  It is meant for local development and single instance
+// deployments.
+type FileStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+func (s *FileStore) Put(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	keys[key.ID] = key
+	return s.write(keys)
+}
+
+func (s *FileStore) List(_ context.Context) ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Key, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k)
+	}
+	return list, nil
+}
+
+func (s *FileStore) Touch(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	key, ok := keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	key.LastUsedAt = &at
+	return s.write(keys)
+}
+
+func (s *FileStore) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "API key file store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.read(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
+
+func (s *FileStore) read() (map[string]*Key, error) {
+	keys := map[string]*Key{}
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return keys, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// write replaces the file atomically so a crash never leaves a partial key file behind.
+func (s *FileStore) write(keys map[string]*Key) error {
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
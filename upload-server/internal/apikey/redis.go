@@ -0,0 +1,111 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "apikey:"
+	redisIndexKey  = "apikeys"
+)
+
+// RedisStore keeps each key as JSON with its last used time alongside, so that touching a key can never
+// overwrite a concurrent revocation.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+func NewRedisStore(uri string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &RedisStore{Client: client}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Key, error) {
+	values, err := s.Client.HMGet(ctx, redisKeyPrefix+id, "key", "last_used").Result()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key := &Key{}
+	if err := json.Unmarshal([]byte(data), key); err != nil {
+		return nil, err
+	}
+	if lastUsed, ok := values[1].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, lastUsed); err == nil {
+			key.LastUsedAt = &t
+		}
+	}
+	return key, nil
+}
+
+func (s *RedisStore) Put(ctx context.Context, key *Key) error {
+	stored := *key
+	stored.LastUsedAt = nil
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, redisKeyPrefix+key.ID, "key", data)
+		p.SAdd(ctx, redisIndexKey, key.ID)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]*Key, error) {
+	ids, err := s.Client.SMembers(ctx, redisIndexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Key, 0, len(ids))
+	for _, id := range ids {
+		key, err := s.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *RedisStore) Touch(ctx context.Context, id string, at time.Time) error {
+	exists, err := s.Client.Exists(ctx, redisKeyPrefix+id).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return s.Client.HSet(ctx, redisKeyPrefix+id, "last_used", at.Format(time.RFC3339Nano)).Err()
+}
+
+func (s *RedisStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "API key redis store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.Client.Ping(ctx).Err(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
@@ -0,0 +1,125 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS api_keys (
+	id TEXT PRIMARY KEY,
+	account TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	data_streams TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	rotated_from TEXT NOT NULL DEFAULT ''
+)`
+
+const selectKeys = `SELECT id, account, hash, data_streams, created_at, expires_at, revoked_at, last_used_at, rotated_from FROM api_keys`
+
+// SQLStore keeps keys in a Postgres api_keys table, which is created if it does not exist.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+func NewSQLStore(ctx context.Context, connection string) (*SQLStore, error) {
+	db, err := sql.Open("pgx", connection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLStore{DB: db}, nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (*Key, error) {
+	key, err := scanKey(s.DB.QueryRowContext(ctx, selectKeys+` WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// Put writes every column but last_used_at, which only Touch updates.
+func (s *SQLStore) Put(ctx context.Context, key *Key) error {
+	streams, err := json.Marshal(key.DataStreams)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, `INSERT INTO api_keys (id, account, hash, data_streams, created_at, expires_at, revoked_at, rotated_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET account = $2, hash = $3, data_streams = $4, created_at = $5, expires_at = $6, revoked_at = $7, rotated_from = $8`,
+		key.ID, key.Account, key.Hash, string(streams), key.CreatedAt, key.ExpiresAt, key.RevokedAt, key.RotatedFrom)
+	return err
+}
+
+func (s *SQLStore) List(ctx context.Context) ([]*Key, error) {
+	rows, err := s.DB.QueryContext(ctx, selectKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []*Key
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLStore) Touch(ctx context.Context, id string, at time.Time) error {
+	res, err := s.DB.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "API key sql store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.DB.PingContext(ctx); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
+
+func scanKey(row interface{ Scan(...any) error }) (*Key, error) {
+	key := &Key{}
+	var streams string
+	var expires, revoked, lastUsed sql.NullTime
+	if err := row.Scan(&key.ID, &key.Account, &key.Hash, &streams, &key.CreatedAt, &expires, &revoked, &lastUsed, &key.RotatedFrom); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(streams), &key.DataStreams); err != nil {
+		return nil, err
+	}
+	if expires.Valid {
+		key.ExpiresAt = &expires.Time
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	return key, nil
+}
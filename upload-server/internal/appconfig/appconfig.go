@@ -55,9 +55,14 @@ type AppConfig struct {
 	ExternalServerFileEndpointUrl string
 	ExternalServerInfoEndpointUrl string
 	Metrics                       MetricsConfig `env:", prefix=METRICS_"`
+	TLS                           *TLSConfig    `env:", prefix=TLS_, noinit"`
 
 	// TUSD
 	TusUploadPrefix string `env:"TUS_UPLOAD_PREFIX, default=tus-prefix"`
+	// TusdMaxSize limits every upload, in bytes, when it is set.  Data streams can set a lower limit.
+	TusdMaxSize int64 `env:"TUSD_MAX_SIZE"`
+	// PartialUploadTTL is how long the partial uploads of a concatenation are kept after they last received data.
+	PartialUploadTTL time.Duration `env:"PARTIAL_UPLOAD_TTL, default=24h"`
 
 	// User Interface Configs
 	UIPort                   string `env:"UI_PORT, default=8081"`
@@ -71,13 +76,40 @@ type AppConfig struct {
 
 	// TUS Upload file lock
 	TusRedisLockURI string `env:"REDIS_CONNECTION_STRING"`
+	// TusSQLLockURI is used instead of Redis for the lock when it is set.
+	TusSQLLockURI string `env:"LOCK_SQL_CONNECTION_STRING"`
 
 	// OAuth Configs
 	OauthConfig *OauthConfig `env:", prefix=OAUTH_"`
 
+	// API Key Configs
+	APIKeyConfig *APIKeyConfig `env:", prefix=API_KEYS_, noinit"`
+
+	// Rate Limit Configs
+	RateLimit *RateLimitConfig `env:", prefix=RATE_LIMIT_, noinit"`
+
+	// Upload Index Configs
+	UploadIndex UploadIndexConfig `env:", prefix=UPLOAD_INDEX_"`
+
+	// Duplicate Detection Configs
+	Duplicates DuplicatesConfig `env:", prefix=DUPLICATES_"`
+
+	// Malware Scan Configs
+	MalwareScan *MalwareScanConfig `env:", prefix=MALWARE_SCAN_, noinit"`
+
+	// How long the urls handed out to download an upload straight from storage are valid
+	DownloadURLExpiry time.Duration `env:"DOWNLOAD_URL_EXPIRY, default=5m"`
+
+	// Origins of the browser clients, such as the UI, that may listen to upload status events with the user's session
+	StatusEventsAllowedOrigins []string `env:"STATUS_EVENTS_ALLOWED_ORIGINS, default=http://localhost:8081"`
+
 	// process status health
 	ProcessingStatusHealthURI string `env:"PROCESSING_STATUS_HEALTH_URI"`
 
+	// Health checks run in parallel, each with the timeout, and their results are reused for the cache ttl
+	HealthCheckTimeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT, default=5s"`
+	HealthCheckCacheTTL time.Duration `env:"HEALTH_CHECK_CACHE_TTL, default=10s"`
+
 	// Local File System Configs
 	LocalFolderUploadsTus string `env:"LOCAL_FOLDER_UPLOADS_TUS, default=./uploads"`
 	UploadConfigPath      string `env:"UPLOAD_CONFIG_PATH, default=../upload-configs"`
@@ -102,11 +134,23 @@ type AppConfig struct {
 	SNSPublisherConnection  *SNSConfig `env:", prefix=SNS_PUBLISHER_,noinit"`
 	SQSSubscriberConnection *SQSConfig `env:", prefix=SQS_SUBSCRIBER_,noinit"`
 
+	// Upload status updates are shared between instances through a topic, and every instance needs its own
+	// subscription to it
+	StatusPublisherConnection     *AzureQueueConfig `env:", prefix=STATUS_PUBLISHER_, noinit"`
+	StatusSubscriberConnection    *AzureQueueConfig `env:", prefix=STATUS_SUBSCRIBER_, noinit"`
+	SNSStatusPublisherConnection  *SNSConfig        `env:", prefix=SNS_STATUS_PUBLISHER_, noinit"`
+	SQSStatusSubscriberConnection *SQSConfig        `env:", prefix=SQS_STATUS_SUBSCRIBER_, noinit"`
+
 	// S3 Storage Configs
 	S3Connection           *S3StorageConfig `env:", prefix=S3_, noinit"`
 	S3ManifestConfigBucket string           `env:"DEX_MANIFEST_CONFIG_BUCKET_NAME"`
 	S3ManifestConfigFolder string           `env:"DEX_S3_MANIFEST_CONFIG_FOLDER_NAME"`
 
+	// GCS Storage Configs
+	GCSConnection           *GCSStorageConfig `env:", prefix=GCS_, noinit"`
+	GCSManifestConfigBucket string            `env:"DEX_GCS_MANIFEST_CONFIG_BUCKET_NAME"`
+	GCSManifestConfigFolder string            `env:"DEX_GCS_MANIFEST_CONFIG_FOLDER_NAME"`
+
 	DeliveryConfigFile string `env:"DEX_DELIVERY_CONFIG_FILE, default=./configs/local/deliver.yml"`
 	ListenerWorkers    int    `env:"LISTENER_WORKERS, default=5"`
 } // .AppConfig
@@ -171,6 +215,12 @@ type S3StorageConfig struct {
 	BucketName string `env:"BUCKET_NAME"`
 }
 
+type GCSStorageConfig struct {
+	Endpoint        string `env:"ENDPOINT"`
+	BucketName      string `env:"BUCKET_NAME"`
+	CredentialsFile string `env:"CREDENTIALS_FILE"`
+}
+
 type AzureContainerConfig struct {
 	AzureStorageConfig
 	ContainerName string
@@ -198,11 +248,93 @@ type OauthConfig struct {
 	SessionKey       string `env:"SESSION_KEY"`
 	SessionSecure    bool   `env:"SESSION_SECURE, default=true"`
 	SessionDomain    string `env:"SESSION_DOMAIN"`
+	// Sessions are kept in memory unless a file, redis, or sql store is given.  The redis store falls back to
+	// REDIS_CONNECTION_STRING.
+	SessionFile                  string        `env:"SESSION_FILE"`
+	SessionRedisConnectionString string        `env:"SESSION_REDIS_CONNECTION_STRING"`
+	SessionSQLConnectionString   string        `env:"SESSION_SQL_CONNECTION_STRING"`
+	SessionIdleTimeout           time.Duration `env:"SESSION_IDLE_TIMEOUT, default=30m"`
+	SessionAbsoluteTimeout       time.Duration `env:"SESSION_ABSOLUTE_TIMEOUT, default=12h"`
+	AdminScope                   string        `env:"ADMIN_SCOPE, default=dex:admin"`
+	// Setting a client id signs users in to the UI with the authorization code flow instead of a pasted token.
+	ClientID              string `env:"CLIENT_ID"`
+	ClientSecret          string `env:"CLIENT_SECRET"`
+	RedirectURL           string `env:"REDIRECT_URL"`
+	PostLogoutRedirectURL string `env:"POST_LOGOUT_REDIRECT_URL"`
+	LoginScopes           string `env:"LOGIN_SCOPES, default=openid profile email offline_access"`
+	// Values of the roles claim that grant each role, space separated.  Every authenticated user is a sender.
+	RolesClaim         string `env:"ROLES_CLAIM, default=roles"`
+	AdminRoles         string `env:"ADMIN_ROLES, default=admin"`
+	OperatorRoles      string `env:"OPERATOR_ROLES, default=operator"`
+	ProgramViewerRoles string `env:"PROGRAM_VIEWER_ROLES, default=program_viewer"`
+}
+
+// Optional config structs leave defaults out of their env tags, since envconfig initializes a noinit
+// struct when any of its fields has a default.  Defaults are set in ParseConfig instead.
+
+type TLSConfig struct {
+	CertFile       string        `env:"CERT_FILE"`
+	KeyFile        string        `env:"KEY_FILE"`
+	ClientCAFile   string        `env:"CLIENT_CA_FILE"`
+	ClientAuth     string        `env:"CLIENT_AUTH"`
+	ReloadInterval time.Duration `env:"RELOAD_INTERVAL"`
+}
+
+type APIKeyConfig struct {
+	File                  string        `env:"FILE"`
+	RedisConnectionString string        `env:"REDIS_CONNECTION_STRING"`
+	SQLConnectionString   string        `env:"SQL_CONNECTION_STRING"`
+	RotationGracePeriod   time.Duration `env:"ROTATION_GRACE_PERIOD"`
+}
+
+// UploadIndexConfig picks the store of the upload index, which is kept in memory unless a file, redis, or sql
+// store is given.
+type UploadIndexConfig struct {
+	File                  string `env:"FILE"`
+	RedisConnectionString string `env:"REDIS_CONNECTION_STRING"`
+	SQLConnectionString   string `env:"SQL_CONNECTION_STRING"`
+
+	// MemoryMaxEntries and MemoryTTL bound the uploads kept in memory, the oldest are forgotten first.
+	MemoryMaxEntries int           `env:"MEMORY_MAX_ENTRIES, default=10000"`
+	MemoryTTL        time.Duration `env:"MEMORY_TTL, default=168h"`
+}
+
+type DuplicatesConfig struct {
+	// RedisConnectionString shares upload hashes between instances, falling back to REDIS_CONNECTION_STRING.
+	RedisConnectionString string `env:"REDIS_CONNECTION_STRING"`
+	// StateTTL is how long the hash of an upload that has stopped receiving chunks is kept.
+	StateTTL time.Duration `env:"STATE_TTL, default=168h"`
+}
+
+type MalwareScanConfig struct {
+	// ClamdAddress is a tcp://host:port or unix:///path/to/clamd.sock address of a clamd daemon.
+	ClamdAddress string        `env:"CLAMD_ADDRESS"`
+	Timeout      time.Duration `env:"TIMEOUT"`
+}
+
+type RateLimitConfig struct {
+	// RedisConnectionString shares limits between instances, falling back to REDIS_CONNECTION_STRING.
+	RedisConnectionString string        `env:"REDIS_CONNECTION_STRING"`
+	ActiveUploadTTL       time.Duration `env:"ACTIVE_UPLOAD_TTL"`
+
+	PrincipalRequestsPerSecond    float64 `env:"PRINCIPAL_REQUESTS_PER_SECOND"`
+	PrincipalBurst                int     `env:"PRINCIPAL_BURST"`
+	PrincipalMaxConcurrentUploads int64   `env:"PRINCIPAL_MAX_CONCURRENT_UPLOADS"`
+	PrincipalMaxBytesPerDay       int64   `env:"PRINCIPAL_MAX_BYTES_PER_DAY"`
+	PrincipalMaxUploadSize        int64   `env:"PRINCIPAL_MAX_UPLOAD_SIZE"`
+
+	DataStreamRequestsPerSecond    float64 `env:"DATA_STREAM_REQUESTS_PER_SECOND"`
+	DataStreamBurst                int     `env:"DATA_STREAM_BURST"`
+	DataStreamMaxConcurrentUploads int64   `env:"DATA_STREAM_MAX_CONCURRENT_UPLOADS"`
+	DataStreamMaxBytesPerDay       int64   `env:"DATA_STREAM_MAX_BYTES_PER_DAY"`
+	DataStreamMaxUploadSize        int64   `env:"DATA_STREAM_MAX_UPLOAD_SIZE"`
 }
 
 type CSRFConfig struct {
 	Token          string `env:"TOKEN, default=1qQBJumxRABFBLvaz5PSXBcXLE84viE42x4Aev359DvLSvzjbXSme3whhFkESatW"`
 	TrustedOrigins string `env:"TRUSTED_ORIGINS, default=localhost:8081"`
+	// Secure is set when the UI is served over https so the CSRF cookie is only sent over TLS.
+	Secure bool
 }
 
 func (azc *AzureStorageConfig) Check() error {
@@ -250,6 +382,64 @@ func ParseConfig(ctx context.Context) (AppConfig, error) {
 		}
 	}
 
+	if ac.GCSConnection != nil {
+		if ac.GCSConnection.BucketName == "" {
+			return AppConfig{}, fmt.Errorf("missing required values for connecting to GCS")
+		}
+	}
+
+	if ac.TLS != nil {
+		if ac.TLS.CertFile == "" || ac.TLS.KeyFile == "" {
+			return AppConfig{}, fmt.Errorf("missing required cert and key files for serving tls")
+		}
+		if ac.TLS.ClientAuth == "" {
+			ac.TLS.ClientAuth = "none"
+		}
+		if ac.TLS.ReloadInterval == 0 {
+			ac.TLS.ReloadInterval = time.Minute
+		}
+		switch ac.TLS.ClientAuth {
+		case "none":
+		case "optional", "require":
+			if ac.TLS.ClientCAFile == "" {
+				return AppConfig{}, fmt.Errorf("missing client ca file for tls client auth %s", ac.TLS.ClientAuth)
+			}
+		default:
+			return AppConfig{}, fmt.Errorf("unknown tls client auth %s, expected none, optional, or require", ac.TLS.ClientAuth)
+		}
+	}
+
+	if ac.APIKeyConfig != nil {
+		if ac.APIKeyConfig.File == "" && ac.APIKeyConfig.RedisConnectionString == "" && ac.APIKeyConfig.SQLConnectionString == "" {
+			return AppConfig{}, fmt.Errorf("missing a file, redis, or sql store for api keys")
+		}
+		if ac.APIKeyConfig.RotationGracePeriod == 0 {
+			ac.APIKeyConfig.RotationGracePeriod = 24 * time.Hour
+		}
+	}
+
+	if ac.MalwareScan != nil && ac.MalwareScan.Timeout == 0 {
+		ac.MalwareScan.Timeout = 5 * time.Minute
+	}
+
+	if ac.OauthConfig.ClientID != "" && ac.OauthConfig.RedirectURL == "" {
+		return AppConfig{}, fmt.Errorf("missing redirect url for oauth client %s", ac.OauthConfig.ClientID)
+	}
+
+	if ac.OauthConfig.SessionRedisConnectionString == "" {
+		ac.OauthConfig.SessionRedisConnectionString = ac.TusRedisLockURI
+	}
+
+	if ac.Duplicates.RedisConnectionString == "" {
+		ac.Duplicates.RedisConnectionString = ac.TusRedisLockURI
+	}
+
+	if ac.RateLimit != nil && ac.RateLimit.RedisConnectionString == "" {
+		ac.RateLimit.RedisConnectionString = ac.TusRedisLockURI
+	}
+
+	ac.CSRF.Secure = ac.UIServerExternalProtocol == "https"
+
 	ac.InternalServerUrl = fmt.Sprintf("%s://%s", ac.UIServerInternalProtocol, ac.UIServerInternalHost)
 	ac.ExternalServerUrl = fmt.Sprintf("%s://%s", ac.UIServerExternalProtocol, ac.UIServerExternalHost)
 	ac.ExternalServerFileEndpointUrl = ac.ExternalServerUrl + ac.TusdHandlerBasePath
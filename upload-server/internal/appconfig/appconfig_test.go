@@ -0,0 +1,49 @@
+package appconfig
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearenv empties the environment for the test and restores it after.
+func clearenv(t *testing.T) {
+	environ := os.Environ()
+	os.Clearenv()
+	t.Cleanup(func() {
+		os.Clearenv()
+		for _, kv := range environ {
+			k, v, _ := strings.Cut(kv, "=")
+			os.Setenv(k, v)
+		}
+	})
+}
+
+func TestParseConfigEmptyEnvironment(t *testing.T) {
+	clearenv(t)
+	ac, err := ParseConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac.TLS != nil || ac.APIKeyConfig != nil || ac.RateLimit != nil || ac.MalwareScan != nil {
+		t.Errorf("expected optional configs to be left out, got tls %+v, api keys %+v, rate limit %+v, malware scan %+v", ac.TLS, ac.APIKeyConfig, ac.RateLimit, ac.MalwareScan)
+	}
+}
+
+func TestParseConfigOptionalDefaults(t *testing.T) {
+	clearenv(t)
+	t.Setenv("MALWARE_SCAN_CLAMD_ADDRESS", "tcp://localhost:3310")
+	t.Setenv("API_KEYS_FILE", "keys.json")
+	ac, err := ParseConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac.MalwareScan == nil || ac.MalwareScan.Timeout != 5*time.Minute {
+		t.Errorf("expected the default malware scan timeout, got %+v", ac.MalwareScan)
+	}
+	if ac.APIKeyConfig == nil || ac.APIKeyConfig.RotationGracePeriod != 24*time.Hour {
+		t.Errorf("expected the default api key rotation grace period, got %+v", ac.APIKeyConfig)
+	}
+}
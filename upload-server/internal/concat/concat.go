@@ -0,0 +1,54 @@
+package concat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+// ChunkSize is the most data written to the final upload in one chunk, which stores that buffer chunks, such as
+// the Azure store, hold on disk.
+var ChunkSize int64 = 64 << 20
+
+// ByCopy concatenates the partial uploads into the final upload for stores without a native way to do it.  The
+// partial uploads are read back and written to the final upload in order, and then the final upload is finished,
+// since tusd does not finish final uploads itself.
+func ByCopy(ctx context.Context, final handler.Upload, partials []handler.Upload) error {
+	var offset int64
+	for _, p := range partials {
+		r, err := p.GetReader(ctx)
+		if err != nil {
+			return err
+		}
+		n, err := copyChunks(ctx, final, offset, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		offset += n
+	}
+	return final.FinishUpload(ctx)
+}
+
+func copyChunks(ctx context.Context, final handler.Upload, offset int64, r io.Reader) (int64, error) {
+	var written int64
+	for {
+		// peek so the end of the partial upload does not write an empty chunk
+		first := make([]byte, 1)
+		if _, err := io.ReadFull(r, first); err != nil {
+			if errors.Is(err, io.EOF) {
+				return written, nil
+			}
+			return written, err
+		}
+		chunk := io.MultiReader(bytes.NewReader(first), io.LimitReader(r, ChunkSize-1))
+		n, err := final.WriteChunk(ctx, offset+written, chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+}
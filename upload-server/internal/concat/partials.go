@@ -0,0 +1,310 @@
+package concat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+// DefaultPartialUploadTTL bounds how long a partial upload that is never concatenated is kept after it last received
+// data.
+const DefaultPartialUploadTTL = 24 * time.Hour
+
+// lockTimeout is how long to wait for the lock of a partial upload before deleting it, the same as tusd.
+const lockTimeout = 20 * time.Second
+
+var ErrNotOwner = errors.New("partial upload was created by another sender")
+
+// Partials carry no manifest, so they are tied to the principal that created them instead.  Only that principal may
+// concatenate them, and they are deleted once they are concatenated or once TTL passes without them receiving any
+// data.
+type Partials struct {
+	Store      handler.DataStore
+	Terminater handler.TerminaterDataStore
+	// Locker keeps partial uploads from being deleted while tusd is writing to them.
+	Locker handler.Locker
+	// Lister finds the partial uploads to sweep.  Without it only the partial uploads this instance created since it
+	// started are swept.
+	Lister Lister
+	// PostTerminate are run for the partial uploads deleted here, like the post-terminate hooks tusd runs for the
+	// uploads it terminates.
+	PostTerminate []func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error)
+	TTL           time.Duration
+
+	mux sync.Mutex
+	// seen holds the offset of each partial upload and when it was first seen at that offset.
+	seen map[string]activity
+}
+
+type activity struct {
+	offset int64
+	at     time.Time
+}
+
+// Lister is implemented by stores that can list the ids of their uploads.
+type Lister interface {
+	ListUploads(ctx context.Context) ([]string, error)
+}
+
+// FileLister lists the uploads of a tus filestore from their info files.
+type FileLister struct {
+	Path string
+}
+
+func (l FileLister) ListUploads(_ context.Context) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(l.Path, "*.info"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, strings.TrimSuffix(filepath.Base(m), ".info"))
+	}
+	return ids, nil
+}
+
+func (p *Partials) ttl() time.Duration {
+	if p.TTL > 0 {
+		return p.TTL
+	}
+	return DefaultPartialUploadTTL
+}
+
+// Record is a pre-create hook that records the authenticated principal on partial uploads, dropping any principal a
+// sender supplied in Upload-Metadata.
+func (p *Partials) Record(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	if !event.Upload.IsPartial {
+		return resp, nil
+	}
+	meta := maps.Clone(event.Upload.MetaData)
+	if resp.ChangeFileInfo.MetaData != nil {
+		meta = resp.ChangeFileInfo.MetaData
+	}
+	if meta == nil {
+		meta = handler.MetaData{}
+	}
+	for _, key := range metadata.PrincipalKeys {
+		delete(meta, key)
+	}
+	if claims, ok := oauth.FromContext(event.Context); ok && claims.Method != "" {
+		maps.Copy(meta, claims.Principal().Fields())
+	}
+	resp.ChangeFileInfo.MetaData = meta
+	return resp, nil
+}
+
+// Authorize is a pre-create hook that rejects final uploads with 403 when any of their partial uploads was created
+// by another principal.  Requests that were not authenticated carry no claims and are not checked.
+func (p *Partials) Authorize(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	if !event.Upload.IsFinal {
+		return resp, nil
+	}
+	claims, ok := oauth.FromContext(event.Context)
+	if !ok {
+		return resp, nil
+	}
+	sender := claims.Principal()
+	for _, id := range event.Upload.PartialUploads {
+		upload, err := p.Store.GetUpload(event.Context, id)
+		if err != nil {
+			return resp, err
+		}
+		info, err := upload.GetInfo(event.Context)
+		if err != nil {
+			return resp, err
+		}
+		if owner := metadata.GetPrincipal(info.MetaData); owner == nil || *owner != *sender {
+			sloger.FromContext(event.Context).Warn("sender not authorized for partial upload", "partial_upload", id, "subject", claims.Subject)
+			resp.RejectUpload = true
+			resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       fmt.Sprintf("%s: %s\n", ErrNotOwner, id),
+			})
+			return resp, nil
+		}
+	}
+	return resp, nil
+}
+
+// Track is a post-create hook that starts the TTL of partial uploads.
+func (p *Partials) Track(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	if !event.Upload.IsPartial {
+		return resp, nil
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.seen == nil {
+		p.seen = map[string]activity{}
+	}
+	p.seen[event.Upload.ID] = activity{offset: event.Upload.Offset, at: time.Now()}
+	return resp, nil
+}
+
+// Remove is a post-finish hook that deletes the partial uploads of a final upload once they are concatenated, and a
+// post-terminate hook that stops tracking terminated partial uploads.
+func (p *Partials) Remove(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	if event.Upload.IsPartial {
+		p.forget(event.Upload.ID)
+		return resp, nil
+	}
+	for _, id := range event.Upload.PartialUploads {
+		if err := p.terminate(event.Context, id); err != nil {
+			sloger.FromContext(event.Context).Error("failed to delete concatenated partial upload", "partial_upload", id, "error", err)
+		}
+	}
+	return resp, nil
+}
+
+func (p *Partials) forget(id string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	delete(p.seen, id)
+}
+
+// terminate deletes the partial upload while holding its lock, and then runs the post-terminate hooks for it.
+func (p *Partials) terminate(ctx context.Context, id string) error {
+	if p.Locker != nil {
+		lock, err := p.Locker.NewLock(id)
+		if err != nil {
+			return err
+		}
+		// the partial upload is only deleted when it is not in use, so the lock is not given up when asked for
+		lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+		err = lock.Lock(lockCtx, func() {})
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+	upload, err := p.Store.GetUpload(ctx, id)
+	if errors.Is(err, handler.ErrNotFound) {
+		p.forget(id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if err := p.Terminater.AsTerminatableUpload(upload).Terminate(ctx); err != nil {
+		return err
+	}
+	p.forget(id)
+
+	event := &handler.HookEvent{Context: ctx, Upload: info}
+	for _, hook := range p.PostTerminate {
+		if _, err := hook(event, hooks.HookResponse{}); err != nil {
+			sloger.FromContext(ctx).Error("failed to clean up after deleting partial upload", "partial_upload", id, "error", err)
+		}
+	}
+	return nil
+}
+
+// ids returns the uploads to sweep, which are every upload in the store when it can be listed.
+func (p *Partials) ids(ctx context.Context) ([]string, error) {
+	if p.Lister != nil {
+		return p.Lister.ListUploads(ctx)
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return slices.Collect(maps.Keys(p.seen)), nil
+}
+
+// Sweep deletes the partial uploads that have not received any data within TTL.  Their offsets are read from the
+// store, so partial uploads still being written to through another instance are kept, and partial uploads first
+// seen after a restart get a full TTL from then.
+func (p *Partials) Sweep(ctx context.Context) {
+	start := time.Now()
+	ids, err := p.ids(ctx)
+	if err != nil {
+		slog.Error("failed to list partial uploads", "error", err)
+		return
+	}
+	expired := []string{}
+	for _, id := range ids {
+		upload, err := p.Store.GetUpload(ctx, id)
+		if errors.Is(err, handler.ErrNotFound) {
+			p.forget(id)
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to get partial upload", "partial_upload", id, "error", err)
+			continue
+		}
+		info, err := upload.GetInfo(ctx)
+		if err != nil {
+			slog.Error("failed to get partial upload", "partial_upload", id, "error", err)
+			continue
+		}
+		if !info.IsPartial {
+			continue
+		}
+		if p.expired(id, info.Offset, start) {
+			expired = append(expired, id)
+		}
+	}
+	if p.Lister != nil {
+		// forget the partial uploads that are gone, unless they were created after the listing
+		p.mux.Lock()
+		for id, a := range p.seen {
+			if a.at.Before(start) && !slices.Contains(ids, id) {
+				delete(p.seen, id)
+			}
+		}
+		p.mux.Unlock()
+	}
+
+	for _, id := range expired {
+		if err := p.terminate(ctx, id); err != nil {
+			slog.Error("failed to delete expired partial upload", "partial_upload", id, "error", err)
+			continue
+		}
+		slog.Info("deleted partial upload that was never concatenated", "partial_upload", id)
+	}
+}
+
+// expired records the offset of the partial upload and reports whether it has stayed at that offset for TTL.
+func (p *Partials) expired(id string, offset int64, now time.Time) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.seen == nil {
+		p.seen = map[string]activity{}
+	}
+	a, ok := p.seen[id]
+	if !ok || a.offset != offset {
+		a = activity{offset: offset, at: now}
+		p.seen[id] = a
+	}
+	return now.Sub(a.at) > p.ttl()
+}
+
+// Watch sweeps the partial uploads every interval until the context is done.
+func (p *Partials) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Sweep(ctx)
+		}
+	}
+}
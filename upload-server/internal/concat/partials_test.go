@@ -0,0 +1,200 @@
+package concat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+	"github.com/tus/tusd/v2/pkg/filestore"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+	"github.com/tus/tusd/v2/pkg/memorylocker"
+)
+
+func newPartials(t *testing.T) *Partials {
+	store := filestore.New(t.TempDir())
+	return &Partials{Store: store, Terminater: store, Locker: memorylocker.New(), TTL: time.Hour}
+}
+
+func sender(subject string) context.Context {
+	return oauth.NewContext(context.Background(), oauth.Claims{Subject: subject, Issuer: "test", Method: oauth.MethodJWT})
+}
+
+// createPartial creates a partial upload the way tusd does, running the pre-create and post-create hooks.
+func createPartial(t *testing.T, p *Partials, ctx context.Context, meta handler.MetaData) string {
+	t.Helper()
+	event := &handler.HookEvent{Context: ctx, Upload: handler.FileInfo{IsPartial: true, Size: 1, MetaData: meta}}
+	resp, err := p.Record(event, hooks.HookResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := p.Store.NewUpload(ctx, handler.FileInfo{IsPartial: true, Size: 1, MetaData: resp.ChangeFileInfo.MetaData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Track(&handler.HookEvent{Context: ctx, Upload: info}, hooks.HookResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	return info.ID
+}
+
+func exists(t *testing.T, p *Partials, id string) bool {
+	t.Helper()
+	_, err := p.Store.GetUpload(context.Background(), id)
+	if errors.Is(err, handler.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
+
+func TestRecord(t *testing.T) {
+	p := newPartials(t)
+	id := createPartial(t, p, sender("alice"), handler.MetaData{metadata.PrincipalSubjectKey: "mallory"})
+	upload, err := p.Store.GetUpload(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := upload.GetInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal := metadata.GetPrincipal(info.MetaData); principal == nil || principal.Subject != "alice" {
+		t.Errorf("expected the authenticated sender to be recorded but got %+v", principal)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	p := newPartials(t)
+	ids := []string{
+		createPartial(t, p, sender("alice"), nil),
+		createPartial(t, p, sender("alice"), nil),
+	}
+
+	authorize := func(ctx context.Context) hooks.HookResponse {
+		event := &handler.HookEvent{Context: ctx, Upload: handler.FileInfo{IsFinal: true, PartialUploads: ids}}
+		resp, err := p.Authorize(event, hooks.HookResponse{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := authorize(sender("alice")); resp.RejectUpload {
+		t.Errorf("expected the sender to concatenate their own partial uploads but got %+v", resp.HTTPResponse)
+	}
+	if resp := authorize(sender("bob")); !resp.RejectUpload || resp.HTTPResponse.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for another sender's partial uploads but got %+v", resp)
+	}
+	if resp := authorize(context.Background()); resp.RejectUpload {
+		t.Errorf("expected requests that were not authenticated to not be checked but got %+v", resp.HTTPResponse)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	p := newPartials(t)
+	ids := []string{createPartial(t, p, sender("alice"), nil), createPartial(t, p, sender("alice"), nil)}
+	event := &handler.HookEvent{Context: context.Background(), Upload: handler.FileInfo{IsFinal: true, PartialUploads: ids}}
+	if _, err := p.Remove(event, hooks.HookResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if exists(t, p, id) {
+			t.Errorf("expected partial upload %s to be deleted once it was concatenated", id)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	p := newPartials(t)
+	terminated := []string{}
+	p.PostTerminate = append(p.PostTerminate, func(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+		terminated = append(terminated, event.Upload.ID)
+		return resp, nil
+	})
+	expired := createPartial(t, p, sender("alice"), nil)
+	kept := createPartial(t, p, sender("alice"), nil)
+	p.seen[expired] = activity{at: time.Now().Add(-2 * time.Hour)}
+
+	p.Sweep(context.Background())
+	if exists(t, p, expired) {
+		t.Error("expected the partial upload that was never concatenated to be deleted")
+	}
+	if !exists(t, p, kept) {
+		t.Error("expected the partial upload within its TTL to be kept")
+	}
+	if len(terminated) != 1 || terminated[0] != expired {
+		t.Errorf("expected the post-terminate hooks to run for the deleted partial upload, got %v", terminated)
+	}
+}
+
+// tests that partial uploads created before a restart or by another instance are swept once they stop receiving data
+func TestSweepListed(t *testing.T) {
+	dir := t.TempDir()
+	created := &Partials{Store: filestore.New(dir), Terminater: filestore.New(dir), TTL: time.Hour}
+	idle := createPartial(t, created, sender("alice"), nil)
+	receiving := createPartial(t, created, sender("alice"), nil)
+
+	p := &Partials{Store: filestore.New(dir), Terminater: filestore.New(dir), Lister: FileLister{Path: dir}, TTL: time.Hour}
+	p.Sweep(context.Background())
+	if !exists(t, p, idle) || !exists(t, p, receiving) {
+		t.Fatal("expected partial uploads first seen by the sweep to get a full TTL")
+	}
+
+	for id := range p.seen {
+		p.seen[id] = activity{at: time.Now().Add(-2 * time.Hour)}
+	}
+	upload, err := p.Store.GetUpload(context.Background(), receiving)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload.WriteChunk(context.Background(), 0, strings.NewReader("a")); err != nil {
+		t.Fatal(err)
+	}
+	p.Sweep(context.Background())
+	if exists(t, p, idle) {
+		t.Error("expected the partial upload that stopped receiving data to be deleted")
+	}
+	if !exists(t, p, receiving) {
+		t.Error("expected the partial upload that received data to be kept")
+	}
+}
+
+// tests that partial uploads are not deleted while tusd holds their lock
+func TestSweepLocked(t *testing.T) {
+	p := newPartials(t)
+	id := createPartial(t, p, sender("alice"), nil)
+	p.seen[id] = activity{at: time.Now().Add(-2 * time.Hour)}
+	lock, err := p.Locker.NewLock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Lock(context.Background(), func() {}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	p.Sweep(ctx)
+	if !exists(t, p, id) {
+		t.Error("expected the locked partial upload to be kept")
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	p.Sweep(context.Background())
+	if exists(t, p, id) {
+		t.Error("expected the partial upload to be deleted once it is unlocked")
+	}
+}
@@ -0,0 +1,252 @@
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+const Algorithm = "sha256"
+
+// DuplicateOfHeader carries the id of the original upload on the response that finishes a duplicate.
+const DuplicateOfHeader = "Upload-Duplicate-Of"
+
+// Record is the first upload of some content to a data stream.
+type Record struct {
+	UploadID   string    `json:"upload_id"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// State is the hash of the part of an upload received so far.
+type State struct {
+	Offset int64 `json:"offset"`
+	// Hash is the marshaled state of the hash function.
+	Hash []byte `json:"hash"`
+}
+
+func newState() *State {
+	return &State{}
+}
+
+func (s *State) hash() (hash.Hash, error) {
+	h := sha256.New()
+	if len(s.Hash) == 0 {
+		return h, nil
+	}
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(s.Hash); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s *State) update(h hash.Hash, n int64) error {
+	b, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.Hash = b
+	s.Offset += n
+	return nil
+}
+
+func (s *State) sum() (string, error) {
+	h, err := s.hash()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type Store interface {
+	health.Checkable
+	// Claim records the upload as the first with the key, unless another upload was recorded with it within the
+	// window, which is returned instead.
+	Claim(ctx context.Context, key string, r Record, window time.Duration) (*Record, error)
+	// LoadState returns the hash state of the upload, or nil when there is none.
+	LoadState(ctx context.Context, id string) (*State, error)
+	SaveState(ctx context.Context, id string, s *State, ttl time.Duration) error
+	DeleteState(ctx context.Context, id string) error
+}
+
+// Detector checks finished uploads against the recent uploads to their data stream, following the data stream's
+// duplicate policy.
+type Detector struct {
+	Store   Store
+	Configs *metadata.ConfigCache
+	// StateTTL is how long the hash of an unfinished upload is kept.
+	StateTTL time.Duration
+
+	// rejected holds the uploads that are not to be delivered until their post-finish hook runs, which tusd
+	// always runs on the instance that finished the upload.
+	rejected sync.Map
+}
+
+func key(manifest map[string]string, sum string) string {
+	return strings.ToLower(manifest["data_stream_id"]+"/"+manifest["data_stream_route"]) + ":" + sum
+}
+
+func (d *Detector) config(ctx context.Context, manifest map[string]string) (validation.DuplicateConfig, error) {
+	path, err := metadata.NewFromManifest(manifest)
+	if err != nil {
+		return validation.DuplicateConfig{}, err
+	}
+	c, err := d.Configs.GetConfigForManifest(ctx, strings.ToLower(path.Path()), manifest)
+	if err != nil {
+		return validation.DuplicateConfig{}, err
+	}
+	return c.Duplicates, nil
+}
+
+// Enabled reports whether the data stream of the manifest checks its uploads for duplicates.
+func (d *Detector) Enabled(ctx context.Context, manifest map[string]string) bool {
+	conf, err := d.config(ctx, manifest)
+	return err == nil && conf.Policy != "" && conf.Policy != validation.DuplicatePolicyAllow
+}
+
+// sum returns the hash of the upload, from the state kept while it was received when there is one for all of
+// it, and by reading it back from the upload source otherwise.
+func (d *Detector) sum(ctx context.Context, upload handler.FileInfo) (string, error) {
+	s, err := d.Store.LoadState(ctx, upload.ID)
+	if err != nil {
+		sloger.FromContext(ctx).Warn("unable to load upload hash state", "error", err)
+	}
+	if s != nil && s.Offset == upload.Size {
+		return s.sum()
+	}
+
+	src, ok := delivery.GetSource(delivery.UploadSrc)
+	if !ok {
+		return "", errors.New("no upload source to hash the upload from")
+	}
+	r, err := src.Reader(ctx, upload.ID)
+	if err != nil {
+		return "", err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Check hashes the finished upload and looks for an earlier upload of the same content to its data stream.
+// Duplicates are reported, and are not delivered when the policy rejects them.  Uploads are accepted when they
+// can not be checked.
+func (d *Detector) Check(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	ctx := event.Context
+	logger := sloger.FromContext(ctx)
+	id := event.Upload.ID
+	manifest := event.Upload.MetaData
+	defer func() {
+		if err := d.Store.DeleteState(ctx, id); err != nil {
+			logger.Warn("unable to delete upload hash state", "error", err)
+		}
+	}()
+
+	conf, err := d.config(ctx, manifest)
+	if err != nil {
+		// validation rejects uploads without a config when they are created
+		logger.Warn("unable to get duplicate policy", "error", err)
+		return resp, nil
+	}
+	if conf.Policy == "" || conf.Policy == validation.DuplicatePolicyAllow {
+		return resp, nil
+	}
+
+	logger.Info("starting duplicate-check")
+	rb := reports.NewBuilderWithManifest[reports.DuplicateCheckContent](
+		"1.0.0",
+		reports.StageDuplicateCheck,
+		id,
+		manifest,
+		reports.DispositionTypeAdd).SetStartTime(time.Now().UTC())
+	content := reports.DuplicateCheckContent{
+		ReportContent: reports.ReportContent{
+			ContentSchemaVersion: "1.0.0",
+			ContentSchemaName:    reports.StageDuplicateCheck,
+		},
+		Algorithm: Algorithm,
+		Policy:    conf.Policy,
+	}
+	defer func() {
+		rb.SetEndTime(time.Now().UTC()).SetContent(content)
+		report := rb.Build()
+		logger.Info("REPORT duplicate-check", "report", report)
+		reports.Publish(ctx, report)
+		logger.Info("duplicate-check complete")
+	}()
+	fail := func(err error) (hooks.HookResponse, error) {
+		logger.Error("unable to check upload for duplicates", "error", err)
+		rb.AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelWarning,
+			Message: "unable to check for duplicates: " + err.Error(),
+		})
+		return resp, nil
+	}
+
+	sum, err := d.sum(ctx, event.Upload)
+	if err != nil {
+		return fail(err)
+	}
+	content.Hash = sum
+	original, err := d.Store.Claim(ctx, key(manifest, sum), Record{UploadID: id, ReceivedAt: time.Now().UTC()}, conf.Window())
+	if err != nil {
+		return fail(err)
+	}
+	if original == nil {
+		return resp, nil
+	}
+
+	content.OriginalUploadID = original.UploadID
+	issue := reports.ReportIssue{
+		Level:   reports.IssueLevelWarning,
+		Message: fmt.Sprintf("duplicate of upload %s received at %s", original.UploadID, original.ReceivedAt.Format(time.RFC3339)),
+	}
+	if conf.Policy == validation.DuplicatePolicyReject {
+		issue.Level = reports.IssueLevelError
+		rb.SetStatus(reports.StatusFailed)
+		d.rejected.Store(id, original)
+	}
+	rb.AppendIssue(issue)
+	logger.Warn("duplicate upload", "original_upload_id", original.UploadID, "policy", conf.Policy)
+	resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+		Header: handler.HTTPHeader{DuplicateOfHeader: original.UploadID},
+	})
+	return resp, nil
+}
+
+// SkipRejected wraps a hook so it is not run for duplicates the policy rejected.
+func (d *Detector) SkipRejected(hf func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error)) func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error) {
+	return func(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+		if original, ok := d.rejected.LoadAndDelete(event.Upload.ID); ok {
+			sloger.FromContext(event.Context).Info("skipping delivery of duplicate upload", "original_upload_id", original.(*Record).UploadID)
+			return resp, nil
+		}
+		return hf(event, resp)
+	}
+}
+
+// Forget drops the hash state of a terminated upload.
+func (d *Detector) Forget(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	return resp, d.Store.DeleteState(event.Context, event.Upload.ID)
+}
@@ -0,0 +1,272 @@
+package dedupe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/redis/go-redis/v9"
+	"github.com/tus/tusd/v2/pkg/filestore"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+var start = time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)
+
+func stores(t *testing.T) (map[string]Store, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  &RedisStore{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()})},
+	}, mr
+}
+
+func TestStoreClaim(t *testing.T) {
+	s, mr := stores(t)
+	for name, store := range s {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			window := 24 * time.Hour
+			original, err := store.Claim(ctx, "k", Record{UploadID: "first", ReceivedAt: start}, window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if original != nil {
+				t.Fatalf("expected first upload to be claimed, got duplicate of %+v", original)
+			}
+			original, err = store.Claim(ctx, "k", Record{UploadID: "first", ReceivedAt: start.Add(time.Minute)}, window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if original != nil {
+				t.Errorf("expected the same upload to not be its own duplicate, got %+v", original)
+			}
+			original, err = store.Claim(ctx, "k", Record{UploadID: "second", ReceivedAt: start.Add(time.Hour)}, window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if original == nil || original.UploadID != "first" || !original.ReceivedAt.Equal(start) {
+				t.Errorf("expected duplicate of first upload, got %+v", original)
+			}
+
+			mr.FastForward(window)
+			original, err = store.Claim(ctx, "k", Record{UploadID: "third", ReceivedAt: start.Add(window)}, window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if original != nil {
+				t.Errorf("expected upload after the window to be claimed, got duplicate of %+v", original)
+			}
+		})
+	}
+}
+
+func TestStoreState(t *testing.T) {
+	s, _ := stores(t)
+	for name, store := range s {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			st, err := store.LoadState(ctx, "upload")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st != nil {
+				t.Fatalf("expected no state, got %+v", st)
+			}
+			if err := store.SaveState(ctx, "upload", &State{Offset: 3, Hash: []byte("abc")}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			st, err = store.LoadState(ctx, "upload")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st == nil || st.Offset != 3 || string(st.Hash) != "abc" {
+				t.Errorf("expected saved state, got %+v", st)
+			}
+			if err := store.DeleteState(ctx, "upload"); err != nil {
+				t.Fatal(err)
+			}
+			if st, err := store.LoadState(ctx, "upload"); err != nil || st != nil {
+				t.Errorf("expected deleted state, got %+v %v", st, err)
+			}
+		})
+	}
+}
+
+func TestHashingStore(t *testing.T) {
+	ctx := context.Background()
+	states := NewMemoryStore()
+	fs := filestore.New(t.TempDir())
+	store := &HashingStore{DataStore: &fs, States: states, TTL: time.Hour}
+	composer := handler.NewStoreComposer()
+	store.UseIn(composer)
+	if !composer.UsesTerminater || !composer.UsesConcater || !composer.UsesLengthDeferrer {
+		t.Errorf("expected the extensions of the wrapped store, got %s", composer.Capabilities())
+	}
+
+	content := []byte("hello duplicate world")
+	u, err := composer.Core.NewUpload(ctx, handler.FileInfo{ID: "upload", Size: int64(len(content))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.WriteChunk(ctx, 0, bytes.NewReader(content[:5])); err != nil {
+		t.Fatal(err)
+	}
+	// later chunks are written to uploads fetched by id
+	u, err = composer.Core.GetUpload(ctx, "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.WriteChunk(ctx, 5, bytes.NewReader(content[5:])); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := states.LoadState(ctx, "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st == nil || st.Offset != int64(len(content)) {
+		t.Fatalf("expected state for the whole upload, got %+v", st)
+	}
+	sum, err := st.sum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sha256.Sum256(content)
+	if sum != hex.EncodeToString(expected[:]) {
+		t.Errorf("expected hash %x, got %s", expected, sum)
+	}
+
+	if err := composer.Terminater.AsTerminatableUpload(u).Terminate(ctx); err != nil {
+		t.Errorf("expected wrapped upload to terminate, got %v", err)
+	}
+}
+
+func TestHashingStoreSkipsOutOfOrderChunks(t *testing.T) {
+	ctx := context.Background()
+	states := NewMemoryStore()
+	fs := filestore.New(t.TempDir())
+	store := &HashingStore{DataStore: &fs, States: states, TTL: time.Hour}
+	composer := handler.NewStoreComposer()
+	store.UseIn(composer)
+
+	u, err := composer.Core.NewUpload(ctx, handler.FileInfo{ID: "upload", Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := states.SaveState(ctx, "upload", &State{Offset: 2}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.WriteChunk(ctx, 0, bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatal(err)
+	}
+	st, err := states.LoadState(ctx, "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Offset != 2 {
+		t.Errorf("expected a chunk not at the hashed offset to be left unhashed, got %+v", st)
+	}
+}
+
+func TestHashingStoreSkipsStreamsWithoutPolicy(t *testing.T) {
+	ctx := context.Background()
+	manifest := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+	}
+	path, err := metadata.NewFromManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := &metadata.ConfigCache{}
+	configs.SetConfig(strings.ToLower(path.Path()), &validation.ManifestConfig{
+		Duplicates: validation.DuplicateConfig{Policy: validation.DuplicatePolicyAllow},
+	})
+	states := NewMemoryStore()
+	fs := filestore.New(t.TempDir())
+	store := &HashingStore{DataStore: &fs, States: states, TTL: time.Hour, Detector: &Detector{Store: states, Configs: configs}}
+	composer := handler.NewStoreComposer()
+	store.UseIn(composer)
+
+	u, err := composer.Core.NewUpload(ctx, handler.FileInfo{ID: "upload", Size: 5, MetaData: manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.WriteChunk(ctx, 0, bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := states.LoadState(ctx, "upload"); err != nil || st != nil {
+		t.Errorf("expected an upload to a data stream that allows duplicates not to be hashed, got %+v %v", st, err)
+	}
+}
+
+func TestDetectorCheck(t *testing.T) {
+	ctx := context.Background()
+	manifest := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+	}
+	path, err := metadata.NewFromManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := &metadata.ConfigCache{}
+	configs.SetConfig(strings.ToLower(path.Path()), &validation.ManifestConfig{
+		Duplicates: validation.DuplicateConfig{Policy: validation.DuplicatePolicyReject},
+	})
+	detector := &Detector{Store: NewMemoryStore(), Configs: configs, StateTTL: time.Hour}
+
+	content := []byte("hello duplicate world")
+	check := func(id string) (hooks.HookResponse, bool) {
+		t.Helper()
+		h := sha256.New()
+		h.Write(content)
+		st := newState()
+		if err := st.update(h, int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+		if err := detector.Store.SaveState(ctx, id, st, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		event := &handler.HookEvent{
+			Context: ctx,
+			Upload:  handler.FileInfo{ID: id, Size: int64(len(content)), MetaData: manifest},
+		}
+		resp, err := detector.Check(event, hooks.HookResponse{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivered := false
+		_, err = detector.SkipRejected(func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error) {
+			delivered = true
+			return hooks.HookResponse{}, nil
+		})(event, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st, _ := detector.Store.LoadState(ctx, id); st != nil {
+			t.Errorf("expected the hash state of %s to be deleted, got %+v", id, st)
+		}
+		return resp, delivered
+	}
+
+	resp, delivered := check("first")
+	if !delivered || resp.HTTPResponse.Header[DuplicateOfHeader] != "" {
+		t.Errorf("expected first upload to be delivered, got %+v", resp)
+	}
+	resp, delivered = check("second")
+	if delivered {
+		t.Error("expected rejected duplicate to not be delivered")
+	}
+	if got := resp.HTTPResponse.Header[DuplicateOfHeader]; got != "first" {
+		t.Errorf("expected duplicate of first upload, got %q", got)
+	}
+}
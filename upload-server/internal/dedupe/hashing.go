@@ -0,0 +1,180 @@
+package dedupe
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+// DataStore is a tusd store that registers itself with a composer.
+type DataStore interface {
+	handler.DataStore
+	UseIn(*handler.StoreComposer)
+}
+
+// HashingStore hashes uploads as their chunks are written, keeping the hash state between chunks in States so
+// the hash of a finished upload does not need the upload to be read again.
+type HashingStore struct {
+	DataStore DataStore
+	States    Store
+	TTL       time.Duration
+	// Detector limits hashing to the uploads of data streams that check for duplicates, when it is set.
+	Detector *Detector
+
+	inner *handler.StoreComposer
+}
+
+type hashedUpload struct {
+	handler.Upload
+	id    string
+	store *HashingStore
+	// info is the last info read for the upload, which tusd reads before writing to it.
+	info *handler.FileInfo
+}
+
+func (u *hashedUpload) GetInfo(ctx context.Context) (handler.FileInfo, error) {
+	info, err := u.Upload.GetInfo(ctx)
+	if err == nil {
+		u.info = &info
+	}
+	return info, err
+}
+
+// hashes reports whether the upload's data stream checks for duplicates.
+func (u *hashedUpload) hashes(ctx context.Context) (bool, error) {
+	if u.info == nil {
+		if _, err := u.GetInfo(ctx); err != nil {
+			return false, err
+		}
+	}
+	if u.id == "" {
+		u.id = u.info.ID
+	}
+	return u.store.Detector == nil || u.store.Detector.Enabled(ctx, u.info.MetaData), nil
+}
+
+func (s *HashingStore) NewUpload(ctx context.Context, info handler.FileInfo) (handler.Upload, error) {
+	u, err := s.inner.Core.NewUpload(ctx, info)
+	if err != nil {
+		return u, err
+	}
+	return &hashedUpload{Upload: u, store: s}, nil
+}
+
+func (s *HashingStore) GetUpload(ctx context.Context, id string) (handler.Upload, error) {
+	u, err := s.inner.Core.GetUpload(ctx, id)
+	if err != nil {
+		return u, err
+	}
+	return &hashedUpload{Upload: u, id: id, store: s}, nil
+}
+
+func unwrap(upload handler.Upload) handler.Upload {
+	if u, ok := upload.(*hashedUpload); ok {
+		return u.Upload
+	}
+	return upload
+}
+
+func (s *HashingStore) AsTerminatableUpload(upload handler.Upload) handler.TerminatableUpload {
+	return s.inner.Terminater.AsTerminatableUpload(unwrap(upload))
+}
+
+func (s *HashingStore) AsLengthDeclarableUpload(upload handler.Upload) handler.LengthDeclarableUpload {
+	return s.inner.LengthDeferrer.AsLengthDeclarableUpload(unwrap(upload))
+}
+
+func (s *HashingStore) AsConcatableUpload(upload handler.Upload) handler.ConcatableUpload {
+	return &hashedConcatableUpload{s.inner.Concater.AsConcatableUpload(unwrap(upload))}
+}
+
+// hashedConcatableUpload unwraps the partial uploads, which the wrapped store expects to be its own.
+type hashedConcatableUpload struct {
+	handler.ConcatableUpload
+}
+
+func (u *hashedConcatableUpload) ConcatUploads(ctx context.Context, partials []handler.Upload) error {
+	unwrapped := make([]handler.Upload, len(partials))
+	for i, p := range partials {
+		unwrapped[i] = unwrap(p)
+	}
+	return u.ConcatableUpload.ConcatUploads(ctx, unwrapped)
+}
+
+// UseIn registers the extensions the wrapped store supports.
+func (s *HashingStore) UseIn(composer *handler.StoreComposer) {
+	s.inner = handler.NewStoreComposer()
+	s.DataStore.UseIn(s.inner)
+	composer.UseCore(s)
+	if s.inner.UsesTerminater {
+		composer.UseTerminater(s)
+	}
+	if s.inner.UsesConcater {
+		composer.UseConcater(s)
+	}
+	if s.inner.UsesLengthDeferrer {
+		composer.UseLengthDeferrer(s)
+	}
+}
+
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// WriteChunk hashes the chunk as it is written when the hash state is up to the offset of the chunk.  Chunks are
+// written without being hashed otherwise, leaving the hash to be taken from the finished upload, and uploads to
+// data streams that do not check for duplicates are never hashed.
+func (u *hashedUpload) WriteChunk(ctx context.Context, offset int64, src io.Reader) (int64, error) {
+	logger := sloger.FromContext(ctx)
+	hashes, err := u.hashes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !hashes {
+		return u.Upload.WriteChunk(ctx, offset, src)
+	}
+	st, err := u.store.States.LoadState(ctx, u.id)
+	if err != nil {
+		logger.Warn("unable to load upload hash state", "error", err)
+		return u.Upload.WriteChunk(ctx, offset, src)
+	}
+	if st == nil {
+		st = newState()
+	}
+	if st.Offset != offset {
+		return u.Upload.WriteChunk(ctx, offset, src)
+	}
+	h, err := st.hash()
+	if err != nil {
+		logger.Warn("unable to restore upload hash state", "error", err)
+		return u.Upload.WriteChunk(ctx, offset, src)
+	}
+
+	hashed := &countingWriter{Writer: h}
+	n, err := u.Upload.WriteChunk(ctx, offset, io.TeeReader(src, hashed))
+	if hashed.n != n {
+		// the hash no longer matches what was written
+		if err := u.store.States.DeleteState(ctx, u.id); err != nil {
+			logger.Warn("unable to delete upload hash state", "error", err)
+		}
+		return n, err
+	}
+	if uerr := st.update(h, n); uerr != nil {
+		logger.Warn("unable to save upload hash state", "error", uerr)
+		return n, err
+	}
+	if serr := u.store.States.SaveState(ctx, u.id, st, u.store.TTL); serr != nil {
+		logger.Warn("unable to save upload hash state", "error", serr)
+	}
+	return n, err
+}
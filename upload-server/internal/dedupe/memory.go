@@ -0,0 +1,94 @@
+package dedupe
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// sweepSize is how many keys are kept before expired ones are cleared out.
+const sweepSize = 10000
+
+type expiring[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// MemoryStore keeps the hashes in process, so duplicates are only found per instance.
+type MemoryStore struct {
+	mux     sync.Mutex
+	records map[string]expiring[Record]
+	states  map[string]expiring[State]
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: map[string]expiring[Record]{},
+		states:  map[string]expiring[State]{},
+	}
+}
+
+func sweep[T any](m map[string]expiring[T], now time.Time) {
+	if len(m) <= sweepSize {
+		return
+	}
+	for k, e := range m {
+		if !e.expires.After(now) {
+			delete(m, k)
+		}
+	}
+}
+
+func (s *MemoryStore) Claim(_ context.Context, key string, r Record, window time.Duration) (*Record, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := r.ReceivedAt
+	sweep(s.records, now)
+	if e, ok := s.records[key]; ok && e.expires.After(now) {
+		if e.value.UploadID == r.UploadID {
+			return nil, nil
+		}
+		return &e.value, nil
+	}
+	s.records[key] = expiring[Record]{value: r, expires: now.Add(window)}
+	return nil, nil
+}
+
+func (s *MemoryStore) LoadState(_ context.Context, id string) (*State, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e, ok := s.states[id]
+	if !ok || !e.expires.After(time.Now()) {
+		return nil, nil
+	}
+	return &e.value, nil
+}
+
+func (s *MemoryStore) SaveState(_ context.Context, id string, st *State, ttl time.Duration) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := time.Now()
+	sweep(s.states, now)
+	s.states[id] = expiring[State]{value: *st, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) DeleteState(_ context.Context, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.states, id)
+	return nil
+}
+
+func (s *MemoryStore) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Duplicate detection memory store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	return rsp
+}
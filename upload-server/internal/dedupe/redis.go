@@ -0,0 +1,103 @@
+package dedupe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisRecordPrefix = "dedupe:hash:"
+	redisStatePrefix  = "dedupe:state:"
+)
+
+// claimScript returns the record already kept under the key, or keeps the new one and returns nothing.
+var claimScript = redis.NewScript(`
+local existing = redis.call('GET', KEYS[1])
+if existing then
+	return existing
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// RedisStore shares the hashes between instances.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+func NewRedisStore(uri string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &RedisStore{Client: client}, nil
+}
+
+func (s *RedisStore) Claim(ctx context.Context, key string, r Record, window time.Duration) (*Record, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := claimScript.Run(ctx, s.Client, []string{redisRecordPrefix + key}, b, max(1, window.Milliseconds())).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var original Record
+	if err := json.Unmarshal([]byte(existing), &original); err != nil {
+		return nil, err
+	}
+	if original.UploadID == r.UploadID {
+		return nil, nil
+	}
+	return &original, nil
+}
+
+func (s *RedisStore) LoadState(ctx context.Context, id string) (*State, error) {
+	b, err := s.Client.Get(ctx, redisStatePrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (s *RedisStore) SaveState(ctx context.Context, id string, st *State, ttl time.Duration) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, redisStatePrefix+id, b, ttl).Err()
+}
+
+func (s *RedisStore) DeleteState(ctx context.Context, id string) error {
+	return s.Client.Del(ctx, redisStatePrefix+id).Err()
+}
+
+func (s *RedisStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Duplicate detection redis store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.Client.Ping(ctx).Err(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
@@ -2,13 +2,17 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storeaz"
 )
@@ -31,11 +35,45 @@ func (ad *AzureSource) Reader(ctx context.Context, path string) (io.Reader, erro
 	return s.Body, nil
 }
 
+func (ad *AzureSource) RangeReader(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	srcBlobClient := ad.FromContainerClient.NewBlobClient(ad.Prefix + "/" + path)
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	// a count of zero reads to the end of the blob
+	s, err := srcBlobClient.DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: offset, Count: max(length, 0)},
+	})
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, ErrSrcFileNotExist
+		}
+		return nil, err
+	}
+	return s.Body, nil
+}
+
+// PresignedURL returns a read only SAS url for the blob, which needs the container client to use a shared key.
+func (ad *AzureSource) PresignedURL(_ context.Context, path string, _ string, expiry time.Duration) (string, error) {
+	srcBlobClient := ad.FromContainerClient.NewBlobClient(ad.Prefix + "/" + path)
+	u, err := srcBlobClient.GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(expiry), nil)
+	if err != nil {
+		if errors.Is(err, bloberror.MissingSharedKeyCredential) {
+			return "", ErrPresignUnsupported
+		}
+		return "", err
+	}
+	return u, nil
+}
+
 func (ad *AzureSource) GetMetadata(ctx context.Context, tuid string) (map[string]string, error) {
 	// Get blob src blob client.
 	srcBlobClient := ad.FromContainerClient.NewBlobClient(ad.Prefix + "/" + tuid)
 	resp, err := srcBlobClient.GetProperties(ctx, nil)
 	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, ErrSrcFileNotExist
+		}
 		return nil, err
 	}
 	return storeaz.DepointerizeMetadata(resp.Metadata), nil
@@ -115,6 +115,7 @@ var DestinationTypes = map[string]func() Destination{
 	"s3":      func() Destination { return &S3Destination{} },
 	"file":    func() Destination { return &FileDestination{} },
 	"az-blob": func() Destination { return &AzureDestination{} },
+	"gcs":     func() Destination { return &GCSDestination{} },
 }
 
 var ErrUnknownDestinationType = errors.New("Unknown destination type")
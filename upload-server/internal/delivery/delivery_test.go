@@ -3,7 +3,9 @@ package delivery_test
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
@@ -75,3 +77,41 @@ func TestGetDeliveredFilename(t *testing.T) {
 		}
 	}
 }
+
+func TestReadSeeker(t *testing.T) {
+	ctx := context.Background()
+	src := &delivery.FileSource{FS: fstest.MapFS{"upload": &fstest.MapFile{Data: []byte("hello world")}}}
+
+	r, err := src.RangeReader(ctx, "upload", 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != "wor" {
+		t.Errorf("expected a range of the upload, got %q %v", b, err)
+	}
+	if _, err := src.RangeReader(ctx, "missing", 0, -1); !errors.Is(err, delivery.ErrSrcFileNotExist) {
+		t.Errorf("expected a missing upload, got %v", err)
+	}
+
+	rs := delivery.NewReadSeeker(ctx, src, "upload", 11)
+	defer rs.Close()
+	if size, err := rs.Seek(0, io.SeekEnd); err != nil || size != 11 {
+		t.Errorf("expected to seek to the size of the upload, got %d %v", size, err)
+	}
+	if _, err := rs.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	b, err = io.ReadAll(rs)
+	if err != nil || string(b) != "world" {
+		t.Errorf("expected the end of the upload, got %q %v", b, err)
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b = make([]byte, 5)
+	if _, err := io.ReadFull(rs, b); err != nil || string(b) != "hello" {
+		t.Errorf("expected the start of the upload, got %q %v", b, err)
+	}
+}
@@ -0,0 +1,96 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var ErrPresignUnsupported = errors.New("source can not presign download urls")
+
+// RangeSource is a source that can read part of an upload, so downloads can be resumed.
+type RangeSource interface {
+	Source
+	// RangeReader reads length bytes of the upload starting at offset, or the rest of the upload when length is
+	// negative.
+	RangeReader(ctx context.Context, id string, offset int64, length int64) (io.ReadCloser, error)
+}
+
+// Presigner is a source that can hand out short-lived urls for downloading an upload straight from storage.
+type Presigner interface {
+	PresignedURL(ctx context.Context, id string, filename string, expiry time.Duration) (string, error)
+}
+
+// NewReadSeeker returns a ReadSeeker over the upload of the given size.  The upload is only read from the source
+// once something is read, from the last offset sought.
+func NewReadSeeker(ctx context.Context, src RangeSource, id string, size int64) io.ReadSeekCloser {
+	return &rangeReadSeeker{ctx: ctx, src: src, id: id, size: size}
+}
+
+type rangeReadSeeker struct {
+	ctx    context.Context
+	src    RangeSource
+	id     string
+	size   int64
+	offset int64
+	r      io.ReadCloser
+}
+
+func (rs *rangeReadSeeker) Read(p []byte) (int, error) {
+	if rs.offset >= rs.size {
+		return 0, io.EOF
+	}
+	if rs.r == nil {
+		r, err := rs.src.RangeReader(rs.ctx, rs.id, rs.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		rs.r = r
+	}
+	n, err := rs.r.Read(p)
+	rs.offset += int64(n)
+	return n, err
+}
+
+func (rs *rangeReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += rs.offset
+	case io.SeekEnd:
+		offset += rs.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek to negative offset %d", offset)
+	}
+	if offset != rs.offset {
+		if err := rs.Close(); err != nil {
+			return 0, err
+		}
+		rs.offset = offset
+	}
+	return offset, nil
+}
+
+func (rs *rangeReadSeeker) Close() error {
+	if rs.r == nil {
+		return nil
+	}
+	err := rs.r.Close()
+	rs.r = nil
+	return err
+}
+
+// limitReadCloser closes the reader it limits.
+type limitReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func limitRange(rc io.ReadCloser, length int64) io.ReadCloser {
+	if length < 0 {
+		return rc
+	}
+	return &limitReadCloser{Reader: io.LimitReader(rc, length), Closer: rc}
+}
@@ -69,6 +69,26 @@ func (fd *FileSource) Reader(_ context.Context, path string) (io.Reader, error)
 	return f, nil
 }
 
+func (fd *FileSource) RangeReader(_ context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	f, err := fd.FS.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrSrcFileNotExist
+		}
+		return nil, err
+	}
+	if s, ok := f.(io.Seeker); ok {
+		_, err = s.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, f, offset)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return limitRange(f, length), nil
+}
+
 func (fd *FileSource) GetMetadata(_ context.Context, tuid string) (map[string]string, error) {
 	f, err := fd.FS.Open(tuid + ".meta")
 	if err != nil {
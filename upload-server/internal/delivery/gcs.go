@@ -0,0 +1,140 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storegcs"
+)
+
+type GCSSource struct {
+	FromClient *storage.Client
+	BucketName string
+	Prefix     string
+}
+
+func (gs *GCSSource) object(id string) *storage.ObjectHandle {
+	return gs.FromClient.Bucket(gs.BucketName).Object(gs.Prefix + "/" + id)
+}
+
+func (gs *GCSSource) Reader(ctx context.Context, id string) (io.Reader, error) {
+	r, err := gs.object(id).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read object: %w", err)
+	}
+	return r, nil
+}
+
+func (gs *GCSSource) RangeReader(ctx context.Context, id string, offset int64, length int64) (io.ReadCloser, error) {
+	r, err := gs.object(id).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrSrcFileNotExist
+		}
+		return nil, fmt.Errorf("unable to read object range: %w", err)
+	}
+	return r, nil
+}
+
+func (gs *GCSSource) GetMetadata(ctx context.Context, id string) (map[string]string, error) {
+	attrs, err := gs.object(id).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrSrcFileNotExist
+		}
+		return nil, fmt.Errorf("unable to retrieve object: %w", err)
+	}
+	return attrs.Metadata, nil
+}
+
+func (gs *GCSSource) GetSize(ctx context.Context, id string) (int64, error) {
+	attrs, err := gs.object(id).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return 0, ErrSrcFileNotExist
+		}
+		return 0, fmt.Errorf("unable to retrieve object size: %w", err)
+	}
+	return attrs.Size, nil
+}
+
+func (gs *GCSSource) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "GCS source " + gs.BucketName
+	rsp.Status = models.STATUS_UP
+
+	if gs.FromClient == nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = "GCS source not configured"
+		return rsp
+	}
+
+	if _, err := gs.FromClient.Bucket(gs.BucketName).Attrs(ctx); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+
+	return rsp
+}
+
+type GCSDestination struct {
+	toClient        *storage.Client
+	BucketName      string `yaml:"bucket_name"`
+	Name            string `yaml:"name"`
+	PathTemplate    string `yaml:"path_template"`
+	Endpoint        string `yaml:"endpoint"`
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+func (gd *GCSDestination) Client(ctx context.Context) (*storage.Client, error) {
+	if gd.toClient != nil {
+		return gd.toClient, nil
+	}
+	c, err := storegcs.NewClient(ctx, gd.Endpoint, gd.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	gd.toClient = c
+	return c, nil
+}
+
+func (gd *GCSDestination) Upload(ctx context.Context, path string, r io.Reader, m map[string]string) (string, error) {
+	c, err := gd.Client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	w := c.Bucket(gd.BucketName).Object(path).NewWriter(ctx)
+	w.Metadata = m
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return "", fmt.Errorf("failed to upload file to %s %s: %w", gd.BucketName, path, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to upload file to %s %s: %w", gd.BucketName, path, err)
+	}
+
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", gd.BucketName, path), nil
+}
+
+func (gd *GCSDestination) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "GCS deliver target " + gd.Name
+	rsp.Status = models.STATUS_UP
+
+	c, err := gd.Client(ctx)
+	if err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = "GCS deliverer target " + gd.Name + " not configured: " + err.Error()
+		return rsp
+	}
+
+	if _, err := c.Bucket(gd.BucketName).Attrs(ctx); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+
+	return rsp
+}
@@ -2,13 +2,18 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
 )
 
@@ -49,6 +54,40 @@ func (ss *S3Source) Reader(ctx context.Context, id string) (io.Reader, error) {
 	return r, nil
 }
 
+func (ss *S3Source) RangeReader(ctx context.Context, id string, offset int64, length int64) (io.ReadCloser, error) {
+	srcFilename := ss.Prefix + "/" + id
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		rng += strconv.FormatInt(offset+length-1, 10)
+	}
+	output, err := ss.FromClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(ss.BucketName),
+		Key:    aws.String(srcFilename),
+		Range:  aws.String(rng),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, ErrSrcFileNotExist
+		}
+		return nil, fmt.Errorf("unable to read object range: %w", err)
+	}
+	return output.Body, nil
+}
+
+func (ss *S3Source) PresignedURL(ctx context.Context, id string, filename string, expiry time.Duration) (string, error) {
+	srcFilename := ss.Prefix + "/" + id
+	req, err := s3.NewPresignClient(ss.FromClient).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(ss.BucketName),
+		Key:                        aws.String(srcFilename),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filename})),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("unable to presign object: %w", err)
+	}
+	return req.URL, nil
+}
+
 func (ss *S3Source) GetMetadata(ctx context.Context, id string) (map[string]string, error) {
 	// Get the object from S3
 	srcFilename := ss.Prefix + "/" + id
@@ -57,6 +96,10 @@ func (ss *S3Source) GetMetadata(ctx context.Context, id string) (map[string]stri
 		Key:    aws.String(srcFilename),
 	})
 	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrSrcFileNotExist
+		}
 		return nil, fmt.Errorf("unable to retrieve object: %w", err)
 	}
 
@@ -70,6 +113,10 @@ func (ss *S3Source) GetSize(ctx context.Context, id string) (int64, error) {
 		Key:    aws.String(srcFilename),
 	})
 	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return 0, ErrSrcFileNotExist
+		}
 		return 0, fmt.Errorf("unable to retrieve object size: %w", err)
 	}
 	return *output.ContentLength, nil
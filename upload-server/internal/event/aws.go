@@ -113,9 +113,18 @@ func (s SNSPublisher[T]) Publish(ctx context.Context, e T) error {
 		return err
 	}
 	m := b.String()
+	// the trace context is also sent as message attributes for consumers that do not read it from the body
+	attributes := map[string]snsTypes.MessageAttributeValue{}
+	for k, v := range NewTraceContext(ctx) {
+		attributes[k] = snsTypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
 	result, err := c.Publish(ctx, &sns.PublishInput{
-		Message:  &m,
-		TopicArn: &s.TopicArn,
+		Message:           &m,
+		TopicArn:          &s.TopicArn,
+		MessageAttributes: attributes,
 	})
 	slog.Info("SNS event publish response", "response", result, "event", e, "uploadId", e.Identifier())
 	return err
@@ -347,7 +356,7 @@ func (s *SQSSubscriber[T]) Listen(ctx context.Context, process func(context.Cont
 					continue
 				}
 				done := s.keepAlive(ctx, message.ReceiptHandle)
-				if err := process(ctx, e); err != nil {
+				if err := process(e.ExtractTraceContext(ctx), e); err != nil {
 					slog.Error("failed to process message", "message", message, "error", err.Error())
 					done()
 					if err := s.requeueMessage(ctx, message.ReceiptHandle); err != nil {
@@ -66,8 +66,14 @@ func (ap *AzurePublisher[T]) Publish(ctx context.Context, event T) error {
 		return err
 	}
 
+	// the trace context is also sent as application properties for consumers that do not read it from the body
+	properties := map[string]any{}
+	for k, v := range NewTraceContext(ctx) {
+		properties[k] = v
+	}
 	return ap.Sender.SendMessage(ctx, &azservicebus.Message{
-		Body: b,
+		Body:                  b,
+		ApplicationProperties: properties,
 	}, nil)
 }
 
@@ -165,8 +171,9 @@ func (as *AzureSubscriber[T]) Listen(ctx context.Context, process func(context.C
 					if err := as.Receiver.DeadLetterMessage(ctx, m, nil); err != nil {
 						slog.Error("failed to dead letter message", "message", m, "error", err.Error())
 					}
+					continue
 				}
-				if err := process(ctx, e); err != nil {
+				if err := process(e.ExtractTraceContext(ctx), e); err != nil {
 					if err := as.Receiver.DeadLetterMessage(ctx, m, nil); err != nil {
 						slog.Error("failed to dead letter message", "message", m, "error", err.Error())
 					}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -23,6 +24,7 @@ type Retryable interface {
 // TODO better name for this interface would be Subscribable or Queueable or similar
 type Identifiable interface {
 	Retryable
+	Traceable
 	Identifier() string
 	GetUploadID() string
 	Type() string
@@ -31,9 +33,10 @@ type Identifiable interface {
 }
 
 type Event struct {
-	ID         string `json:"id"`
-	Type       string `json:"type"`
-	RetryCount int    `json:"retry_count"`
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	RetryCount   int          `json:"retry_count"`
+	TraceContext TraceContext `json:"trace_context,omitempty"`
 }
 
 type FileReady struct {
@@ -43,6 +46,8 @@ type FileReady struct {
 	Path              string `json:"path"`
 	DestinationTarget string `json:"deliver_target"`
 	Metadata          map[string]string
+	// Principal is who created the upload.
+	Principal *metadata.Principal `json:"principal,omitempty"`
 }
 
 func (fr *FileReady) RetryCount() int {
@@ -73,15 +78,16 @@ func (fr *FileReady) GetUploadID() string {
 	return fr.UploadId
 }
 
-func NewFileReadyEvent(uploadId string, metadata map[string]string, path, target string) *FileReady {
+func NewFileReadyEvent(uploadId string, manifest map[string]string, path, target string) *FileReady {
 	return &FileReady{
 		Event: Event{
 			Type: FileReadyEventType,
 		},
 		Path:              path,
 		UploadId:          uploadId,
-		Metadata:          metadata,
+		Metadata:          manifest,
 		DestinationTarget: target,
+		Principal:         metadata.GetPrincipal(manifest),
 	}
 }
 
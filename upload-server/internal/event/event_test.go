@@ -3,12 +3,17 @@ package event
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"log/slog"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestBasicMemoryEvent(t *testing.T) {
@@ -100,3 +105,50 @@ func TestUploadIDLoggingProcessor(t *testing.T) {
 		t.Fatalf("expected log output to contain %s, got %s", testEvent.UploadId, logOutput.String())
 	}
 }
+
+func TestTraceContextPropagation(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	ctx, span := otel.Tracer("test").Start(context.Background(), "upload")
+	defer span.End()
+
+	c := make(chan *FileReady)
+	pub := Publishers[*FileReady]{&MemoryPublisher[*FileReady]{c}}
+	sub := MemorySubscriber[*FileReady]{c}
+
+	received := make(chan trace.SpanContext, 1)
+	listenCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sub.Listen(listenCtx, func(ctx context.Context, fr *FileReady) error {
+		received <- trace.SpanContextFromContext(ctx)
+		return nil
+	})
+
+	e := NewFileReadyEvent("test-upload-id", nil, "path", "edav")
+	if err := pub.Publish(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if e.TraceContext["traceparent"] == "" {
+		t.Fatalf("expected the event to carry a traceparent, got %v", e.TraceContext)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded FileReady
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TraceContext["traceparent"] != e.TraceContext["traceparent"] {
+		t.Errorf("expected the trace context to survive serialization, got %v", decoded.TraceContext)
+	}
+
+	sc := <-received
+	if !sc.IsRemote() || sc.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("expected the subscriber to continue trace %s, got %s", span.SpanContext().TraceID(), sc.TraceID())
+	}
+	if sc.SpanID() == span.SpanContext().SpanID() {
+		t.Error("expected the subscriber's parent to be the publish span")
+	}
+}
@@ -37,7 +37,7 @@ func (ms *MemorySubscriber[T]) Listen(ctx context.Context, process func(context.
 		case <-ctx.Done():
 			return nil
 		case evt := <-ms.Chan:
-			if err := process(ctx, evt); err != nil {
+			if err := process(evt.ExtractTraceContext(ctx), evt); err != nil {
 				slog.Error("failed to handle event", "event", evt, "error", err.Error())
 				if evt.RetryCount() < MaxRetries {
 					evt.IncrementRetryCount()
@@ -8,11 +8,19 @@ import (
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Publishers[T Identifiable] []Publisher[T]
 
+// Publish sends the event to every publisher from a producer span, whose trace context the event carries so the
+// span handling the event continues the trace.
 func (p Publishers[T]) Publish(ctx context.Context, e T) error {
+	ctx, span := otel.Tracer("event-publishing").Start(ctx, fmt.Sprintf("Publish-%s", e.Type()), trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	e.InjectTraceContext(ctx)
+
 	var errs error
 	logger := sloger.FromContext(ctx)
 	for _, publisher := range p {
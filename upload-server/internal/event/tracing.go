@@ -0,0 +1,48 @@
+package event
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TraceContext carries the W3C trace context (traceparent and tracestate) of the span that published an event,
+// so the span that handles it, on this or another instance, continues the same trace.
+type TraceContext map[string]string
+
+// Traceable events carry a trace context from the publisher to the subscriber.
+type Traceable interface {
+	InjectTraceContext(ctx context.Context)
+	ExtractTraceContext(ctx context.Context) context.Context
+}
+
+// NewTraceContext returns the trace context of the span in ctx, or nil when there is none.
+func NewTraceContext(ctx context.Context) TraceContext {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return TraceContext(carrier)
+}
+
+// Extract returns ctx with the remote span of the trace context as its parent.
+func (tc TraceContext) Extract(ctx context.Context) context.Context {
+	if len(tc) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(tc))
+}
+
+// InjectTraceContext records the trace context of ctx on the event.  Events published outside of a span keep
+// the trace context they already had.
+func (e *Event) InjectTraceContext(ctx context.Context) {
+	if tc := NewTraceContext(ctx); tc != nil {
+		e.TraceContext = tc
+	}
+}
+
+func (e *Event) ExtractTraceContext(ctx context.Context) context.Context {
+	return e.TraceContext.Extract(ctx)
+}
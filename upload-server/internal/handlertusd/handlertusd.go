@@ -2,6 +2,8 @@ package handlertusd
 
 import (
 	"errors"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/dedupe"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/slogerxexp"
 	"github.com/prometheus/client_golang/prometheus"
 	tusd "github.com/tus/tusd/v2/pkg/handler"
@@ -27,8 +29,9 @@ type Locker interface {
 	UseIn(*tusd.StoreComposer)
 }
 
-// New returns a configured TUSD handler as-is with official implementation
-func New(store Store, locker Locker, hooksHandler hooks.HookHandler, basePath string) (*tusd.Handler, error) {
+// New returns a configured TUSD handler as-is with official implementation.  Uploads are not limited in size when
+// maxSize is 0.
+func New(store Store, locker Locker, hooksHandler hooks.HookHandler, basePath string, maxSize int64) (*tusd.Handler, error) {
 	if slogerxexp.DefaultLogger != nil {
 		logger = slogerxexp.DefaultLogger
 	}
@@ -51,11 +54,13 @@ func New(store Store, locker Locker, hooksHandler hooks.HookHandler, basePath st
 	// ------------------------------------------------------------------
 	corsConfig := tusd.DefaultCorsConfig
 	corsConfig.AllowCredentials = true
+	corsConfig.ExposeHeaders += ", " + metadata.ValidationWarningsHeader + ", " + dedupe.DuplicateOfHeader + ", " + metadata.MaxSizeHeader
 
 	// Create a new HTTP handler for the tusd server by providing a configuration.
 	// The StoreComposer property must be set to allow the handler to function.
 	handler, err := hooks.NewHandlerWithHooks(&tusd.Config{
 		BasePath:                basePath,
+		MaxSize:                 maxSize,
 		StoreComposer:           composer,
 		NotifyCompleteUploads:   true,
 		Logger:                  slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})),
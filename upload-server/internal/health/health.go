@@ -8,7 +8,10 @@ import (
 	"log/slog"
 	"net/http"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
@@ -27,21 +30,37 @@ type Checkable interface {
 	Health(context.Context) models.ServiceHealthResp
 }
 
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultCacheTTL = 10 * time.Second
+)
+
 // HealthResp, app health response
 type HealthResp struct {
 	Status   string                     `json:"status"` // general app health
 	Services []models.ServiceHealthResp `json:"services"`
 } // .HealthResp
 
+// SystemHealthCheck runs the registered checks in parallel, each bounded by Timeout, and reuses the results for
+// CacheTTL so that frequent probes do not hammer the dependencies.  The app is DOWN when a critical service is
+// down, and DEGRADED when only non-critical services are.
 type SystemHealthCheck struct {
 	Services []Checkable
+	// Critical services are the ones uploads can not be taken without, such as the store, locker, and event bus.
+	Critical []Checkable
+	Timeout  time.Duration
+	CacheTTL time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	results   []models.ServiceHealthResp
 }
 
-func (hc *SystemHealthCheck) Register(checks ...any) error {
+func register(services *[]Checkable, checks []any) error {
 	var errs error
 	for _, c := range checks {
 		if cc, ok := c.(Checkable); ok {
-			hc.Services = append(hc.Services, cc)
+			*services = append(*services, cc)
 		} else {
 			errs = errors.Join(errs, fmt.Errorf("Could not register %+V health check", c))
 		}
@@ -49,25 +68,131 @@ func (hc *SystemHealthCheck) Register(checks ...any) error {
 	return errs
 }
 
-// health responds to /health endpoint with the health of the app including dependency services
-func (hc *SystemHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (hc *SystemHealthCheck) Register(checks ...any) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.checkedAt = time.Time{}
+	return register(&hc.Services, checks)
+}
 
-	status := models.STATUS_UP
+// RegisterCritical registers checks of services the app is not ready without.
+func (hc *SystemHealthCheck) RegisterCritical(checks ...any) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.checkedAt = time.Time{}
+	return register(&hc.Critical, checks)
+}
 
-	var servicesResponses []models.ServiceHealthResp
+func (hc *SystemHealthCheck) timeout() time.Duration {
+	if hc.Timeout > 0 {
+		return hc.Timeout
+	}
+	return DefaultTimeout
+}
 
-	for _, check := range hc.Services {
-		sr := check.Health(r.Context())
-		servicesResponses = append(servicesResponses, sr)
-		if sr.Status == models.STATUS_DOWN {
-			status = models.STATUS_DEGRADED
-		} // .if
-	} // .if
+func (hc *SystemHealthCheck) cacheTTL() time.Duration {
+	if hc.CacheTTL > 0 {
+		return hc.CacheTTL
+	}
+	return DefaultCacheTTL
+}
+
+// Check returns the health of every registered service, critical services first.  Checks are run at most once
+// per cache ttl; callers in the meantime get the cached results.
+func (hc *SystemHealthCheck) Check(ctx context.Context) HealthResp {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.checkedAt.IsZero() || time.Since(hc.checkedAt) >= hc.cacheTTL() {
+		// a probe that hangs up should not leave its cancelled results in the cache
+		hc.results = hc.run(context.WithoutCancel(ctx))
+		hc.checkedAt = time.Now()
+	}
 
-	jsonResp, err := json.Marshal(HealthResp{
+	status := models.STATUS_UP
+	for _, sr := range hc.results {
+		if sr.Status != models.STATUS_DOWN {
+			continue
+		}
+		if sr.Critical {
+			status = models.STATUS_DOWN
+			break
+		}
+		status = models.STATUS_DEGRADED
+	}
+	return HealthResp{
 		Status:   status,
-		Services: servicesResponses,
-	}) // .jsonResp
+		Services: slices.Clone(hc.results),
+	}
+}
+
+func (hc *SystemHealthCheck) run(ctx context.Context) []models.ServiceHealthResp {
+	checks := append(slices.Clone(hc.Critical), hc.Services...)
+	results := make([]models.ServiceHealthResp, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checkWithTimeout(ctx, check, hc.timeout())
+			results[i].Critical = i < len(hc.Critical)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// checkWithTimeout reports the service as down once the timeout passes, even if its check ignores the context.
+func checkWithTimeout(ctx context.Context, check Checkable, timeout time.Duration) models.ServiceHealthResp {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan models.ServiceHealthResp, 1)
+	go func() {
+		done <- check.Health(ctx)
+	}()
+	select {
+	case sr := <-done:
+		return sr
+	case <-ctx.Done():
+		logger.Warn("health check timed out", "check", fmt.Sprintf("%T", check), "timeout", timeout)
+		return models.ServiceHealthResp{
+			Service:     fmt.Sprintf("%T", check),
+			Status:      models.STATUS_DOWN,
+			HealthIssue: fmt.Sprintf("health check timed out after %s", timeout),
+		}
+	}
+}
+
+// health responds to /health endpoint with the health of the app including dependency services.  It responds
+// with 503 when a critical service is down.
+func (hc *SystemHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, hc.Check(r.Context()))
+} // .health
+
+// Ready responds to readiness probes with the health of the critical services, and 503 when any is down so
+// load balancers stop routing uploads to the instance.
+func (hc *SystemHealthCheck) Ready() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := hc.Check(r.Context())
+		resp.Services = slices.DeleteFunc(resp.Services, func(sr models.ServiceHealthResp) bool {
+			return !sr.Critical
+		})
+		if resp.Status == models.STATUS_DEGRADED {
+			resp.Status = models.STATUS_UP
+		}
+		writeHealth(w, resp)
+	})
+}
+
+// Live responds to liveness probes.  It does not check dependencies, since restarting the instance would not
+// fix them.
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, HealthResp{Status: models.STATUS_UP, Services: []models.ServiceHealthResp{}})
+	})
+}
+
+func writeHealth(w http.ResponseWriter, resp HealthResp) {
+	jsonResp, err := json.Marshal(resp) // .jsonResp
 	if err != nil {
 		errMsg := "error marshal json for health response"
 		logger.Error(errMsg, "error", err.Error())
@@ -76,9 +201,13 @@ func (hc *SystemHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} // .if
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	if resp.Status == models.STATUS_DOWN {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
 	w.Write(jsonResp)
-} // .health
+}
 
 var DefaultSystemHealthCheck = &SystemHealthCheck{}
 
@@ -86,6 +215,14 @@ func Register(c ...any) error {
 	return DefaultSystemHealthCheck.Register(c...)
 }
 
+func RegisterCritical(c ...any) error {
+	return DefaultSystemHealthCheck.RegisterCritical(c...)
+}
+
 func Handler() http.Handler {
 	return DefaultSystemHealthCheck
 }
+
+func ReadyHandler() http.Handler {
+	return DefaultSystemHealthCheck.Ready()
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+type fakeCheck struct {
+	name  string
+	down  atomic.Bool
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (f *fakeCheck) Health(ctx context.Context) models.ServiceHealthResp {
+	f.calls.Add(1)
+	time.Sleep(f.delay)
+	rsp := models.ServiceHealthResp{Service: f.name, Status: models.STATUS_UP, HealthIssue: models.HEALTH_ISSUE_NONE}
+	if f.down.Load() {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = "unreachable"
+	}
+	return rsp
+}
+
+func get(t *testing.T, h http.Handler) (int, HealthResp) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var resp HealthResp
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, resp
+}
+
+func TestSystemHealthCheck_Status(t *testing.T) {
+	store := &fakeCheck{name: "store"}
+	reporter := &fakeCheck{name: "reporter"}
+	hc := &SystemHealthCheck{CacheTTL: time.Nanosecond}
+	hc.RegisterCritical(store)
+	hc.Register(reporter)
+
+	cases := []struct {
+		name          string
+		storeDown     bool
+		reporterDown  bool
+		expectStatus  string
+		expectCode    int
+		expectReady   int
+		readyServices int
+	}{
+		{"all up", false, false, models.STATUS_UP, http.StatusOK, http.StatusOK, 1},
+		{"non-critical down", false, true, models.STATUS_DEGRADED, http.StatusOK, http.StatusOK, 1},
+		{"critical down", true, false, models.STATUS_DOWN, http.StatusServiceUnavailable, http.StatusServiceUnavailable, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store.down.Store(c.storeDown)
+			reporter.down.Store(c.reporterDown)
+			time.Sleep(time.Millisecond)
+
+			code, resp := get(t, hc)
+			if code != c.expectCode || resp.Status != c.expectStatus {
+				t.Errorf("expected %d %s, got %d %s", c.expectCode, c.expectStatus, code, resp.Status)
+			}
+			if len(resp.Services) != 2 || !resp.Services[0].Critical || resp.Services[1].Critical {
+				t.Errorf("expected the critical service first, got %+v", resp.Services)
+			}
+
+			code, resp = get(t, hc.Ready())
+			if code != c.expectReady || len(resp.Services) != c.readyServices {
+				t.Errorf("expected ready %d with %d services, got %d %+v", c.expectReady, c.readyServices, code, resp.Services)
+			}
+
+			if code, _ := get(t, Live()); code != http.StatusOK {
+				t.Errorf("expected live to ignore dependencies, got %d", code)
+			}
+		})
+	}
+}
+
+func TestSystemHealthCheck_TimeoutAndCache(t *testing.T) {
+	slow := []*fakeCheck{
+		{name: "slow-1", delay: time.Second},
+		{name: "slow-2", delay: time.Second},
+		{name: "slow-3", delay: time.Second},
+	}
+	fast := &fakeCheck{name: "fast"}
+	hc := &SystemHealthCheck{Timeout: 50 * time.Millisecond, CacheTTL: time.Minute}
+	for _, s := range slow {
+		hc.RegisterCritical(s)
+	}
+	hc.Register(fast)
+
+	start := time.Now()
+	resp := hc.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected checks to run in parallel and time out, took %s", elapsed)
+	}
+	if resp.Status != models.STATUS_DOWN {
+		t.Errorf("expected timed out critical checks to take the app down, got %s", resp.Status)
+	}
+	if resp.Services[3].Status != models.STATUS_UP {
+		t.Errorf("expected fast check to finish, got %+v", resp.Services[3])
+	}
+
+	hc.Check(context.Background())
+	if n := fast.calls.Load(); n != 1 {
+		t.Errorf("expected cached results to be reused, checked %d times", n)
+	}
+
+	hc.Register(&fakeCheck{name: "late"})
+	if resp := hc.Check(context.Background()); len(resp.Services) != 5 {
+		t.Errorf("expected registering a check to clear the cache, got %d services", len(resp.Services))
+	}
+}
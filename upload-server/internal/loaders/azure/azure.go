@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
@@ -30,3 +31,20 @@ func (l *AzureConfigLoader) LoadConfig(ctx context.Context, path string) ([]byte
 
 	return io.ReadAll(downloadResponse.Body)
 }
+
+func (l *AzureConfigLoader) ListConfigs(ctx context.Context) ([]string, error) {
+	var paths []string
+	pager := l.Client.NewListBlobsFlatPager(l.ContainerName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name != nil && strings.HasSuffix(*item.Name, ".json") {
+				paths = append(paths, *item.Name)
+			}
+		}
+	}
+	return paths, nil
+}
@@ -21,3 +21,7 @@ func (l *FileConfigLoader) LoadConfig(_ context.Context, path string) ([]byte, e
 	defer file.Close()
 	return io.ReadAll(file)
 }
+
+func (l *FileConfigLoader) ListConfigs(_ context.Context) ([]string, error) {
+	return fs.Glob(l.FileSystem, "*.json")
+}
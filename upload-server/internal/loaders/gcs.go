@@ -0,0 +1,58 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"google.golang.org/api/iterator"
+)
+
+type GCSConfigLoader struct {
+	Client     *storage.Client
+	BucketName string
+	Folder     string
+}
+
+func (l *GCSConfigLoader) LoadConfig(ctx context.Context, path string) ([]byte, error) {
+	key := path
+	if l.Folder != "" {
+		key = l.Folder + "/" + key
+	}
+	r, err := l.Client.Bucket(l.BucketName).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, errors.Join(err, validation.ErrNotFound)
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (l *GCSConfigLoader) ListConfigs(ctx context.Context) ([]string, error) {
+	prefix := ""
+	if l.Folder != "" {
+		prefix = l.Folder + "/"
+	}
+	var paths []string
+	it := l.Client.Bucket(l.BucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		obj, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		path := strings.TrimPrefix(obj.Name, prefix)
+		if strings.HasSuffix(path, ".json") && !strings.Contains(path, "/") {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
 	"io"
+	"strings"
 )
 
 type S3ConfigLoader struct {
@@ -39,3 +40,28 @@ func (l *S3ConfigLoader) LoadConfig(ctx context.Context, path string) ([]byte, e
 
 	return io.ReadAll(output.Body)
 }
+
+func (l *S3ConfigLoader) ListConfigs(ctx context.Context) ([]string, error) {
+	prefix := ""
+	if l.Folder != "" {
+		prefix = l.Folder + "/"
+	}
+	var paths []string
+	paginator := s3.NewListObjectsV2Paginator(l.Client, &s3.ListObjectsV2Input{
+		Bucket: &l.BucketName,
+		Prefix: &prefix,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			path := strings.TrimPrefix(*obj.Key, prefix)
+			if strings.HasSuffix(path, ".json") && !strings.Contains(path, "/") {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
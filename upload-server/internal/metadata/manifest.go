@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
 	"github.com/tus/tusd/v2/pkg/handler"
 
 	"fmt"
@@ -19,7 +20,19 @@ func (c *ConfigIdentification) Path() string {
 	return path
 }
 
+// NewFromManifest locates the config for a manifest by its data stream fields.  Legacy v1 manifests that only
+// carry meta_destination_id and meta_ext_event are located by those instead, so their config can map them to v2.
 func NewFromManifest(manifest handler.MetaData) (validation.ConfigLocation, error) {
+	if _, ok := manifest["data_stream_id"]; !ok {
+		id, hasID := manifest[models.META_DESTINATION_ID]
+		event, hasEvent := manifest[models.META_EXT_EVENT]
+		if hasID && hasEvent {
+			return &ConfigIdentification{
+				DataStreamID:    id,
+				DataStreamRoute: event,
+			}, nil
+		}
+	}
 	dataStreamID, ok := manifest["data_stream_id"]
 	if !ok {
 		return nil, errors.Join(validation.ErrFailure, &validation.ErrorMissing{Field: "data_stream_id"})
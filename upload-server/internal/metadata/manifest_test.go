@@ -0,0 +1,26 @@
+package metadata_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+func TestNewFromManifest(t *testing.T) {
+	path, err := metadata.NewFromManifest(handler.MetaData{"meta_destination_id": "ndlp", "meta_ext_event": "routineImmunization"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Path() != "ndlp_routineImmunization.json" {
+		t.Errorf("expected a v1 manifest to be located by its v1 fields, got %s", path.Path())
+	}
+
+	_, err = metadata.NewFromManifest(handler.MetaData{"meta_destination_id": "ndlp"})
+	var missing *validation.ErrorMissing
+	if !errors.As(err, &missing) || missing.Field != "data_stream_id" {
+		t.Errorf("expected data_stream_id to be missing from a manifest without meta_ext_event, got %v", err)
+	}
+}
@@ -1,18 +1,23 @@
 package metadata
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storeaz"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
@@ -29,13 +34,19 @@ const FolderStructureRoot = "root"
 const FilenameSuffixUploadId = "upload_id"
 const ErrNoUploadId = "no upload ID defined"
 
+// ValidationWarningsHeader carries the number of non-fatal validation warnings on an accepted upload.
+const ValidationWarningsHeader = "Upload-Validation-Warnings"
+
 type PreCreateResponse struct {
-	UploadId         string   `json:"upload_id"`
-	ValidationErrors []string `json:"validation_errors"`
+	UploadId           string   `json:"upload_id"`
+	ValidationErrors   []string `json:"validation_errors"`
+	ValidationWarnings []string `json:"validation_warnings,omitempty"`
 }
 
 var Cache *ConfigCache
 
+var ErrInvalidConfig = errors.New("invalid manifest config")
+
 type ConfigCache struct {
 	sync.Map
 	Loader validation.ConfigLoader
@@ -44,20 +55,10 @@ type ConfigCache struct {
 func (c *ConfigCache) GetConfig(ctx context.Context, key string) (*validation.ManifestConfig, error) {
 	conf, ok := c.Load(key)
 	if !ok {
-		if c.Loader == nil {
-			return nil, errors.New("misconfigured config cache, set a loader")
-		}
-		b, err := c.Loader.LoadConfig(ctx, key)
+		mc, err := c.loadConfig(ctx, key)
 		if err != nil {
 			return nil, err
 		}
-
-		// Expand config string to substitute any env var placeholders within.
-		expandedConf := os.ExpandEnv(string(b))
-		mc := &validation.ManifestConfig{}
-		if err := json.Unmarshal([]byte(expandedConf), mc); err != nil {
-			return nil, err
-		}
 		c.SetConfig(key, mc)
 		return mc, nil
 	}
@@ -68,10 +69,145 @@ func (c *ConfigCache) GetConfig(ctx context.Context, key string) (*validation.Ma
 	return config, nil
 }
 
+// ReloadConfig loads the config at key again, replacing the cached one only when the new one is valid.
+func (c *ConfigCache) ReloadConfig(ctx context.Context, key string) (*validation.ManifestConfig, error) {
+	mc, err := c.loadConfig(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c.SetConfig(key, mc)
+	return mc, nil
+}
+
+func (c *ConfigCache) loadConfig(ctx context.Context, key string) (*validation.ManifestConfig, error) {
+	if c.Loader == nil {
+		return nil, errors.New("misconfigured config cache, set a loader")
+	}
+	b, err := c.Loader.LoadConfig(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expand config string to substitute any env var placeholders within.
+	expandedConf := os.ExpandEnv(string(b))
+	mc := &validation.ManifestConfig{}
+	if err := json.Unmarshal([]byte(expandedConf), mc); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidConfig, key, err)
+	}
+	if err := mc.Check(); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidConfig, key, err)
+	}
+	return mc, nil
+}
+
 func (c *ConfigCache) SetConfig(key any, config *validation.ManifestConfig) {
 	c.Store(key, config)
 }
 
+// GetConfigForManifest loads the config at key and selects the version of it that applies to the manifest.
+func (c *ConfigCache) GetConfigForManifest(ctx context.Context, key string, manifest map[string]string) (*validation.ManifestConfig, error) {
+	conf, err := c.GetConfig(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return conf.Select(manifest[validation.ConfigVersionKey], ingestTime(manifest))
+}
+
+// ingestTime is when the upload was received, so that every hook selects the same config version.
+func ingestTime(manifest map[string]string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, metadata.GetDexIngestDatetime(manifest)); err == nil {
+		return t
+	}
+	return time.Now().UTC()
+}
+
+type DataStreamConfig struct {
+	DataStreamID    string                   `json:"data_stream_id"`
+	DataStreamRoute string                   `json:"data_stream_route"`
+	Fields          []validation.FieldConfig `json:"fields"`
+	MaxSizeBytes    int64                    `json:"max_size_bytes,omitempty"`
+
+	authorization validation.AuthorizationConfig
+}
+
+var ErrListingUnsupported = errors.New("config loader does not support listing")
+
+// ListConfigs returns the keys of every config the loader holds.
+func (c *ConfigCache) ListConfigs(ctx context.Context) ([]string, error) {
+	lister, ok := c.Loader.(validation.ConfigLister)
+	if !ok {
+		return nil, ErrListingUnsupported
+	}
+	return lister.ListConfigs(ctx)
+}
+
+// ListDataStreams returns every data stream and route that has a manifest config with metadata fields.
+func (c *ConfigCache) ListDataStreams(ctx context.Context) ([]DataStreamConfig, error) {
+	paths, err := c.ListConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []DataStreamConfig
+	for _, path := range paths {
+		id, route, ok := strings.Cut(strings.TrimSuffix(path, ".json"), "_")
+		if !ok {
+			continue
+		}
+		conf, err := c.GetConfigForManifest(ctx, path, nil)
+		if err != nil {
+			sloger.FromContext(ctx).Warn("skipping manifest config that failed to load", "config", path, "error", err)
+			continue
+		}
+		if len(conf.Metadata.Fields) == 0 {
+			// configs without fields only translate legacy manifests
+			continue
+		}
+		ds := DataStreamConfig{
+			DataStreamID:    id,
+			DataStreamRoute: route,
+			Fields:          conf.Metadata.Fields,
+			MaxSizeBytes:    conf.MaxSizeBytes,
+			authorization:   conf.Authorization,
+		}
+		// prefer the values the config itself allows over the file name, which cannot express underscores
+		for _, f := range conf.Metadata.Fields {
+			if len(f.AllowedValues) != 1 {
+				continue
+			}
+			switch f.FieldName {
+			case "data_stream_id":
+				ds.DataStreamID = f.AllowedValues[0]
+			case "data_stream_route":
+				ds.DataStreamRoute = f.AllowedValues[0]
+			}
+		}
+		streams = append(streams, ds)
+	}
+	slices.SortFunc(streams, func(a, b DataStreamConfig) int {
+		return cmp.Or(strings.Compare(a.DataStreamID, b.DataStreamID), strings.Compare(a.DataStreamRoute, b.DataStreamRoute))
+	})
+	return streams, nil
+}
+
+// ListAuthorizedDataStreams returns the data streams the sender in the context may upload to, by the same rules
+// SenderAuthorization applies when an upload is created.  Every data stream is returned when the request was not
+// authenticated.
+func (c *ConfigCache) ListAuthorizedDataStreams(ctx context.Context) ([]DataStreamConfig, error) {
+	streams, err := c.ListDataStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := oauth.FromContext(ctx)
+	if !ok {
+		return streams, nil
+	}
+	scopes := claims.Values(oauth.DataStreamsClaim)
+	return slices.DeleteFunc(streams, func(ds DataStreamConfig) bool {
+		return validation.AuthorizeDataStream(scopes, ds.DataStreamID, ds.DataStreamRoute) != nil || !ds.authorization.Allows(claims)
+	}), nil
+}
+
 func Uid() string {
 	return uuid.NewString()
 }
@@ -80,26 +216,28 @@ type SenderManifestVerification struct {
 	Configs *ConfigCache
 }
 
-func (v *SenderManifestVerification) verify(ctx context.Context, manifest handler.MetaData) error {
+// verify validates the manifest and returns the name and version of the config it was validated against.
+func (v *SenderManifestVerification) verify(ctx context.Context, manifest handler.MetaData) (string, string, error) {
 	logger := sloger.FromContext(ctx)
 
-	path, err := NewFromManifest(manifest) //GetConfigIdentifierByVersion(manifest)
+	path, err := NewFromManifest(manifest)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	c, err := v.Configs.GetConfig(ctx, strings.ToLower(path.Path()))
+	key := strings.ToLower(path.Path())
+	c, err := v.Configs.GetConfigForManifest(ctx, key, manifest)
 	if err != nil {
-		return err
+		return key, "", err
 	}
 	config := c.Metadata
-	logger.Info("checking config", "config", config)
+	logger.Info("checking config", "config", config, "version", c.Version)
 
 	var errs error
 	for _, field := range config.Fields {
 		err := field.Validate(manifest)
 		errs = errors.Join(errs, err)
 	}
-	return errs
+	return key, c.Version, errs
 }
 
 func (v *SenderManifestVerification) Verify(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
@@ -118,14 +256,16 @@ func (v *SenderManifestVerification) Verify(event *handler.HookEvent, resp hooks
 		reports.StageMetadataVerify,
 		tuid,
 		manifest,
-		reports.DispositionTypeAdd).SetStartTime(time.Now().UTC()).SetContent(reports.MetaDataVerifyContent{
+		reports.DispositionTypeAdd).SetStartTime(time.Now().UTC())
+	content := reports.MetaDataVerifyContent{
 		ReportContent: reports.ReportContent{
 			ContentSchemaVersion: "1.0.0",
 			ContentSchemaName:    reports.StageMetadataVerify,
 		},
 		Filename: metadata.GetFilename(manifest),
 		Metadata: manifest,
-	})
+	}
+	rb.SetContent(content)
 
 	defer func() {
 		rb.SetEndTime(time.Now().UTC())
@@ -135,36 +275,175 @@ func (v *SenderManifestVerification) Verify(event *handler.HookEvent, resp hooks
 		logger.Info("metadata-verify complete")
 	}()
 
-	if err := v.verify(event.Context, manifest); err != nil {
+	content.ConfigName, content.ConfigVersion, err = v.verify(event.Context, manifest)
+	rb.SetContent(content)
+	if err != nil {
 		logger.Info("validation errors and warnings", "errors", err)
+	}
 
+	failures := validation.Failures(err)
+	warnings := validation.Warnings(err)
+	for _, f := range failures {
+		rb.AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelError,
+			Message: f,
+		})
+	}
+	for _, w := range warnings {
+		rb.AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelWarning,
+			Message: w,
+		})
+	}
+
+	if errors.Is(err, validation.ErrFailure) {
+		rb.SetStatus(reports.StatusFailed)
+		resp.RejectUpload = true
+
+		respBody := PreCreateResponse{
+			UploadId:           tuid,
+			ValidationErrors:   failures,
+			ValidationWarnings: warnings,
+		}
+		b, err := json.Marshal(respBody)
+		if err != nil {
+			return resp, err
+		}
+		resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       string(b),
+		})
+		return resp, nil
+	}
+
+	if err != nil && !errors.Is(err, validation.ErrWarning) {
 		rb.SetStatus(reports.StatusFailed).AppendIssue(reports.ReportIssue{
 			Level:   reports.IssueLevelError,
 			Message: err.Error(),
 		})
+		return resp, err
+	}
 
-		if errors.Is(err, validation.ErrFailure) {
-			resp.RejectUpload = true
+	rb.SetStatus(reports.StatusSuccess)
 
-			respBody := PreCreateResponse{
-				UploadId:         tuid,
-				ValidationErrors: strings.Split(err.Error(), "\n"),
-			}
-			b, err := json.Marshal(respBody)
-			if err != nil {
-				return resp, err
-			}
-			resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
-				StatusCode: http.StatusBadRequest,
-				Body:       string(b),
-			})
+	if len(warnings) > 0 {
+		// Warnings are informational only; the status code is left to tusd so the upload is still created.
+		b, err := json.Marshal(PreCreateResponse{
+			UploadId:           tuid,
+			ValidationErrors:   []string{},
+			ValidationWarnings: warnings,
+		})
+		if err != nil {
+			return resp, err
+		}
+		resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+			Header: handler.HTTPHeader{
+				ValidationWarningsHeader: strconv.Itoa(len(warnings)),
+				"Content-Type":           "application/json",
+			},
+			Body: string(b),
+		})
+	}
+
+	return resp, nil
+}
+
+type SenderAuthorization struct {
+	Configs *ConfigCache
+}
+
+// Authorize rejects the upload with 403 when the sender identified by the validated token claims is limited to
+// other data streams, or when the authorization_config of the manifest's data stream does not allow the sender.
+// Every authenticated upload gets a sender-authorization report recording who sent it.  Requests that were not authenticated, such as
+// when auth is disabled, carry no claims and are not checked.
+func (a *SenderAuthorization) Authorize(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	claims, ok := oauth.FromContext(event.Context)
+	if !ok {
+		return resp, nil
+	}
+	tuid, err := GetUploadId(*event, resp)
+	if err != nil {
+		return resp, err
+	}
+
+	logger := sloger.FromContext(event.Context)
+	manifest := event.Upload.MetaData
+	if resp.ChangeFileInfo.MetaData != nil {
+		manifest = resp.ChangeFileInfo.MetaData
+	}
+
+	path, err := NewFromManifest(manifest)
+	if err != nil {
+		// validation reports the missing fields
+		return resp, nil
+	}
+	key := strings.ToLower(path.Path())
+	c, err := a.Configs.GetConfigForManifest(event.Context, key, manifest)
+	if err != nil {
+		if errors.Is(err, validation.ErrFailure) {
+			// validation reports the missing config
 			return resp, nil
 		}
 		return resp, err
 	}
+	scopes := claims.Values(oauth.DataStreamsClaim)
 
-	rb.SetStatus(reports.StatusSuccess)
+	logger.Info("starting sender-authorization", "subject", claims.Subject, "client_id", claims.ClientID)
+	rb := reports.NewBuilderWithManifest[reports.SenderAuthorizationContent](
+		"1.0.0",
+		reports.StageSenderAuthorization,
+		tuid,
+		manifest,
+		reports.DispositionTypeAdd).SetStartTime(time.Now().UTC()).SetContent(reports.SenderAuthorizationContent{
+		ReportContent: reports.ReportContent{
+			ContentSchemaVersion: "1.0.0",
+			ContentSchemaName:    reports.StageSenderAuthorization,
+		},
+		ConfigName: key,
+		Subject:    claims.Subject,
+		ClientID:   claims.ClientID,
+		Issuer:     claims.Issuer,
+	})
+	defer func() {
+		rb.SetEndTime(time.Now().UTC())
+		report := rb.Build()
+		logger.Info("REPORT sender-authorization", "report", report)
+		reports.Publish(event.Context, report)
+		logger.Info("sender-authorization complete")
+	}()
+
+	err = validation.AuthorizeDataStream(scopes, manifest["data_stream_id"], manifest["data_stream_route"])
+	if err == nil {
+		err = c.Authorization.Authorize(claims, manifest)
+	}
+	if err == nil {
+		rb.SetStatus(reports.StatusSuccess)
+		return resp, nil
+	}
+
+	var notAuthorized *validation.ErrorNotAuthorized
+	if !errors.As(err, &notAuthorized) {
+		rb.SetStatus(reports.StatusFailed).AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelError,
+			Message: err.Error(),
+		})
+		return resp, err
+	}
+	reasons := notAuthorized.Reasons
+	rb.SetStatus(reports.StatusFailed)
+	for _, r := range reasons {
+		rb.AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelError,
+			Message: r,
+		})
+	}
+	logger.Warn("sender not authorized for data stream", "config", key, "reasons", reasons)
 
+	resp.RejectUpload = true
+	resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       fmt.Sprintf("%s: %s\n", validation.ErrUnauthorized, notAuthorized),
+	})
 	return resp, nil
 }
 
@@ -181,12 +460,18 @@ type AzureMetadataAppender struct {
 
 type Appender interface {
 	Append(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error)
+	// Remove deletes what Append kept for an upload once the upload is terminated.
+	Remove(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error)
 }
 
 func (na *NoopAppender) Append(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
 	return resp, nil
 }
 
+func (na *NoopAppender) Remove(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	return resp, nil
+}
+
 func (fa *FileMetadataAppender) Append(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
 	manifest := event.Upload.MetaData
 	tuid, err := GetUploadId(*event, resp)
@@ -209,6 +494,20 @@ func (fa *FileMetadataAppender) Append(event *handler.HookEvent, resp hooks.Hook
 	return resp, nil
 }
 
+func (fa *FileMetadataAppender) Remove(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	tuid, err := GetUploadId(*event, resp)
+	if err != nil {
+		return resp, err
+	}
+
+	// uploads terminated before they finish never had their metadata appended
+	err = os.Remove(filepath.Join(fa.Path, tuid+".meta"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return resp, err
+	}
+	return resp, nil
+}
+
 func (aa *AzureMetadataAppender) Append(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
 	tuid, err := GetUploadId(*event, resp)
 	if err != nil {
@@ -230,6 +529,11 @@ func (aa *AzureMetadataAppender) Append(event *handler.HookEvent, resp hooks.Hoo
 	return resp, nil
 }
 
+// Remove does nothing, as the metadata is set on the upload's blob, which tusd deletes when it is terminated.
+func (aa *AzureMetadataAppender) Remove(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	return resp, nil
+}
+
 func WithUploadId(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
 	tuid := Uid()
 	resp.ChangeFileInfo.ID = tuid
@@ -237,7 +541,14 @@ func WithUploadId(event *handler.HookEvent, resp hooks.HookResponse) (hooks.Hook
 	return resp, nil
 }
 
-func WithPreCreateManifestTransforms(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+type ManifestTransformer struct {
+	Configs *ConfigCache
+}
+
+// Transform stamps the server assigned fields, including the authenticated principal, on the manifest and then
+// applies the transform_config of the manifest's data stream.  If the transforms move the upload to another data
+// stream, that stream's transforms are applied as well.
+func (t *ManifestTransformer) Transform(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
 	tuid, err := GetUploadId(*event, resp)
 	if err != nil {
 		return resp, err
@@ -252,6 +563,35 @@ func WithPreCreateManifestTransforms(event *handler.HookEvent, resp hooks.HookRe
 	manifest := event.Upload.MetaData
 	manifest["dex_ingest_datetime"] = timestamp
 	manifest["upload_id"] = tuid
+
+	transforms := []reports.MetadataTransformContent{
+		{Action: "update", Field: "ID", Value: tuid},
+		{Action: "append", Field: "dex_ingest_datetime", Value: timestamp},
+		{Action: "append", Field: "upload_id", Value: tuid},
+	}
+
+	// the principal can only come from the authenticated request, never from Upload-Metadata
+	for _, key := range metadata.PrincipalKeys {
+		if _, ok := manifest[key]; ok {
+			logger.Warn("dropping sender supplied principal field", "field", key)
+			delete(manifest, key)
+			transforms = append(transforms, reports.MetadataTransformContent{Action: "remove", Field: key})
+		}
+	}
+	if claims, ok := oauth.FromContext(event.Context); ok && claims.Method != "" {
+		fields := claims.Principal().Fields()
+		for _, key := range metadata.PrincipalKeys {
+			value := fields[key]
+			manifest[key] = value
+			transforms = append(transforms, reports.MetadataTransformContent{Action: "append", Field: key, Value: value})
+		}
+	}
+
+	configTransforms, err := t.applyConfigTransforms(event.Context, manifest)
+	transforms = append(transforms, configTransforms...)
+	if err != nil {
+		return resp, err
+	}
 	resp.ChangeFileInfo.MetaData = manifest
 
 	report := reports.NewBuilderWithManifest[reports.BulkMetadataTransformReportContent](
@@ -264,11 +604,7 @@ func WithPreCreateManifestTransforms(event *handler.HookEvent, resp hooks.HookRe
 			ContentSchemaVersion: "1.0.0",
 			ContentSchemaName:    reports.StageMetadataTransform,
 		},
-		Transforms: []reports.MetadataTransformContent{
-			{Action: "update", Field: "ID", Value: tuid},
-			{Action: "append", Field: "dex_ingest_datetime", Value: timestamp},
-			{Action: "append", Field: "upload_id", Value: tuid},
-		},
+		Transforms: transforms,
 	}).Build()
 
 	logger.Info("REPORT metadata-transform", "report", report)
@@ -278,6 +614,45 @@ func WithPreCreateManifestTransforms(event *handler.HookEvent, resp hooks.HookRe
 	return resp, nil
 }
 
+func (t *ManifestTransformer) applyConfigTransforms(ctx context.Context, manifest handler.MetaData) ([]reports.MetadataTransformContent, error) {
+	logger := sloger.FromContext(ctx)
+	var transforms []reports.MetadataTransformContent
+	visited := map[string]bool{}
+	for {
+		path, err := NewFromManifest(manifest)
+		if err != nil {
+			// validation reports the missing fields
+			return transforms, nil
+		}
+		key := strings.ToLower(path.Path())
+		if visited[key] {
+			return transforms, nil
+		}
+		visited[key] = true
+
+		c, err := t.Configs.GetConfigForManifest(ctx, key, manifest)
+		if err != nil {
+			if errors.Is(err, validation.ErrFailure) {
+				// validation reports the missing config
+				return transforms, nil
+			}
+			return transforms, err
+		}
+
+		results, err := c.Transform.Apply(manifest)
+		for _, r := range results {
+			transforms = append(transforms, reports.MetadataTransformContent{Action: r.Action, Field: r.Field, Value: r.Value})
+		}
+		if err != nil {
+			return transforms, err
+		}
+		logger.Info("applied manifest config transforms", "config", key, "count", len(results))
+		if len(results) == 0 {
+			return transforms, nil
+		}
+	}
+}
+
 func GetUploadId(event handler.HookEvent, resp hooks.HookResponse) (string, error) {
 	tuid := event.Upload.ID
 	if resp.ChangeFileInfo.ID != "" {
@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+// MaxSizeHeader advertises the largest upload the data stream accepts, in bytes.
+const MaxSizeHeader = "Upload-Max-Size"
+
+// SizeLimit enforces the max_size_bytes of each data stream.  Partial uploads of a concatenation carry no manifest,
+// so they are only limited by the max size of the tus handler.
+type SizeLimit struct {
+	Configs *ConfigCache
+}
+
+// maxSize returns the limit of the manifest's data stream, or 0 when it has none.  Uploads are not limited when
+// their config can not be found, which validation rejects when they are created.
+func (s *SizeLimit) maxSize(ctx context.Context, manifest map[string]string) int64 {
+	path, err := NewFromManifest(manifest)
+	if err != nil {
+		return 0
+	}
+	c, err := s.Configs.GetConfigForManifest(ctx, strings.ToLower(path.Path()), manifest)
+	if err != nil {
+		sloger.FromContext(ctx).Warn("unable to get max upload size", "error", err)
+		return 0
+	}
+	return c.MaxSizeBytes
+}
+
+func tooLarge(size int64, limit int64) string {
+	return fmt.Sprintf("upload of %d bytes exceeds the maximum size of %d bytes for the data stream", size, limit)
+}
+
+func maxSizeResponse(limit int64) handler.HTTPResponse {
+	return handler.HTTPResponse{
+		Header: handler.HTTPHeader{MaxSizeHeader: strconv.FormatInt(limit, 10)},
+	}
+}
+
+// Check advertises the limit of the data stream on the creation response, and rejects the upload with 413 when its
+// Upload-Length is over it.
+func (s *SizeLimit) Check(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	manifest := event.Upload.MetaData
+	if resp.ChangeFileInfo.MetaData != nil {
+		manifest = resp.ChangeFileInfo.MetaData
+	}
+	limit := s.maxSize(event.Context, manifest)
+	if limit <= 0 {
+		return resp, nil
+	}
+
+	r := maxSizeResponse(limit)
+	if !event.Upload.SizeIsDeferred && event.Upload.Size > limit {
+		sloger.FromContext(event.Context).Warn("upload too large for data stream", "size", event.Upload.Size, "max_size", limit)
+		resp.RejectUpload = true
+		r.StatusCode = http.StatusRequestEntityTooLarge
+		r.Body = tooLarge(event.Upload.Size, limit) + "\n"
+	}
+	resp.HTTPResponse = resp.HTTPResponse.MergeWith(r)
+	return resp, nil
+}
+
+// Enforce stops and terminates uploads that have grown over the limit of their data stream, or that declared a
+// length over it once they started without one.  tusd runs it while data is received, about once a second.
+func (s *SizeLimit) Enforce(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	limit := s.maxSize(event.Context, event.Upload.MetaData)
+	if limit <= 0 {
+		return resp, nil
+	}
+
+	size := event.Upload.Offset
+	if !event.Upload.SizeIsDeferred {
+		size = max(size, event.Upload.Size)
+	}
+	if size <= limit {
+		return resp, nil
+	}
+	sloger.FromContext(event.Context).Warn("stopping upload too large for data stream", "size", size, "max_size", limit)
+	resp.StopUpload = true
+	r := maxSizeResponse(limit)
+	r.StatusCode = http.StatusRequestEntityTooLarge
+	r.Body = tooLarge(size, limit) + "\n"
+	resp.HTTPResponse = resp.HTTPResponse.MergeWith(r)
+	return resp, nil
+}
+
+// Finish fails uploads over the limit of their data stream that were received between two runs of Enforce, so they
+// are never delivered.
+func (s *SizeLimit) Finish(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	limit := s.maxSize(event.Context, event.Upload.MetaData)
+	if limit <= 0 || event.Upload.Size <= limit {
+		return resp, nil
+	}
+	sloger.FromContext(event.Context).Warn("upload too large for data stream", "size", event.Upload.Size, "max_size", limit)
+	return resp, handler.NewError("ERR_UPLOAD_TOO_LARGE", tooLarge(event.Upload.Size, limit), http.StatusRequestEntityTooLarge)
+}
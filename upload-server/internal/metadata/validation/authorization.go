@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+var ErrUnauthorized = errors.New("sender not authorized")
+
+// Claims is the validated identity of the sender, e.g. oauth.Claims.
+type Claims interface {
+	Values(name string) []string
+}
+
+// AuthorizationConfig limits who may upload to a data stream route.  When Rules is empty any authenticated
+// sender is allowed; otherwise at least one rule must allow the sender.
+type AuthorizationConfig struct {
+	Rules []AuthorizationRule `json:"rules"`
+}
+
+// AuthorizationRule grants the senders whose claims match every condition in Claims.  SenderIDs and
+// Jurisdictions limit the values those senders may put in the manifest, and SenderIDClaim and
+// JurisdictionClaim require the manifest value to be one of the values of the named claim.
+type AuthorizationRule struct {
+	Claims            []ClaimCondition `json:"claims"`
+	SenderIDs         []string         `json:"sender_ids"`
+	Jurisdictions     []string         `json:"jurisdictions"`
+	SenderIDClaim     string           `json:"sender_id_claim"`
+	JurisdictionClaim string           `json:"jurisdiction_claim"`
+}
+
+// ClaimCondition matches when the named claim holds one of Values.  Array claims such as groups match when
+// any element does.
+type ClaimCondition struct {
+	Claim  string   `json:"claim"`
+	Values []string `json:"values"`
+}
+
+func (c *ClaimCondition) Matches(claims Claims) bool {
+	return slices.ContainsFunc(claims.Values(c.Claim), func(v string) bool {
+		return slices.Contains(c.Values, v)
+	})
+}
+
+type ErrorNotAuthorized struct {
+	Reasons []string
+}
+
+func (e *ErrorNotAuthorized) Error() string {
+	return strings.Join(e.Reasons, "; ")
+}
+
+func (ac *AuthorizationConfig) IsEmpty() bool {
+	return len(ac.Rules) == 0
+}
+
+// Check reports rules that could never match.
+func (ac *AuthorizationConfig) Check() error {
+	var errs error
+	for i, r := range ac.Rules {
+		for _, c := range r.Claims {
+			if c.Claim == "" {
+				errs = errors.Join(errs, fmt.Errorf("authorization rule %d has a claim condition without a claim name", i))
+			}
+			if len(c.Values) == 0 {
+				errs = errors.Join(errs, fmt.Errorf("authorization rule %d has no values for claim %s", i, c.Claim))
+			}
+		}
+	}
+	return errs
+}
+
+// Authorize returns ErrUnauthorized joined with an ErrorNotAuthorized listing why each rule rejected the sender
+// when no rule allows the sender to submit the manifest.
+func (ac *AuthorizationConfig) Authorize(claims Claims, manifest map[string]string) error {
+	if ac.IsEmpty() {
+		return nil
+	}
+	var reasons []string
+	for _, r := range ac.Rules {
+		reason := r.reject(claims, manifest)
+		if reason == "" {
+			return nil
+		}
+		if !slices.Contains(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+	return errors.Join(ErrUnauthorized, &ErrorNotAuthorized{Reasons: reasons})
+}
+
+// Allows reports whether the sender could be authorized to submit some manifest, that is whether a rule's claim
+// conditions match and it leaves a sender_id and jurisdiction the sender may put in the manifest.
+func (ac *AuthorizationConfig) Allows(claims Claims) bool {
+	if ac.IsEmpty() {
+		return true
+	}
+	return slices.ContainsFunc(ac.Rules, func(r AuthorizationRule) bool {
+		for _, c := range r.Claims {
+			if !c.Matches(claims) {
+				return false
+			}
+		}
+		return allowsValue(r.SenderIDs, r.SenderIDClaim, claims) && allowsValue(r.Jurisdictions, r.JurisdictionClaim, claims)
+	})
+}
+
+func allowsValue(allowed []string, claim string, claims Claims) bool {
+	if claim == "" {
+		return true
+	}
+	values := claims.Values(claim)
+	if len(allowed) == 0 {
+		return len(values) > 0
+	}
+	return slices.ContainsFunc(values, func(v string) bool {
+		return slices.Contains(allowed, v)
+	})
+}
+
+// AuthorizeDataStream returns ErrUnauthorized when scopes is not empty and none of its "<data_stream_id>/<data_stream_route>"
+// globs match the data stream route.
+func AuthorizeDataStream(scopes []string, dataStreamID string, dataStreamRoute string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+	stream := strings.ToLower(dataStreamID + "/" + dataStreamRoute)
+	for _, scope := range scopes {
+		if ok, err := path.Match(strings.ToLower(scope), stream); err == nil && ok {
+			return nil
+		}
+	}
+	return errors.Join(ErrUnauthorized, &ErrorNotAuthorized{Reasons: []string{
+		fmt.Sprintf("data stream %s/%s is outside the scope of this sender", dataStreamID, dataStreamRoute),
+	}})
+}
+
+// reject returns why the rule does not allow the sender, or an empty string when it does.
+func (r *AuthorizationRule) reject(claims Claims, manifest map[string]string) string {
+	for _, c := range r.Claims {
+		if !c.Matches(claims) {
+			return fmt.Sprintf("claim %s is not one of [%s]", c.Claim, strings.Join(c.Values, ", "))
+		}
+	}
+	if reason := rejectValue("sender_id", manifest["sender_id"], r.SenderIDs, r.SenderIDClaim, claims); reason != "" {
+		return reason
+	}
+	return rejectValue("jurisdiction", manifest["jurisdiction"], r.Jurisdictions, r.JurisdictionClaim, claims)
+}
+
+func rejectValue(field string, value string, allowed []string, claim string, claims Claims) string {
+	if len(allowed) > 0 && !slices.Contains(allowed, value) {
+		return fmt.Sprintf("%s %s is not allowed for this sender", field, value)
+	}
+	if claim != "" && !slices.Contains(claims.Values(claim), value) {
+		return fmt.Sprintf("%s %s does not match claim %s", field, value, claim)
+	}
+	return ""
+}
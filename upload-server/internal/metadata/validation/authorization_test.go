@@ -0,0 +1,141 @@
+package validation_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+)
+
+type testClaims map[string][]string
+
+func (c testClaims) Values(name string) []string {
+	return c[name]
+}
+
+func TestAuthorizationConfigAuthorize(t *testing.T) {
+	ac := validation.AuthorizationConfig{
+		Rules: []validation.AuthorizationRule{
+			{
+				Claims:            []validation.ClaimCondition{{Claim: "groups", Values: []string{"state-submitters"}}},
+				JurisdictionClaim: "jurisdiction",
+			},
+			{
+				Claims:    []validation.ClaimCondition{{Claim: "client_id", Values: []string{"cdc-internal"}}},
+				SenderIDs: []string{"CDC"},
+			},
+		},
+	}
+
+	type testCase struct {
+		name       string
+		claims     testClaims
+		manifest   map[string]string
+		authorized bool
+	}
+	testCases := []testCase{
+		{
+			"jurisdiction matches claim",
+			testClaims{"groups": {"users", "state-submitters"}, "jurisdiction": {"GA"}},
+			map[string]string{"sender_id": "GA-DOH", "jurisdiction": "GA"},
+			true,
+		},
+		{
+			"jurisdiction submitting as another",
+			testClaims{"groups": {"state-submitters"}, "jurisdiction": {"GA"}},
+			map[string]string{"sender_id": "GA-DOH", "jurisdiction": "FL"},
+			false,
+		},
+		{
+			"allowed sender id",
+			testClaims{"client_id": {"cdc-internal"}},
+			map[string]string{"sender_id": "CDC", "jurisdiction": "FL"},
+			true,
+		},
+		{
+			"disallowed sender id",
+			testClaims{"client_id": {"cdc-internal"}},
+			map[string]string{"sender_id": "GA-DOH", "jurisdiction": "GA"},
+			false,
+		},
+		{
+			"no matching claims",
+			testClaims{"sub": {"someone"}},
+			map[string]string{"sender_id": "CDC"},
+			false,
+		},
+	}
+	for _, c := range testCases {
+		err := ac.Authorize(c.claims, c.manifest)
+		if c.authorized && err != nil {
+			t.Errorf("%s: expected sender to be authorized but got %v", c.name, err)
+		}
+		if !c.authorized && !errors.Is(err, validation.ErrUnauthorized) {
+			t.Errorf("%s: expected %v but got %v", c.name, validation.ErrUnauthorized, err)
+		}
+	}
+
+	err := ac.Authorize(testClaims{"groups": {"state-submitters"}, "jurisdiction": {"GA"}}, map[string]string{"sender_id": "GA-DOH", "jurisdiction": "FL"})
+	var notAuthorized *validation.ErrorNotAuthorized
+	if !errors.As(err, &notAuthorized) {
+		t.Fatalf("expected reasons for the rejection but got %v", err)
+	}
+	expected := []string{
+		"jurisdiction FL does not match claim jurisdiction",
+		"claim client_id is not one of [cdc-internal]",
+	}
+	if !slices.Equal(notAuthorized.Reasons, expected) {
+		t.Errorf("expected reasons %q but got %q", expected, notAuthorized.Reasons)
+	}
+}
+
+func TestAuthorizationConfigAllows(t *testing.T) {
+	ac := validation.AuthorizationConfig{
+		Rules: []validation.AuthorizationRule{
+			{
+				Claims:            []validation.ClaimCondition{{Claim: "groups", Values: []string{"state-submitters"}}},
+				Jurisdictions:     []string{"GA", "FL"},
+				JurisdictionClaim: "jurisdiction",
+			},
+		},
+	}
+	testCases := map[string]struct {
+		claims  testClaims
+		allowed bool
+	}{
+		"matching claims":              {testClaims{"groups": {"state-submitters"}, "jurisdiction": {"GA"}}, true},
+		"no matching group":            {testClaims{"groups": {"users"}, "jurisdiction": {"GA"}}, false},
+		"no jurisdiction claim":        {testClaims{"groups": {"state-submitters"}}, false},
+		"jurisdiction not in the list": {testClaims{"groups": {"state-submitters"}, "jurisdiction": {"TX"}}, false},
+	}
+	for name, c := range testCases {
+		if got := ac.Allows(c.claims); got != c.allowed {
+			t.Errorf("%s: expected allowed %t but got %t", name, c.allowed, got)
+		}
+	}
+	empty := validation.AuthorizationConfig{}
+	if !empty.Allows(testClaims{}) {
+		t.Error("expected no rules to allow every sender")
+	}
+}
+
+func TestEmptyAuthorizationConfigAllowsAll(t *testing.T) {
+	ac := validation.AuthorizationConfig{}
+	if err := ac.Authorize(testClaims{}, map[string]string{"sender_id": "anyone"}); err != nil {
+		t.Errorf("expected no rules to allow every sender but got %v", err)
+	}
+}
+
+func TestAuthorizeDataStream(t *testing.T) {
+	scopes := []string{"dextesting/*", "ndlp/routineImmunization"}
+	if err := validation.AuthorizeDataStream(scopes, "dextesting", "testevent1"); err != nil {
+		t.Errorf("expected glob scope to allow the route but got %v", err)
+	}
+	if err := validation.AuthorizeDataStream(scopes, "ndlp", "aplhealth"); !errors.Is(err, validation.ErrUnauthorized) {
+		t.Errorf("expected %v but got %v", validation.ErrUnauthorized, err)
+	}
+	if err := validation.AuthorizeDataStream(nil, "ndlp", "aplhealth"); err != nil {
+		t.Errorf("expected no scopes to allow every data stream but got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DuplicatePolicyAllow  = "allow"
+	DuplicatePolicyWarn   = "warn"
+	DuplicatePolicyReject = "reject"
+)
+
+// DefaultDuplicateWindowDays is how far back duplicates are looked for when the config does not say.
+const DefaultDuplicateWindowDays = 7
+
+// DuplicateConfig decides what happens to an upload with the same content as one received by the data stream
+// route within the window.  Duplicates are allowed when no policy is set.
+type DuplicateConfig struct {
+	Policy     string `json:"policy"`
+	WindowDays int    `json:"window_days"`
+}
+
+func (dc *DuplicateConfig) Window() time.Duration {
+	days := dc.WindowDays
+	if days <= 0 {
+		days = DefaultDuplicateWindowDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (dc *DuplicateConfig) Check() error {
+	switch dc.Policy {
+	case "", DuplicatePolicyAllow, DuplicatePolicyWarn, DuplicatePolicyReject:
+	default:
+		return fmt.Errorf("unknown duplicate policy %s", dc.Policy)
+	}
+	if dc.WindowDays < 0 {
+		return fmt.Errorf("negative duplicate window of %d days", dc.WindowDays)
+	}
+	return nil
+}
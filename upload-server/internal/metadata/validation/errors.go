@@ -3,6 +3,8 @@ package validation
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 )
 
 var (
@@ -12,10 +14,14 @@ var (
 )
 
 type ErrorMissing struct {
-	Field string
+	Field     string
+	Condition string
 }
 
 func (e *ErrorMissing) Error() string {
+	if e.Condition != "" {
+		return fmt.Sprintf("field %s was missing and is required when %s", e.Field, e.Condition)
+	}
 	return fmt.Sprintf("field %s was missing", e.Field)
 }
 
@@ -27,3 +33,116 @@ type ErrorNotAnAllowedValue struct {
 func (e *ErrorNotAnAllowedValue) Error() string {
 	return fmt.Sprintf("%s had disallowed value %s", e.field, e.value)
 }
+
+type ErrorInvalidType struct {
+	Field string
+	Value string
+	Type  string
+}
+
+func (e *ErrorInvalidType) Error() string {
+	return fmt.Sprintf("%s had value %s which is not a valid %s", e.Field, e.Value, e.Type)
+}
+
+type ErrorPatternMismatch struct {
+	Field   string
+	Value   string
+	Pattern string
+}
+
+func (e *ErrorPatternMismatch) Error() string {
+	return fmt.Sprintf("%s had value %s which does not match pattern %s", e.Field, e.Value, e.Pattern)
+}
+
+type ErrorLength struct {
+	Field  string
+	Length int
+	Min    *int
+	Max    *int
+}
+
+func (e *ErrorLength) Error() string {
+	return fmt.Sprintf("%s had length %d which is not within %s", e.Field, e.Length, bounds(e.Min, e.Max, strconv.Itoa))
+}
+
+type ErrorOutOfRange struct {
+	Field string
+	Value string
+	Min   *float64
+	Max   *float64
+}
+
+func (e *ErrorOutOfRange) Error() string {
+	return fmt.Sprintf("%s had value %s which is not within %s", e.Field, e.Value, bounds(e.Min, e.Max, func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}))
+}
+
+type ErrorDeprecated struct {
+	Field string
+}
+
+func (e *ErrorDeprecated) Error() string {
+	return fmt.Sprintf("field %s is deprecated", e.Field)
+}
+
+func bounds[T any](lo *T, hi *T, format func(T) string) string {
+	lower, upper := "*", "*"
+	if lo != nil {
+		lower = format(*lo)
+	}
+	if hi != nil {
+		upper = format(*hi)
+	}
+	return fmt.Sprintf("[%s, %s]", lower, upper)
+}
+
+// Violations flattens a joined validation error into one message per violation,
+// dropping the ErrFailure and ErrWarning markers.
+func Violations(err error) []string {
+	return violations(err, nil)
+}
+
+// Failures returns the messages of the violations that should reject an upload.
+func Failures(err error) []string {
+	return violations(err, ErrFailure)
+}
+
+// Warnings returns the messages of the violations that should be reported without rejecting an upload.
+func Warnings(err error) []string {
+	return violations(err, ErrWarning)
+}
+
+func violations(err error, marker error) []string {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		if marker == nil || errors.Is(err, marker) {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+	var msgs []string
+	errs := joined.Unwrap()
+	isViolation := slices.ContainsFunc(errs, isMarker)
+	if isViolation && marker != nil && !slices.Contains(errs, marker) {
+		return nil
+	}
+	for _, e := range errs {
+		if isMarker(e) {
+			continue
+		}
+		if isViolation {
+			msgs = append(msgs, e.Error())
+			continue
+		}
+		msgs = append(msgs, violations(e, marker)...)
+	}
+	return msgs
+}
+
+func isMarker(err error) bool {
+	return err == ErrFailure || err == ErrWarning
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+)
+
+const (
+	TransformActionAppend = "append"
+	TransformActionUpdate = "update"
+	TransformActionRemove = "remove"
+)
+
+// ReservedFields are stamped by the server on every upload and cannot be targeted by a transform.
+var ReservedFields = append([]string{"dex_ingest_datetime", "upload_id"}, metadata.PrincipalKeys...)
+
+// TransformConfig describes how a sender manifest is rewritten before it is validated.
+// Transforms are applied in the order renames, defaults, mappings, derived, drop.
+type TransformConfig struct {
+	Renames  []RenameTransform  `json:"renames"`
+	Defaults []DefaultTransform `json:"defaults"`
+	Mappings []MappingTransform `json:"mappings"`
+	Derived  []DerivedTransform `json:"derived"`
+	Drop     []string           `json:"drop"`
+}
+
+type RenameTransform struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type DefaultTransform struct {
+	FieldName string `json:"field_name"`
+	Value     string `json:"value"`
+}
+
+// MappingTransform replaces the value of a field using a lookup table.
+// Values not in the table are left as they are unless Default is set.
+type MappingTransform struct {
+	FieldName string            `json:"field_name"`
+	Values    map[string]string `json:"values"`
+	Default   *string           `json:"default"`
+}
+
+// DerivedTransform sets a field from a text/template executed against the manifest, e.g. "{{.sender_id}}-{{.jurisdiction}}".
+type DerivedTransform struct {
+	FieldName string `json:"field_name"`
+	Template  string `json:"template"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+type TransformResult struct {
+	Action string
+	Field  string
+	Value  string
+}
+
+func (tc *TransformConfig) IsEmpty() bool {
+	return len(tc.Renames) == 0 && len(tc.Defaults) == 0 && len(tc.Mappings) == 0 && len(tc.Derived) == 0 && len(tc.Drop) == 0
+}
+
+// Check reports transforms that target reserved fields or have templates that do not parse.
+func (tc *TransformConfig) Check() error {
+	var errs error
+	var targets []string
+	for _, r := range tc.Renames {
+		targets = append(targets, r.From, r.To)
+	}
+	for _, d := range tc.Defaults {
+		targets = append(targets, d.FieldName)
+	}
+	for _, m := range tc.Mappings {
+		targets = append(targets, m.FieldName)
+	}
+	for _, d := range tc.Derived {
+		targets = append(targets, d.FieldName)
+		if _, err := template.New(d.FieldName).Parse(d.Template); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("invalid template configured for derived field %s: %w", d.FieldName, err))
+		}
+	}
+	targets = append(targets, tc.Drop...)
+	for _, t := range targets {
+		if t == "" {
+			errs = errors.Join(errs, errors.New("transform configured without a field name"))
+		}
+		if slices.Contains(ReservedFields, t) {
+			errs = errors.Join(errs, fmt.Errorf("transform configured for reserved field %s", t))
+		}
+	}
+	return errs
+}
+
+// Apply rewrites the manifest in place and returns a record of every change made.
+func (tc *TransformConfig) Apply(manifest map[string]string) ([]TransformResult, error) {
+	var results []TransformResult
+
+	for _, r := range tc.Renames {
+		value, ok := manifest[r.From]
+		if !ok {
+			continue
+		}
+		if _, exists := manifest[r.To]; exists {
+			// never clobber a value the sender supplied under the new name
+			continue
+		}
+		delete(manifest, r.From)
+		manifest[r.To] = value
+		results = append(results,
+			TransformResult{Action: TransformActionRemove, Field: r.From},
+			TransformResult{Action: TransformActionAppend, Field: r.To, Value: value},
+		)
+	}
+
+	for _, d := range tc.Defaults {
+		if _, ok := manifest[d.FieldName]; ok {
+			continue
+		}
+		manifest[d.FieldName] = d.Value
+		results = append(results, TransformResult{Action: TransformActionAppend, Field: d.FieldName, Value: d.Value})
+	}
+
+	for _, m := range tc.Mappings {
+		value, ok := manifest[m.FieldName]
+		if !ok {
+			continue
+		}
+		mapped, found := m.Values[value]
+		if !found {
+			if m.Default == nil {
+				continue
+			}
+			mapped = *m.Default
+		}
+		if mapped == value {
+			continue
+		}
+		manifest[m.FieldName] = mapped
+		results = append(results, TransformResult{Action: TransformActionUpdate, Field: m.FieldName, Value: mapped})
+	}
+
+	for _, d := range tc.Derived {
+		_, exists := manifest[d.FieldName]
+		if exists && !d.Overwrite {
+			continue
+		}
+		tmpl, err := template.New(d.FieldName).Option("missingkey=zero").Parse(d.Template)
+		if err != nil {
+			return results, fmt.Errorf("invalid template configured for derived field %s: %w", d.FieldName, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, manifest); err != nil {
+			return results, fmt.Errorf("failed to derive field %s: %w", d.FieldName, err)
+		}
+		action := TransformActionAppend
+		if exists {
+			action = TransformActionUpdate
+		}
+		manifest[d.FieldName] = b.String()
+		results = append(results, TransformResult{Action: action, Field: d.FieldName, Value: b.String()})
+	}
+
+	for _, field := range tc.Drop {
+		if _, ok := manifest[field]; !ok {
+			continue
+		}
+		delete(manifest, field)
+		results = append(results, TransformResult{Action: TransformActionRemove, Field: field})
+	}
+
+	return results, nil
+}
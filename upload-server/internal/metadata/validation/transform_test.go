@@ -0,0 +1,102 @@
+package validation_test
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+)
+
+func TestTransformConfigApply(t *testing.T) {
+	tc := validation.TransformConfig{
+		Renames: []validation.RenameTransform{
+			{From: "meta_ext_filename", To: "received_filename"},
+			{From: "meta_destination_id", To: "data_stream_id"},
+			{From: "meta_ext_event", To: "data_stream_route"},
+		},
+		Defaults: []validation.DefaultTransform{
+			{FieldName: "version", Value: "2.0"},
+			{FieldName: "sender_id", Value: "unknown"},
+		},
+		Mappings: []validation.MappingTransform{
+			{FieldName: "data_stream_id", Values: map[string]string{"ndlp": "dextesting"}},
+			{FieldName: "data_stream_route", Values: map[string]string{"routineImmunization": "testevent1"}},
+		},
+		Derived: []validation.DerivedTransform{
+			{FieldName: "data_producer_id", Template: "{{.sender_id}}-{{.jurisdiction}}"},
+		},
+		Drop: []string{"meta_ext_source"},
+	}
+
+	manifest := map[string]string{
+		"meta_destination_id": "ndlp",
+		"meta_ext_event":      "routineImmunization",
+		"meta_ext_filename":   "test.csv",
+		"meta_ext_source":     "legacy",
+		"sender_id":           "sender",
+		"jurisdiction":        "GA",
+	}
+
+	results, err := tc.Apply(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+		"received_filename": "test.csv",
+		"version":           "2.0",
+		"sender_id":         "sender",
+		"jurisdiction":      "GA",
+		"data_producer_id":  "sender-GA",
+	}
+	if !maps.Equal(manifest, expected) {
+		t.Errorf("expected manifest %v but got %v", expected, manifest)
+	}
+
+	expectedResults := []validation.TransformResult{
+		{Action: validation.TransformActionRemove, Field: "meta_ext_filename"},
+		{Action: validation.TransformActionAppend, Field: "received_filename", Value: "test.csv"},
+		{Action: validation.TransformActionRemove, Field: "meta_destination_id"},
+		{Action: validation.TransformActionAppend, Field: "data_stream_id", Value: "ndlp"},
+		{Action: validation.TransformActionRemove, Field: "meta_ext_event"},
+		{Action: validation.TransformActionAppend, Field: "data_stream_route", Value: "routineImmunization"},
+		{Action: validation.TransformActionAppend, Field: "version", Value: "2.0"},
+		{Action: validation.TransformActionUpdate, Field: "data_stream_id", Value: "dextesting"},
+		{Action: validation.TransformActionUpdate, Field: "data_stream_route", Value: "testevent1"},
+		{Action: validation.TransformActionAppend, Field: "data_producer_id", Value: "sender-GA"},
+		{Action: validation.TransformActionRemove, Field: "meta_ext_source"},
+	}
+	if !slices.Equal(results, expectedResults) {
+		t.Errorf("expected transforms %+v but got %+v", expectedResults, results)
+	}
+}
+
+func TestTransformConfigRenameKeepsExistingValue(t *testing.T) {
+	tc := validation.TransformConfig{
+		Renames: []validation.RenameTransform{{From: "meta_ext_filename", To: "received_filename"}},
+	}
+	manifest := map[string]string{
+		"meta_ext_filename": "old.csv",
+		"received_filename": "new.csv",
+	}
+	results, err := tc.Apply(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 || manifest["received_filename"] != "new.csv" {
+		t.Errorf("expected sender supplied value to be kept but got %v", manifest)
+	}
+}
+
+func TestTransformConfigCheck(t *testing.T) {
+	tc := validation.TransformConfig{
+		Drop:    []string{"upload_id"},
+		Derived: []validation.DerivedTransform{{FieldName: "x", Template: "{{.bad"}},
+	}
+	if err := tc.Check(); err == nil {
+		t.Error("expected reserved field and bad template to fail the check")
+	}
+}
@@ -2,14 +2,60 @@ package validation
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// ManifestConfig is the config of a data stream route.  When Versions is set, the config to apply to an upload
+// is chosen from them with Select and the other top level sections are ignored.
 type ManifestConfig struct {
-	Metadata MetadataConfig `json:"metadata_config"`
-	Copy     CopyConfig     `json:"copy_config"`
+	Version        string          `json:"version"`
+	EffectiveFrom  *time.Time      `json:"effective_from"`
+	EffectiveUntil *time.Time      `json:"effective_until"`
+	Metadata       MetadataConfig  `json:"metadata_config"`
+	Copy           CopyConfig      `json:"copy_config"`
+	Transform      TransformConfig `json:"transform_config"`
+	Versions       []ConfigVersion `json:"versions"`
+	// Authorization applies to every version of the config.
+	Authorization AuthorizationConfig `json:"authorization_config"`
+	// Duplicates applies to every version of the config.
+	Duplicates DuplicateConfig `json:"duplicate_config"`
+	// MaxSizeBytes limits the size of every upload to the data stream route when it is set, whatever the version.
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+}
+
+// Check reports configuration mistakes that would otherwise only surface while validating an upload.
+func (mc *ManifestConfig) Check() error {
+	errs := errors.Join(mc.Transform.Check(), mc.Authorization.Check(), mc.Duplicates.Check(), mc.checkVersions())
+	if mc.MaxSizeBytes < 0 {
+		errs = errors.Join(errs, fmt.Errorf("negative max size of %d bytes", mc.MaxSizeBytes))
+	}
+	for i := range mc.Metadata.Fields {
+		fc := &mc.Metadata.Fields[i]
+		if fc.Type != "" {
+			if _, ok := typeCheckers[fc.Type]; !ok {
+				errs = errors.Join(errs, fmt.Errorf("unknown type %s configured for field %s", fc.Type, fc.FieldName))
+			}
+		}
+		if fc.Pattern != "" {
+			re, err := regexp.Compile(fc.Pattern)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("invalid pattern configured for field %s: %w", fc.FieldName, err))
+			}
+			fc.pattern = re
+		}
+		if fc.Severity != "" && fc.Severity != SeverityError && fc.Severity != SeverityWarning {
+			errs = errors.Join(errs, fmt.Errorf("unknown severity %s configured for field %s", fc.Severity, fc.FieldName))
+		}
+	}
+	return errs
 }
 
 type MetadataConfig struct {
@@ -24,10 +70,84 @@ type CopyConfig struct {
 }
 
 type FieldConfig struct {
-	FieldName     string   `json:"field_name"`
-	Required      bool     `json:"required"`
-	Description   string   `json:"description"`
-	AllowedValues []string `json:"allowed_values"`
+	FieldName     string           `json:"field_name"`
+	Required      bool             `json:"required"`
+	Description   string           `json:"description"`
+	AllowedValues []string         `json:"allowed_values"`
+	Type          string           `json:"type"`
+	Pattern       string           `json:"pattern"`
+	MinLength     *int             `json:"min_length"`
+	MaxLength     *int             `json:"max_length"`
+	MinValue      *float64         `json:"min_value"`
+	MaxValue      *float64         `json:"max_value"`
+	RequiredIf    []FieldCondition `json:"required_if"`
+	Deprecated    bool             `json:"deprecated"`
+	Severity      string           `json:"severity"`
+
+	// pattern is Pattern compiled when the config is checked.
+	pattern *regexp.Regexp
+}
+
+// FieldCondition matches when the named field is present and, if Values is set, holds one of those values.
+type FieldCondition struct {
+	FieldName string   `json:"field_name"`
+	Values    []string `json:"values"`
+}
+
+func (c *FieldCondition) Matches(manifest map[string]string) bool {
+	value, ok := manifest[c.FieldName]
+	if !ok {
+		return false
+	}
+	if len(c.Values) == 0 {
+		return true
+	}
+	return slices.Contains(c.Values, value)
+}
+
+func (c *FieldCondition) String() string {
+	if len(c.Values) == 0 {
+		return fmt.Sprintf("%s is present", c.FieldName)
+	}
+	return fmt.Sprintf("%s is one of [%s]", c.FieldName, strings.Join(c.Values, ", "))
+}
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	FieldTypeString   = "string"
+	FieldTypeInt      = "int"
+	FieldTypeDate     = "date"
+	FieldTypeDatetime = "datetime"
+	FieldTypeBool     = "bool"
+	FieldTypeArray    = "array"
+)
+
+var typeCheckers = map[string]func(string) error{
+	FieldTypeString: func(string) error { return nil },
+	FieldTypeInt: func(v string) error {
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err
+	},
+	FieldTypeDate: func(v string) error {
+		_, err := time.Parse(time.DateOnly, v)
+		return err
+	},
+	FieldTypeDatetime: func(v string) error {
+		_, err := time.Parse(time.RFC3339Nano, v)
+		return err
+	},
+	FieldTypeBool: func(v string) error {
+		_, err := strconv.ParseBool(v)
+		return err
+	},
+	FieldTypeArray: func(v string) error {
+		var a []any
+		return json.Unmarshal([]byte(v), &a)
+	},
 }
 
 func validFileName(value string) error {
@@ -45,33 +165,133 @@ var BuiltIns = map[string][]func(string) error{
 	"received_filename": {validFileName},
 }
 
+func (fc *FieldConfig) isRequired(manifest map[string]string) bool {
+	if fc.Required {
+		return true
+	}
+	if len(fc.RequiredIf) == 0 {
+		return false
+	}
+	for _, c := range fc.RequiredIf {
+		if !c.Matches(manifest) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the manifest against every rule of the field and returns all violations joined together.
+// Each violation wraps ErrFailure, or ErrWarning for rules that should not reject the upload.
 func (fc *FieldConfig) Validate(manifest map[string]string) error {
 	value, ok := manifest[fc.FieldName]
-	if !ok && fc.Required {
-		return errors.Join(ErrFailure, &ErrorMissing{Field: fc.FieldName})
-	}
-	if len(fc.AllowedValues) > 0 {
-		for _, allowed := range fc.AllowedValues {
-			if allowed == value {
-				return nil
-			}
+	if !ok && fc.isRequired(manifest) {
+		e := &ErrorMissing{Field: fc.FieldName}
+		if !fc.Required {
+			e.Condition = fc.conditionString()
 		}
-		return errors.Join(ErrFailure, &ErrorNotAnAllowedValue{field: fc.FieldName, value: value})
+		return fc.violation(e)
+	}
+
+	var errs error
+	if len(fc.AllowedValues) > 0 && !slices.Contains(fc.AllowedValues, value) {
+		errs = errors.Join(errs, fc.violation(&ErrorNotAnAllowedValue{field: fc.FieldName, value: value}))
 	}
 	if validators, ok := BuiltIns[fc.FieldName]; ok {
 		for _, validator := range validators {
 			if err := validator(value); err != nil {
-				return err
+				errs = errors.Join(errs, err)
 			}
 		}
 	}
-	return nil
+	if !ok {
+		return errs
+	}
+
+	if fc.Deprecated {
+		errs = errors.Join(errs, errors.Join(ErrWarning, &ErrorDeprecated{Field: fc.FieldName}))
+	}
+	if err := fc.validateRules(value); err != nil {
+		errs = errors.Join(errs, err)
+	}
+	return errs
+}
+
+func (fc *FieldConfig) validateRules(value string) error {
+	var errs error
+	if fc.Type != "" {
+		check, ok := typeCheckers[fc.Type]
+		if !ok {
+			return fmt.Errorf("unknown type %s configured for field %s", fc.Type, fc.FieldName)
+		}
+		if err := check(value); err != nil {
+			// the remaining rules assume a value of the configured type
+			return fc.violation(&ErrorInvalidType{Field: fc.FieldName, Value: value, Type: fc.Type})
+		}
+	}
+
+	if fc.Pattern != "" {
+		re := fc.pattern
+		if re == nil {
+			// fields that were never checked, such as ones built in code
+			var err error
+			if re, err = regexp.Compile(fc.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern configured for field %s: %w", fc.FieldName, err)
+			}
+		}
+		if !re.MatchString(value) {
+			errs = errors.Join(errs, fc.violation(&ErrorPatternMismatch{Field: fc.FieldName, Value: value, Pattern: fc.Pattern}))
+		}
+	}
+
+	if fc.MinLength != nil || fc.MaxLength != nil {
+		length := utf8.RuneCountInString(value)
+		if fc.Type == FieldTypeArray {
+			var a []any
+			json.Unmarshal([]byte(value), &a)
+			length = len(a)
+		}
+		if (fc.MinLength != nil && length < *fc.MinLength) || (fc.MaxLength != nil && length > *fc.MaxLength) {
+			errs = errors.Join(errs, fc.violation(&ErrorLength{Field: fc.FieldName, Length: length, Min: fc.MinLength, Max: fc.MaxLength}))
+		}
+	}
+
+	if fc.MinValue != nil || fc.MaxValue != nil {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			errs = errors.Join(errs, fc.violation(&ErrorInvalidType{Field: fc.FieldName, Value: value, Type: "number"}))
+		} else if (fc.MinValue != nil && n < *fc.MinValue) || (fc.MaxValue != nil && n > *fc.MaxValue) {
+			errs = errors.Join(errs, fc.violation(&ErrorOutOfRange{Field: fc.FieldName, Value: value, Min: fc.MinValue, Max: fc.MaxValue}))
+		}
+	}
+
+	return errs
+}
+
+// violation marks a rule violation with ErrWarning when the field is configured as a warning, and ErrFailure otherwise.
+func (fc *FieldConfig) violation(err error) error {
+	if fc.Severity == SeverityWarning {
+		return errors.Join(ErrWarning, err)
+	}
+	return errors.Join(ErrFailure, err)
+}
+
+func (fc *FieldConfig) conditionString() string {
+	conds := make([]string, len(fc.RequiredIf))
+	for i, c := range fc.RequiredIf {
+		conds[i] = c.String()
+	}
+	return strings.Join(conds, " and ")
 }
 
 type ConfigLoader interface {
 	LoadConfig(ctx context.Context, path string) ([]byte, error)
 }
 
+// ConfigLister is implemented by config loaders that can enumerate the configs they hold.
+type ConfigLister interface {
+	ListConfigs(ctx context.Context) ([]string, error)
+}
+
 type ConfigLocation interface {
 	Path() string
 }
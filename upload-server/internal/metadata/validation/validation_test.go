@@ -0,0 +1,339 @@
+package validation_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestFieldConfigValidate(t *testing.T) {
+	type testCase struct {
+		name     string
+		field    validation.FieldConfig
+		manifest map[string]string
+		err      error
+	}
+
+	testCases := []testCase{
+		{
+			"required present",
+			validation.FieldConfig{FieldName: "sender_id", Required: true},
+			map[string]string{"sender_id": "test"},
+			nil,
+		},
+		{
+			"required missing",
+			validation.FieldConfig{FieldName: "sender_id", Required: true},
+			map[string]string{},
+			validation.ErrFailure,
+		},
+		{
+			"optional missing skips rules",
+			validation.FieldConfig{FieldName: "count", Type: validation.FieldTypeInt, MinValue: ptr(1.0)},
+			map[string]string{},
+			nil,
+		},
+		{
+			"int type",
+			validation.FieldConfig{FieldName: "count", Type: validation.FieldTypeInt},
+			map[string]string{"count": "12"},
+			nil,
+		},
+		{
+			"bad int type",
+			validation.FieldConfig{FieldName: "count", Type: validation.FieldTypeInt},
+			map[string]string{"count": "twelve"},
+			validation.ErrFailure,
+		},
+		{
+			"date type",
+			validation.FieldConfig{FieldName: "report_date", Type: validation.FieldTypeDate},
+			map[string]string{"report_date": "2024-02-29"},
+			nil,
+		},
+		{
+			"bad date type",
+			validation.FieldConfig{FieldName: "report_date", Type: validation.FieldTypeDate},
+			map[string]string{"report_date": "02/29/2024"},
+			validation.ErrFailure,
+		},
+		{
+			"datetime type",
+			validation.FieldConfig{FieldName: "sent", Type: validation.FieldTypeDatetime},
+			map[string]string{"sent": "2024-02-29T10:11:12Z"},
+			nil,
+		},
+		{
+			"bool type",
+			validation.FieldConfig{FieldName: "is_test", Type: validation.FieldTypeBool},
+			map[string]string{"is_test": "maybe"},
+			validation.ErrFailure,
+		},
+		{
+			"array type with length",
+			validation.FieldConfig{FieldName: "tags", Type: validation.FieldTypeArray, MaxLength: ptr(2)},
+			map[string]string{"tags": `["a", "b", "c"]`},
+			validation.ErrFailure,
+		},
+		{
+			"pattern match",
+			validation.FieldConfig{FieldName: "jurisdiction", Pattern: "^[A-Z]{2}$"},
+			map[string]string{"jurisdiction": "GA"},
+			nil,
+		},
+		{
+			"pattern mismatch",
+			validation.FieldConfig{FieldName: "jurisdiction", Pattern: "^[A-Z]{2}$"},
+			map[string]string{"jurisdiction": "Georgia"},
+			validation.ErrFailure,
+		},
+		{
+			"string too short",
+			validation.FieldConfig{FieldName: "sender_id", MinLength: ptr(3)},
+			map[string]string{"sender_id": "ab"},
+			validation.ErrFailure,
+		},
+		{
+			"value out of range",
+			validation.FieldConfig{FieldName: "count", Type: validation.FieldTypeInt, MinValue: ptr(1.0), MaxValue: ptr(10.0)},
+			map[string]string{"count": "11"},
+			validation.ErrFailure,
+		},
+		{
+			"required if condition met",
+			validation.FieldConfig{FieldName: "batch_id", RequiredIf: []validation.FieldCondition{{FieldName: "is_batch", Values: []string{"true"}}}},
+			map[string]string{"is_batch": "true"},
+			validation.ErrFailure,
+		},
+		{
+			"required if condition not met",
+			validation.FieldConfig{FieldName: "batch_id", RequiredIf: []validation.FieldCondition{{FieldName: "is_batch", Values: []string{"true"}}}},
+			map[string]string{"is_batch": "false"},
+			nil,
+		},
+		{
+			"deprecated field present",
+			validation.FieldConfig{FieldName: "meta_ext_source", Deprecated: true},
+			map[string]string{"meta_ext_source": "test"},
+			validation.ErrWarning,
+		},
+		{
+			"deprecated field missing",
+			validation.FieldConfig{FieldName: "meta_ext_source", Deprecated: true},
+			map[string]string{},
+			nil,
+		},
+	}
+
+	for _, c := range testCases {
+		err := c.field.Validate(c.manifest)
+		if c.err == nil && err != nil {
+			t.Errorf("%s: expected no error but got %v", c.name, err)
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v but got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestDeprecatedIsNotAFailure(t *testing.T) {
+	field := validation.FieldConfig{FieldName: "meta_ext_source", Deprecated: true}
+	err := field.Validate(map[string]string{"meta_ext_source": "test"})
+	if errors.Is(err, validation.ErrFailure) {
+		t.Errorf("expected deprecated field to only warn but got %v", err)
+	}
+}
+
+func TestViolationsReportsEveryRule(t *testing.T) {
+	fields := []validation.FieldConfig{
+		{FieldName: "sender_id", Required: true},
+		{FieldName: "jurisdiction", Pattern: "^[A-Z]{2}$", MaxLength: ptr(2)},
+		{FieldName: "meta_ext_source", Deprecated: true},
+	}
+	manifest := map[string]string{
+		"jurisdiction":    "Georgia",
+		"meta_ext_source": "test",
+	}
+
+	var errs error
+	for _, f := range fields {
+		errs = errors.Join(errs, f.Validate(manifest))
+	}
+
+	expected := []string{
+		"field sender_id was missing",
+		"jurisdiction had value Georgia which does not match pattern ^[A-Z]{2}$",
+		"jurisdiction had length 7 which is not within [*, 2]",
+		"field meta_ext_source is deprecated",
+	}
+	if got := validation.Violations(errs); !slices.Equal(got, expected) {
+		t.Errorf("expected violations %q but got %q", expected, got)
+	}
+}
+
+func TestCheckedPattern(t *testing.T) {
+	mc := validation.ManifestConfig{
+		Metadata: validation.MetadataConfig{Fields: []validation.FieldConfig{
+			{FieldName: "jurisdiction", Pattern: "^[A-Z]{2}$"},
+		}},
+	}
+	if err := mc.Check(); err != nil {
+		t.Fatal(err)
+	}
+	f := mc.Metadata.Fields[0]
+	if err := f.Validate(map[string]string{"jurisdiction": "GA"}); err != nil {
+		t.Errorf("expected a matching value to pass but got %v", err)
+	}
+	if err := f.Validate(map[string]string{"jurisdiction": "Georgia"}); err == nil {
+		t.Error("expected a value not matching the checked pattern to fail")
+	}
+}
+
+func TestWarningSeverity(t *testing.T) {
+	fields := []validation.FieldConfig{
+		{FieldName: "sender_id", Required: true},
+		{FieldName: "program_code", Required: true, Severity: validation.SeverityWarning},
+		{FieldName: "jurisdiction", Pattern: "^[A-Z]{2}$", Severity: validation.SeverityWarning},
+	}
+	manifest := map[string]string{
+		"jurisdiction": "Georgia",
+	}
+
+	var errs error
+	for _, f := range fields {
+		errs = errors.Join(errs, f.Validate(manifest))
+	}
+
+	expectedFailures := []string{"field sender_id was missing"}
+	if got := validation.Failures(errs); !slices.Equal(got, expectedFailures) {
+		t.Errorf("expected failures %q but got %q", expectedFailures, got)
+	}
+	expectedWarnings := []string{
+		"field program_code was missing",
+		"jurisdiction had value Georgia which does not match pattern ^[A-Z]{2}$",
+	}
+	if got := validation.Warnings(errs); !slices.Equal(got, expectedWarnings) {
+		t.Errorf("expected warnings %q but got %q", expectedWarnings, got)
+	}
+
+	manifest["sender_id"] = "test"
+	errs = nil
+	for _, f := range fields {
+		errs = errors.Join(errs, f.Validate(manifest))
+	}
+	if errors.Is(errs, validation.ErrFailure) {
+		t.Errorf("expected only warnings but got %v", errs)
+	}
+}
+
+func TestManifestConfigCheck(t *testing.T) {
+	good := validation.ManifestConfig{
+		Metadata: validation.MetadataConfig{
+			Fields: []validation.FieldConfig{
+				{FieldName: "count", Type: validation.FieldTypeInt, Severity: validation.SeverityWarning},
+			},
+		},
+	}
+	if err := good.Check(); err != nil {
+		t.Errorf("expected valid config but got %v", err)
+	}
+
+	bad := validation.ManifestConfig{
+		Metadata: validation.MetadataConfig{
+			Fields: []validation.FieldConfig{
+				{FieldName: "count", Type: "integer"},
+				{FieldName: "jurisdiction", Pattern: "[A-Z"},
+				{FieldName: "sender_id", Severity: "info"},
+			},
+		},
+	}
+	if err := bad.Check(); err == nil {
+		t.Error("expected invalid config to fail the check")
+	}
+
+	duplicates := validation.ManifestConfig{
+		Duplicates: validation.DuplicateConfig{Policy: "skip"},
+	}
+	if err := duplicates.Check(); err == nil {
+		t.Error("expected unknown duplicate policy to fail the check")
+	}
+
+	size := validation.ManifestConfig{MaxSizeBytes: -1}
+	if err := size.Check(); err == nil {
+		t.Error("expected negative max size to fail the check")
+	}
+}
+
+func TestDuplicateConfigWindow(t *testing.T) {
+	dc := validation.DuplicateConfig{Policy: validation.DuplicatePolicyWarn}
+	if dc.Window() != validation.DefaultDuplicateWindowDays*24*time.Hour {
+		t.Errorf("expected default window but got %s", dc.Window())
+	}
+	dc.WindowDays = 2
+	if dc.Window() != 48*time.Hour {
+		t.Errorf("expected two day window but got %s", dc.Window())
+	}
+}
+
+func TestManifestConfigSelect(t *testing.T) {
+	jan := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	jul := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	mc := validation.ManifestConfig{
+		Versions: []validation.ConfigVersion{
+			{Version: "1", EffectiveUntil: &jul},
+			{Version: "2", EffectiveFrom: &jul},
+			{Version: "beta", EffectiveFrom: &jan, EffectiveUntil: &jan},
+		},
+	}
+
+	type testCase struct {
+		manifestVersion string
+		at              time.Time
+		expected        string
+	}
+	testCases := []testCase{
+		{"", jan, "1"},
+		{"", jul.Add(-time.Second), "1"},
+		{"", jul, "2"},
+		{"1", jan, "1"},
+		{"2", jul, "2"},
+	}
+	for _, c := range testCases {
+		selected, err := mc.Select(c.manifestVersion, c.at)
+		if err != nil {
+			t.Errorf("unexpected error selecting version %q at %s: %v", c.manifestVersion, c.at, err)
+			continue
+		}
+		if selected.Version != c.expected {
+			t.Errorf("expected version %s for %q at %s but got %s", c.expected, c.manifestVersion, c.at, selected.Version)
+		}
+	}
+
+	// pinned versions must be in effect, and unknown ones are not ignored
+	for _, pinned := range []string{"2", "beta", "2.0"} {
+		if _, err := mc.Select(pinned, jan); !errors.Is(err, validation.ErrFailure) {
+			t.Errorf("expected failure selecting version %q at %s but got %v", pinned, jan, err)
+		}
+	}
+	if _, err := mc.Select("1", jul); !errors.Is(err, validation.ErrFailure) {
+		t.Errorf("expected failure selecting retired version 1 at %s but got %v", jul, err)
+	}
+
+	future := validation.ManifestConfig{
+		Versions: []validation.ConfigVersion{{Version: "1", EffectiveFrom: &jul}},
+	}
+	if _, err := future.Select("", jan); !errors.Is(err, validation.ErrFailure) {
+		t.Errorf("expected failure when no version is effective but got %v", err)
+	}
+
+	if err := mc.Check(); err == nil {
+		t.Error("expected never effective version to fail the check")
+	}
+}
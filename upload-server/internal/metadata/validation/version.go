@@ -0,0 +1,106 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ConfigVersion is one version of a data stream's manifest config.  A version applies from EffectiveFrom
+// (inclusive) until EffectiveUntil (exclusive); either bound may be left open.
+type ConfigVersion struct {
+	Version        string          `json:"version"`
+	EffectiveFrom  *time.Time      `json:"effective_from"`
+	EffectiveUntil *time.Time      `json:"effective_until"`
+	Metadata       MetadataConfig  `json:"metadata_config"`
+	Copy           CopyConfig      `json:"copy_config"`
+	Transform      TransformConfig `json:"transform_config"`
+}
+
+func (v *ConfigVersion) EffectiveAt(t time.Time) bool {
+	if v.EffectiveFrom != nil && t.Before(*v.EffectiveFrom) {
+		return false
+	}
+	if v.EffectiveUntil != nil && !t.Before(*v.EffectiveUntil) {
+		return false
+	}
+	return true
+}
+
+// ConfigVersionKey is the manifest field a sender can pin a config version with.  It is separate from the
+// manifest's own version, which is the version of the manifest schema.
+const ConfigVersionKey = "config_version"
+
+type ErrorNoEffectiveVersion struct {
+	Version string
+	At      time.Time
+}
+
+func (e *ErrorNoEffectiveVersion) Error() string {
+	if e.Version != "" {
+		return fmt.Sprintf("no config version %s effective at %s", e.Version, e.At.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("no config version effective at %s", e.At.Format(time.RFC3339))
+}
+
+// Select returns the config that applies to a manifest.  A config without versions always applies as it is.
+// Otherwise the version pinned by the manifest's config_version is used, as long as it is effective at the given
+// time, and without one the effective version with the latest effective_from.
+func (mc *ManifestConfig) Select(configVersion string, at time.Time) (*ManifestConfig, error) {
+	if len(mc.Versions) == 0 {
+		return mc, nil
+	}
+
+	var selected *ConfigVersion
+	for i, v := range mc.Versions {
+		if !v.EffectiveAt(at) {
+			continue
+		}
+		if configVersion != "" {
+			if v.Version == configVersion {
+				selected = &mc.Versions[i]
+				break
+			}
+			continue
+		}
+		if selected == nil || (v.EffectiveFrom != nil && (selected.EffectiveFrom == nil || v.EffectiveFrom.After(*selected.EffectiveFrom))) {
+			selected = &mc.Versions[i]
+		}
+	}
+	if selected == nil {
+		return nil, errors.Join(ErrFailure, &ErrorNoEffectiveVersion{Version: configVersion, At: at})
+	}
+
+	return &ManifestConfig{
+		Version:        selected.Version,
+		EffectiveFrom:  selected.EffectiveFrom,
+		EffectiveUntil: selected.EffectiveUntil,
+		Metadata:       selected.Metadata,
+		Copy:           selected.Copy,
+		Transform:      selected.Transform,
+		Authorization:  mc.Authorization,
+		Duplicates:     mc.Duplicates,
+		MaxSizeBytes:   mc.MaxSizeBytes,
+	}, nil
+}
+
+func (mc *ManifestConfig) checkVersions() error {
+	var errs error
+	seen := map[string]bool{}
+	for _, v := range mc.Versions {
+		if v.Version == "" {
+			errs = errors.Join(errs, errors.New("config version configured without a version name"))
+		} else if seen[v.Version] {
+			errs = errors.Join(errs, fmt.Errorf("config version %s configured more than once", v.Version))
+		}
+		seen[v.Version] = true
+		if v.EffectiveFrom != nil && v.EffectiveUntil != nil && !v.EffectiveFrom.Before(*v.EffectiveUntil) {
+			errs = errors.Join(errs, fmt.Errorf("config version %s is never effective", v.Version))
+		}
+		vc := ManifestConfig{Metadata: v.Metadata, Copy: v.Copy, Transform: v.Transform}
+		if err := vc.Check(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("config version %s: %w", v.Version, err))
+		}
+	}
+	return errs
+}
@@ -30,6 +30,11 @@ func (c *codedResponseWriter) WriteHeader(statusCode int) {
 	c.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Unwrap lets http.ResponseController flush streamed responses through the writer.
+func (c *codedResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
 func TrackHTTP(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		OpenConnections.Inc()
@@ -0,0 +1,11 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var RateLimitRejections = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "dex_server_rate_limit_rejections_total",
+		Help: "How many requests and uploads were rejected by rate limits, partitioned by scope and limit.",
+	},
+	[]string{"scope", "limit"},
+)
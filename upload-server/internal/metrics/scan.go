@@ -0,0 +1,18 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const ScanVerdictClean = "clean"
+const ScanVerdictInfected = "infected"
+const ScanVerdictError = "error"
+
+var MalwareScans = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "dex_server_malware_scans_total",
+	Help: "Number of uploads scanned for malware, partitioned by verdict",
+}, []string{"verdict"})
+
+var MalwareScanDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "dex_server_malware_scan_duration_seconds",
+	Help:    "How long scanning an upload for malware took, partitioned by verdict",
+	Buckets: prometheus.ExponentialBuckets(0.01, 2.5, 15),
+}, []string{"verdict"})
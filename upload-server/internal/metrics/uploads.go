@@ -37,9 +37,23 @@ func ActiveUploadDecHook(event *handler.HookEvent, resp hooks.HookResponse) (hoo
 	return resp, nil
 }
 
+// ActiveUploadTerminatedHook stops counting an upload that is terminated before it finishes, as it never reaches
+// the post-finish hook that would.
+func ActiveUploadTerminatedHook(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	if event.Upload.SizeIsDeferred || event.Upload.Offset < event.Upload.Size {
+		ActiveUploads.Dec()
+	}
+	return resp, nil
+}
+
 func UploadSpeedsHook(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
 	logger := sloger.FromContext(event.Context)
 
+	if event.Upload.IsPartial || event.Upload.IsFinal {
+		// partial uploads carry no ingest time, and final uploads are concatenated from data already received
+		return resp, nil
+	}
+
 	size := event.Upload.Size
 
 	manifest := event.Upload.MetaData
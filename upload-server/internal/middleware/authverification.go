@@ -3,12 +3,17 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/apikey"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/mtls"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
 )
@@ -16,10 +21,15 @@ import (
 var ErrNoAuthHeader = errors.New("authorization header missing")
 var ErrAuthHeaderInvalidFormat = errors.New("authorization header format is invalid")
 var ErrTokenNotFound = errors.New("authorization token not found")
+var ErrNoClientCertificate = errors.New("verified client certificate required")
+var ErrRoleRequired = errors.New("role required")
 
 const UserSessionCookieName = "phdo_session"
 const LoginRedirectCookieName = "login_redirect"
 
+// RefreshWindow is how long before a session's token expires that it is refreshed.
+const RefreshWindow = 2 * time.Minute
+
 type Claims struct {
 	Scopes string `json:"scope"`
 }
@@ -42,7 +52,16 @@ func NewHTTPError(code int, msg string) *HTTPError {
 	return &HTTPError{Code: code, Msg: msg}
 }
 
-func NewAuthMiddleware(ctx context.Context, config appconfig.OauthConfig) (*AuthMiddleware, error) {
+type AuthOption func(a *AuthMiddleware)
+
+// WithAPIKeys lets machine senders authenticate with an API key in place of a JWT.
+func WithAPIKeys(keys *apikey.Manager) AuthOption {
+	return func(a *AuthMiddleware) {
+		a.apiKeys = keys
+	}
+}
+
+func NewAuthMiddleware(ctx context.Context, config appconfig.OauthConfig, opts ...AuthOption) (*AuthMiddleware, error) {
 	var validator oauth.Validator = oauth.PassthroughValidator{}
 	if config.AuthEnabled {
 		if config.IssuerUrl == "" {
@@ -57,20 +76,67 @@ func NewAuthMiddleware(ctx context.Context, config appconfig.OauthConfig) (*Auth
 		health.Register(validator)
 	}
 
-	return &AuthMiddleware{
+	a := &AuthMiddleware{
 		authEnabled: config.AuthEnabled,
 		validator:   validator,
-	}, nil
+		roles: oauth.RoleMapping{
+			Claim:      config.RolesClaim,
+			AdminScope: config.AdminScope,
+			Values: map[oauth.Role][]string{
+				oauth.RoleAdmin:         strings.Fields(config.AdminRoles),
+				oauth.RoleOperator:      strings.Fields(config.OperatorRoles),
+				oauth.RoleProgramViewer: strings.Fields(config.ProgramViewerRoles),
+			},
+		},
+	}
+	if config.AuthEnabled && config.ClientID != "" {
+		a.client = oauth.NewClient(oauth.ClientConfig{
+			IssuerURL:             config.IssuerUrl,
+			ClientID:              config.ClientID,
+			ClientSecret:          config.ClientSecret,
+			RedirectURL:           config.RedirectURL,
+			PostLogoutRedirectURL: config.PostLogoutRedirectURL,
+			Scopes:                loginScopes(config),
+		})
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// loginScopes adds the scopes the API requires to the scopes requested when signing in to the UI.
+func loginScopes(config appconfig.OauthConfig) []string {
+	scopes := strings.Fields(config.LoginScopes)
+	for _, s := range strings.Fields(config.RequiredScopes) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
 }
 
 type AuthMiddleware struct {
 	authEnabled bool
 	validator   oauth.Validator
+	apiKeys     *apikey.Manager
+	client      *oauth.Client
+	roles       oauth.RoleMapping
+}
+
+// withClaims passes the validated claims, along with the roles they grant, on to the handlers.
+func (a AuthMiddleware) withClaims(r *http.Request, claims oauth.Claims) *http.Request {
+	claims.Roles = a.roles.Roles(claims)
+	return r.WithContext(oauth.NewContext(r.Context(), claims))
 }
 
 func (a AuthMiddleware) VerifyOAuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, hasCert := mtls.FromRequest(r)
 		if !a.authEnabled {
+			if hasCert {
+				r = a.withClaims(r, mtls.Claims(cert))
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -82,13 +148,20 @@ func (a AuthMiddleware) VerifyOAuthTokenMiddleware(next http.Handler) http.Handl
 		// read auth token from either headers or cookies
 		token, err := getAuthToken(r.Header)
 		if err != nil {
+			if errors.Is(err, ErrNoAuthHeader) && hasCert {
+				// a verified client certificate authenticates the sender on its own
+				next.ServeHTTP(w, a.withClaims(r, mtls.Claims(cert)))
+				return
+			}
 			if errors.Is(err, ErrNoAuthHeader) {
 				// fallback to session cookies
 				us, err := GetUserSession(r)
 				if err != nil {
 					slog.Error("error getting user session", "error", err)
 					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
 				}
+				a.refresh(r, us)
 				token = us.Data().Token
 			} else {
 				slog.Error("error getting token from header", "error", err)
@@ -101,9 +174,12 @@ func (a AuthMiddleware) VerifyOAuthTokenMiddleware(next http.Handler) http.Handl
 			http.Error(w, ErrTokenNotFound.Error(), http.StatusUnauthorized)
 			return
 		}
-		if strings.Count(token, ".") == 2 {
+		var claims oauth.Claims
+		if a.apiKeys != nil && apikey.IsKey(token) {
+			claims, err = a.validateAPIKey(r.Context(), token)
+		} else if strings.Count(token, ".") == 2 {
 			// Token is JWT, validate using oidc verifier
-			_, err = a.validator.ValidateJWT(r.Context(), token)
+			claims, err = a.validator.ValidateJWT(r.Context(), token)
 			if err != nil {
 				if errors.Is(err, oauth.ErrTokenVerificationFailed) || errors.Is(err, oauth.ErrTokenClaimsFailed) {
 					err = errors.Join(err, NewHTTPError(http.StatusUnauthorized, err.Error()))
@@ -126,10 +202,39 @@ func (a AuthMiddleware) VerifyOAuthTokenMiddleware(next http.Handler) http.Handl
 			return
 		}
 
+		// pass the validated claims on so the tus hooks can authorize the sender
+		next.ServeHTTP(w, a.withClaims(r, claims))
+	})
+}
+
+// RequireClientCertificate rejects requests without a verified client certificate.
+func RequireClientCertificate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// allow preflight checks from browser clients
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := mtls.FromRequest(r); !ok {
+			slog.Warn("request missing client certificate", "path", r.URL.Path)
+			http.Error(w, ErrNoClientCertificate.Error(), http.StatusUnauthorized)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+func (a AuthMiddleware) validateAPIKey(ctx context.Context, token string) (oauth.Claims, error) {
+	key, err := a.apiKeys.Authenticate(ctx, token)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidKey) || errors.Is(err, apikey.ErrExpired) || errors.Is(err, apikey.ErrRevoked) {
+			return oauth.Claims{}, errors.Join(err, NewHTTPError(http.StatusUnauthorized, err.Error()))
+		}
+		return oauth.Claims{}, errors.Join(err, NewHTTPError(http.StatusInternalServerError, err.Error()))
+	}
+	return key.Claims(), nil
+}
+
 func (a AuthMiddleware) VerifyUserSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !a.authEnabled {
@@ -139,17 +244,58 @@ func (a AuthMiddleware) VerifyUserSession(next http.Handler) http.Handler {
 
 		us, err := GetUserSession(r)
 		if err != nil {
+			slog.Error("error getting user session", "error", err)
 			loginRedirect(*us, r, w)
+			return
 		}
 
+		a.refresh(r, us)
 		token := us.Data().Token
 
-		_, err = a.validator.ValidateJWT(r.Context(), token)
+		claims, err := a.validator.ValidateJWT(r.Context(), token)
 		if err != nil {
 			loginRedirect(*us, r, w)
 			return
 		}
 
+		next.ServeHTTP(w, a.withClaims(r, claims))
+	})
+}
+
+// refresh swaps in new tokens when the session's token is about to expire.  On failure the session keeps its
+// current token, so the user is sent to log in again once it expires.
+func (a AuthMiddleware) refresh(r *http.Request, us *UserSession) {
+	if a.client == nil || !us.NeedsRefresh(RefreshWindow) {
+		return
+	}
+	tokens, err := a.client.Refresh(r.Context(), us.Tokens())
+	if err != nil {
+		slog.Warn("failed to refresh session token", "subject", us.Subject(), "error", err)
+		return
+	}
+	claims, err := a.validator.ValidateJWT(r.Context(), tokens.Bearer())
+	if err != nil {
+		slog.Warn("refreshed session token failed validation", "subject", us.Subject(), "error", err)
+		return
+	}
+	if err := us.Refresh(r, tokens, claims); err != nil {
+		slog.Error("failed to save refreshed session token", "error", err)
+	}
+}
+
+// RequireRole only lets through requests whose principal was granted the role, or a more privileged one.
+func (a AuthMiddleware) RequireRole(role oauth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authEnabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, ok := oauth.FromContext(r.Context())
+		if !ok || !claims.HasRole(role) {
+			slog.Warn("request missing required role", "path", r.URL.Path, "role", role, "subject", claims.Subject)
+			http.Error(w, fmt.Sprintf("%s: %s", ErrRoleRequired, role), http.StatusForbidden)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -158,6 +304,16 @@ func (a AuthMiddleware) Validator() oauth.Validator {
 	return a.validator
 }
 
+func (a AuthMiddleware) APIKeys() *apikey.Manager {
+	return a.apiKeys
+}
+
+// Client returns the client for signing in to the UI with the authorization code flow, or nil when the UI
+// falls back to pasting a token.
+func (a AuthMiddleware) Client() *oauth.Client {
+	return a.client
+}
+
 func loginRedirect(userSess UserSession, r *http.Request, w http.ResponseWriter) {
 	v := r.URL.Path
 	if r.URL.RawQuery != "" {
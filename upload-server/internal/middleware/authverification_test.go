@@ -6,15 +6,20 @@ import (
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/apikey"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/session"
 	"github.com/gorilla/securecookie"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -250,7 +255,7 @@ func runOAuthTokenVerificationTestCase(t *testing.T, tc testCase) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			err = us.SetToken(req, rec, tc.userSession.Token, 0)
+			err = us.SetToken(req, rec, tc.userSession.Token, oauth.Claims{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -280,6 +285,77 @@ func runOAuthTokenVerificationTestCase(t *testing.T, tc testCase) {
 	})
 }
 
+// tests that API keys are accepted in place of a JWT and that the principal reaches the next handler
+func TestVerifyOAuthTokenMiddleware_APIKeys(t *testing.T) {
+	err := initKeys()
+	if err != nil {
+		t.Fatalf("failed to initialize keys: %v", err)
+	}
+	mockOIDC := mockOIDCServer()
+	defer mockOIDC.Close()
+
+	authConfig := appconfig.OauthConfig{
+		AuthEnabled: true,
+		IssuerUrl:   mockOIDC.URL,
+		SessionKey:  sessionKey,
+	}
+	if err := InitStore(authConfig); err != nil {
+		t.Fatal(err)
+	}
+	keys := &apikey.Manager{Store: &apikey.FileStore{Path: filepath.Join(t.TempDir(), "api-keys.json")}}
+	validKey, _, err := keys.Issue(context.Background(), "ga-doh", []string{"dextesting/*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedKey, revoked, err := keys.Issue(context.Background(), "fl-doh", []string{"*/*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Revoke(context.Background(), revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	mockTokenValid, _ := createMockJWT(mockOIDC.URL, 1, "")
+
+	middleware, err := NewAuthMiddleware(context.Background(), authConfig, WithAPIKeys(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		token         string
+		expectStatus  int
+		expectSubject string
+	}{
+		{"Valid API Key", validKey, http.StatusOK, "ga-doh"},
+		{"Revoked API Key", revokedKey, http.StatusUnauthorized, ""},
+		{"Unknown API Key", apikey.Prefix + "unknown_secret", http.StatusUnauthorized, ""},
+		{"Valid JWT Token", mockTokenValid, http.StatusOK, "1234567890"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var subject string
+			handler := middleware.VerifyOAuthTokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, _ := oauth.FromContext(r.Context())
+				subject = claims.Subject
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectStatus {
+				t.Errorf("expected status %d, got %d", tc.expectStatus, rec.Code)
+			}
+			if subject != tc.expectSubject {
+				t.Errorf("expected subject %q in the request context, got %q", tc.expectSubject, subject)
+			}
+		})
+	}
+}
+
 func TestUserSessionMiddleware_TestCases(t *testing.T) {
 	err := initKeys()
 	if err != nil {
@@ -406,7 +482,7 @@ func runUserSessionMiddlewareTestCase(t *testing.T, tc testCase) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			err = us.SetToken(req, resp, tc.userSession.Token, 0)
+			err = us.SetToken(req, resp, tc.userSession.Token, oauth.Claims{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -457,10 +533,8 @@ func runUserSessionMiddlewareTestCase(t *testing.T, tc testCase) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			if token, ok := val["token"].(string); ok {
-				if !strings.Contains(token, tc.expectedUserSession.Token) {
-					t.Errorf("expected cookie to have token %s; cookie: %s", tc.expectedUserSession.Token, token)
-				}
+			if token, ok := val["token"]; ok {
+				t.Errorf("expected the token to be kept on the server; cookie: %s", token)
 			}
 			if redirect, ok := val["redirect"].(string); ok {
 				if !strings.Contains(redirect, tc.expectedUserSession.Token) {
@@ -471,6 +545,197 @@ func runUserSessionMiddlewareTestCase(t *testing.T, tc testCase) {
 	})
 }
 
+// tests that roles granted by the token gate handlers, and that API keys never grant more than the sender role
+func TestRequireRole(t *testing.T) {
+	err := initKeys()
+	if err != nil {
+		t.Fatalf("failed to initialize keys: %v", err)
+	}
+	mockOIDC := mockOIDCServer()
+	defer mockOIDC.Close()
+
+	authConfig := appconfig.OauthConfig{
+		AuthEnabled: true,
+		IssuerUrl:   mockOIDC.URL,
+		SessionKey:  sessionKey,
+		AdminScope:  "dex:admin",
+		RolesClaim:  "roles",
+	}
+	keys := &apikey.Manager{Store: &apikey.FileStore{Path: filepath.Join(t.TempDir(), "api-keys.json")}}
+	key, _, err := keys.Issue(context.Background(), "ga-doh", []string{"*/*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, _ := createMockJWT(mockOIDC.URL, 1, "dex:admin")
+	senderToken, _ := createMockJWT(mockOIDC.URL, 1, "")
+
+	middleware, err := NewAuthMiddleware(context.Background(), authConfig, WithAPIKeys(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware.VerifyOAuthTokenMiddleware(middleware.RequireRole(oauth.RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	testCases := []struct {
+		name         string
+		token        string
+		expectStatus int
+	}{
+		{"Admin", adminToken, http.StatusOK},
+		{"Sender", senderToken, http.StatusForbidden},
+		{"API Key", key, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/route/1234", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectStatus {
+				t.Errorf("expected status %d, got %d", tc.expectStatus, rec.Code)
+			}
+		})
+	}
+}
+
+// tests that the cookie only identifies a server side session, so logging out invalidates every copy of it
+func TestUserSession_ServerSide(t *testing.T) {
+	if err := InitStore(appconfig.OauthConfig{AuthEnabled: true, SessionKey: sessionKey}); err != nil {
+		t.Fatal(err)
+	}
+	cookieFrom := func(rec *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == UserSessionCookieName {
+				return c
+			}
+		}
+		t.Fatal("expected session cookie")
+		return nil
+	}
+	login := func(subject string) *http.Cookie {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		us, err := GetUserSession(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := us.SetToken(req, rec, "token-"+subject, oauth.Claims{Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+		return cookieFrom(rec)
+	}
+	tokenFor := func(c *http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		us, err := GetUserSession(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return us.Data().Token
+	}
+
+	laptop := login("alice")
+	phone := login("alice")
+	other := login("bob")
+	if token := tokenFor(laptop); token != "token-alice" {
+		t.Fatalf("expected the session token, got %q", token)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req.AddCookie(laptop)
+	us, err := GetUserSession(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := us.Delete(req, httptest.NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if token := tokenFor(laptop); token != "" {
+		t.Errorf("expected a copy of a logged out cookie to have no token, got %q", token)
+	}
+	if token := tokenFor(phone); token != "token-alice" {
+		t.Errorf("expected another session to stay signed in, got %q", token)
+	}
+
+	if n, err := Sessions().RevokeSubject(context.Background(), "alice"); err != nil || n != 1 {
+		t.Fatalf("expected to revoke 1 session, got %d %v", n, err)
+	}
+	if token := tokenFor(phone); token != "" {
+		t.Errorf("expected a revoked session to have no token, got %q", token)
+	}
+	if token := tokenFor(other); token != "token-bob" {
+		t.Errorf("expected another user's session to stay signed in, got %q", token)
+	}
+}
+
+// failingSessionStore fails to look up any session, as when the store it is backed by is unavailable.
+type failingSessionStore struct {
+	*session.MemoryStore
+}
+
+func (s failingSessionStore) Get(ctx context.Context, id string) (*session.Session, error) {
+	return nil, errors.New("session store unavailable")
+}
+
+// tests that a session store error stops the request with a single response instead of carrying on without a session
+func TestUserSession_StoreError(t *testing.T) {
+	authConfig := appconfig.OauthConfig{AuthEnabled: true, SessionKey: sessionKey}
+	if err := InitStore(authConfig, WithSessionStore(failingSessionStore{session.NewMemoryStore()})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		InitStore(authConfig)
+	})
+	mockOIDC := mockOIDCServer()
+	defer mockOIDC.Close()
+	authConfig.IssuerUrl = mockOIDC.URL
+	middleware, err := NewAuthMiddleware(context.Background(), authConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	us, err := GetUserSession(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := us.SetToken(req, rec, "token", oauth.Claims{Subject: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	cookie := rec.Result().Cookies()[0]
+
+	hasBeenCalled := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hasBeenCalled = true
+	})
+	for name, tc := range map[string]struct {
+		handler      http.Handler
+		expectStatus int
+	}{
+		"token":   {middleware.VerifyOAuthTokenMiddleware(next), http.StatusUnauthorized},
+		"session": {middleware.VerifyUserSession(next), http.StatusSeeOther},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			tc.handler.ServeHTTP(rec, req)
+			if rec.Code != tc.expectStatus {
+				t.Errorf("expected status %d, got %d", tc.expectStatus, rec.Code)
+			}
+			if hasBeenCalled {
+				t.Error("expected the next handler to not be called")
+			}
+			if lines := strings.Count(strings.TrimSpace(rec.Body.String()), "\n"); lines != 0 {
+				t.Errorf("expected a single response but got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
 // initialize keys for testing
 func initKeys() error {
 	var err error
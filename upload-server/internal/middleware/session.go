@@ -3,31 +3,61 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/session"
 	"github.com/gorilla/sessions"
 )
 
 var store sessions.Store
+var manager *session.Manager
 
-func InitStore(config appconfig.OauthConfig) error {
+type StoreOption func(*session.Manager)
+
+// WithSessionStore keeps sessions in s instead of in memory.
+func WithSessionStore(s session.Store) StoreOption {
+	return func(m *session.Manager) {
+		m.Store = s
+	}
+}
+
+// InitStore sets up the signed session cookie, which only holds an opaque session id, and the server side store
+// that holds each session's token.
+func InitStore(config appconfig.OauthConfig, opts ...StoreOption) error {
 	if config.AuthEnabled && config.SessionKey == "" {
 		return errors.New("no session key provided")
 	}
-	opts := &sessions.Options{
+	cookieOpts := &sessions.Options{
 		Path:     "/",
 		Secure:   config.SessionSecure,
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	}
 	if config.SessionDomain != "" {
-		opts.Domain = config.SessionDomain
+		cookieOpts.Domain = config.SessionDomain
 	}
 	store = sessions.NewCookieStore([]byte(config.SessionKey))
-	store.(*sessions.CookieStore).Options = opts
+	store.(*sessions.CookieStore).Options = cookieOpts
+
+	manager = &session.Manager{
+		Store:           session.NewMemoryStore(),
+		IdleTimeout:     config.SessionIdleTimeout,
+		AbsoluteTimeout: config.SessionAbsoluteTimeout,
+	}
+	for _, opt := range opts {
+		opt(manager)
+	}
 	return nil
 }
 
+// Sessions returns the server side session manager.
+func Sessions() *session.Manager {
+	return manager
+}
+
 type UserSessionData struct {
 	Token    string
 	Redirect string
@@ -35,21 +65,34 @@ type UserSessionData struct {
 
 type UserSession struct {
 	session *sessions.Session
+	server  *session.Session
 }
 
 func GetUserSession(r *http.Request) (*UserSession, error) {
 	s, err := store.Get(r, UserSessionCookieName)
+	us := &UserSession{session: s}
 	if err != nil {
-		return &UserSession{s}, err
+		return us, err
 	}
 
-	return &UserSession{s}, nil
+	if id, ok := s.Values["id"].(string); ok && id != "" {
+		us.server, err = manager.Get(r.Context(), id)
+		if errors.Is(err, session.ErrNotFound) || errors.Is(err, session.ErrExpired) {
+			// logged out, revoked, or timed out
+			return us, nil
+		}
+		if err != nil {
+			return us, err
+		}
+	}
+
+	return us, nil
 }
 
 func (s *UserSession) Data() UserSessionData {
-	token, ok := s.session.Values["token"].(string)
-	if !ok {
-		token = ""
+	token := ""
+	if s.server != nil {
+		token = s.server.Token
 	}
 	redirect, ok := s.session.Values["redirect"].(string)
 	if !ok {
@@ -59,16 +102,109 @@ func (s *UserSession) Data() UserSessionData {
 	return UserSessionData{token, redirect}
 }
 
-func (s *UserSession) SetToken(r *http.Request, w http.ResponseWriter, token string, expiry int) error {
-	s.session.Values["token"] = token
-	s.session.Options.MaxAge = expiry
+// Subject returns the subject of the signed in user, or an empty string when there is no active session.
+func (s *UserSession) Subject() string {
+	if s.server == nil {
+		return ""
+	}
+	return s.server.Subject
+}
+
+// SetToken starts a new server side session for the token, replacing any the browser already had.
+func (s *UserSession) SetToken(r *http.Request, w http.ResponseWriter, token string, claims oauth.Claims) error {
+	return s.SetTokens(r, w, oauth.Tokens{AccessToken: token}, claims)
+}
+
+// SetTokens starts a new server side session for the tokens of a completed login, replacing any the browser
+// already had.
+func (s *UserSession) SetTokens(r *http.Request, w http.ResponseWriter, tokens oauth.Tokens, claims oauth.Claims) error {
+	if err := s.end(r); err != nil {
+		return err
+	}
+	id, sess, err := manager.Create(r.Context(), tokens, claims)
+	if err != nil {
+		return err
+	}
+	s.server = sess
+	s.session.Values["id"] = id
+	delete(s.session.Values, "login")
+	s.session.Options.MaxAge = int(time.Until(sess.ExpiresAt).Seconds())
+	return s.session.Save(r, w)
+}
+
+// Tokens returns the tokens of the signed in user.
+func (s *UserSession) Tokens() oauth.Tokens {
+	if s.server == nil {
+		return oauth.Tokens{}
+	}
+	return s.server.Tokens()
+}
+
+// NeedsRefresh reports whether the session's token expires within the window and can be refreshed.
+func (s *UserSession) NeedsRefresh(window time.Duration) bool {
+	if s.server == nil || s.server.RefreshToken == "" || s.server.TokenExpiresAt.IsZero() {
+		return false
+	}
+	return time.Until(s.server.TokenExpiresAt) < window
+}
+
+// Refresh swaps in refreshed tokens without changing the session id.
+func (s *UserSession) Refresh(r *http.Request, tokens oauth.Tokens, claims oauth.Claims) error {
+	if s.server == nil {
+		return session.ErrNotFound
+	}
+	return manager.Refresh(r.Context(), s.server, tokens, claims)
+}
+
+// SetLoginRequest remembers the state, nonce, and PKCE verifier of a login until the provider redirects back.
+func (s *UserSession) SetLoginRequest(r *http.Request, w http.ResponseWriter, req oauth.LoginRequest) error {
+	s.session.Values["login"] = strings.Join([]string{req.State, req.Nonce, req.Verifier}, " ")
 	return s.session.Save(r, w)
 }
+
+// LoginRequest returns the pending login, if there is one.
+func (s *UserSession) LoginRequest() (oauth.LoginRequest, bool) {
+	v, ok := s.session.Values["login"].(string)
+	if !ok {
+		return oauth.LoginRequest{}, false
+	}
+	parts := strings.Split(v, " ")
+	if len(parts) != 3 {
+		return oauth.LoginRequest{}, false
+	}
+	return oauth.LoginRequest{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, true
+}
+
 func (s *UserSession) SetRedirect(r *http.Request, w http.ResponseWriter, redirect string) error {
 	s.session.Values["redirect"] = redirect
 	return s.session.Save(r, w)
 }
+
+// Delete ends the session on the server as well as clearing the cookie, so a copy of the cookie can not be reused.
 func (s *UserSession) Delete(r *http.Request, w http.ResponseWriter) error {
+	if err := s.end(r); err != nil {
+		return err
+	}
 	s.session.Options.MaxAge = -1
 	return s.session.Save(r, w)
 }
+
+// DeleteEverywhere ends every session of the signed in user along with this one.
+func (s *UserSession) DeleteEverywhere(r *http.Request, w http.ResponseWriter) error {
+	if subject := s.Subject(); subject != "" {
+		if _, err := manager.RevokeSubject(r.Context(), subject); err != nil {
+			return err
+		}
+	}
+	return s.Delete(r, w)
+}
+
+func (s *UserSession) end(r *http.Request) error {
+	id, ok := s.session.Values["id"].(string)
+	if !ok || id == "" {
+		return nil
+	}
+	delete(s.session.Values, "id")
+	s.server = nil
+	return manager.Delete(r.Context(), id)
+}
@@ -12,6 +12,7 @@ const (
 	PROCESSING_STATUS_APP = "Processing Status"
 	SERVICE_BUS           = "Azure Service Bus"
 	REDIS_LOCKER          = "Redis Locker"
+	POSTGRES_LOCKER       = "Postgres Locker"
 
 	META_DESTINATION_ID = "meta_destination_id"
 	META_EXT_EVENT      = "meta_ext_event"
@@ -4,6 +4,8 @@ type ServiceHealthResp struct {
 	Service     string `json:"service"`
 	Status      string `json:"status"`
 	HealthIssue string `json:"health_issue"`
+	// Critical is set by the health check for services the app is not ready without.
+	Critical bool `json:"critical"`
 } // .ServiceHealthResp
 
 func (shr ServiceHealthResp) BuildErrorResponse(err error) ServiceHealthResp {
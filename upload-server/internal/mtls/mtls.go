@@ -0,0 +1,227 @@
+package mtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+)
+
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+var ErrNoClientCAs = errors.New("no certificates found in client CA bundle")
+
+// Reloader serves the certificate and client CA bundle currently on disk, so that rotated certificates are
+// picked up without a restart.
+type Reloader struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func NewReloader(certFile string, keyFile string, clientCAFile string) (*Reloader, error) {
+	r := &Reloader{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: clientCAFile,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again if any of them changed since they were last loaded, and reports whether they
+// did.  The previous certificates are kept when the new ones fail to load.
+func (r *Reloader) Reload() (bool, error) {
+	modTimes := map[string]time.Time{}
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, err
+		}
+		modTimes[f] = info.ModTime()
+	}
+	r.mu.RLock()
+	changed := r.cert == nil
+	for f, t := range modTimes {
+		if !t.Equal(r.modTimes[f]) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return false, err
+	}
+	var pool *x509.CertPool
+	if r.ClientCAFile != "" {
+		b, err := os.ReadFile(r.ClientCAFile)
+		if err != nil {
+			return false, err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return false, fmt.Errorf("%w: %s", ErrNoClientCAs, r.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = modTimes
+	return true, nil
+}
+
+// Watch reloads the files every interval until the context is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				slog.Error("failed to reload tls certificates, keeping the current ones", "error", err)
+				continue
+			}
+			if reloaded {
+				slog.Info("reloaded tls certificates", "cert", r.CertFile, "client_ca", r.ClientCAFile)
+			}
+		}
+	}
+}
+
+// TLSConfig returns a server config that looks up the current certificates on every handshake.  Client
+// certificates are verified when given; whether one is required is left to RequireClientCertificate so that
+// routes such as /health stay reachable.
+func (r *Reloader) TLSConfig(clientAuth string) *tls.Config {
+	authType := tls.NoClientCert
+	if clientAuth == ClientAuthOptional || clientAuth == ClientAuthRequire {
+		authType = tls.VerifyClientCertIfGiven
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{"h2", "http/1.1"},
+				Certificates: []tls.Certificate{*r.cert},
+				ClientCAs:    r.clientCAs,
+				ClientAuth:   authType,
+			}, nil
+		},
+	}
+}
+
+func (r *Reloader) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "TLS certificate " + r.CertFile
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	leaf, err := x509.ParseCertificate(r.cert.Certificate[0])
+	if err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+		return rsp
+	}
+	if time.Now().After(leaf.NotAfter) {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = "certificate expired at " + leaf.NotAfter.Format(time.RFC3339)
+	}
+	return rsp
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.CertFile, r.KeyFile}
+	if r.ClientCAFile != "" {
+		files = append(files, r.ClientCAFile)
+	}
+	return files
+}
+
+// FromRequest returns the verified client certificate of the request, if it has one.
+func FromRequest(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+// Claims describes a client certificate as token claims so that certificates are authorized like tokens.
+// The subject is the common name, or the first SAN when there is none, the issuer is the issuing CA, and the
+// client ID is the certificate's SHA-256 fingerprint.  Subject and SAN fields are available as claims for
+// authorization rules.
+func Claims(cert *x509.Certificate) oauth.Claims {
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	subject := cert.Subject.CommonName
+	var uris []string
+	for _, u := range cert.URIs {
+		uris = append(uris, u.String())
+	}
+	for _, sans := range [][]string{cert.EmailAddresses, cert.DNSNames, uris} {
+		if subject == "" && len(sans) > 0 {
+			subject = sans[0]
+		}
+	}
+
+	return oauth.Claims{
+		Expiry:   cert.NotAfter.Unix(),
+		Subject:  subject,
+		Issuer:   cert.Issuer.String(),
+		ClientID: fingerprint,
+		Method:   oauth.MethodClientCertificate,
+		Raw: map[string]any{
+			"sub":                 subject,
+			"iss":                 cert.Issuer.String(),
+			"client_id":           fingerprint,
+			"cn":                  cert.Subject.CommonName,
+			"organization":        toAny(cert.Subject.Organization),
+			"organizational_unit": toAny(cert.Subject.OrganizationalUnit),
+			"dns_names":           toAny(cert.DNSNames),
+			"emails":              toAny(cert.EmailAddresses),
+			"uris":                toAny(uris),
+		},
+	}
+}
+
+func toAny(values []string) []any {
+	a := make([]any, len(values))
+	for i, v := range values {
+		a[i] = v
+	}
+	return a
+}
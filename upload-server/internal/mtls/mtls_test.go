@@ -0,0 +1,182 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var localhostIPs = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, certFile string, keyFile string) {
+	t.Helper()
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile == "" {
+		return
+	}
+	b, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestClientCertificateIdentityAndReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	serverTemplate := func(cn string) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: cn},
+			DNSNames:    []string{"localhost"},
+			IPAddresses: localhostIPs,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+	}
+	ca.write(t, caFile, "")
+	newTestCert(t, serverTemplate("server-1"), ca).write(t, certFile, keyFile)
+	client := newTestCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "ga-doh-sender", Organization: []string{"GA DOH"}},
+		EmailAddresses: []string{"sender@dph.ga.gov"},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	reloader, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, ok := FromRequest(r)
+		if !ok {
+			io.WriteString(w, "anonymous")
+			return
+		}
+		claims := Claims(cert)
+		io.WriteString(w, claims.Subject+"|"+claims.Values("organization")[0])
+	}))
+	ts.TLS = reloader.TLSConfig(ClientAuthOptional)
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) (string, string) {
+		t.Helper()
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b), resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	if body, _ := get(); body != "anonymous" {
+		t.Errorf("expected request without a client certificate to be anonymous, got %s", body)
+	}
+	body, server := get(client.tlsCertificate())
+	if body != "ga-doh-sender|GA DOH" {
+		t.Errorf("expected client certificate identity, got %s", body)
+	}
+	if server != "server-1" {
+		t.Errorf("expected server certificate server-1, got %s", server)
+	}
+
+	// rotate the server certificate; the mod time is moved forward in case the filesystem is coarse
+	newTestCert(t, serverTemplate("server-2"), ca).write(t, certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	reloaded, err := reloader.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded {
+		t.Error("expected rotated certificate to be reloaded")
+	}
+	if _, server := get(client.tlsCertificate()); server != "server-2" {
+		t.Errorf("expected rotated server certificate server-2, got %s", server)
+	}
+}
+
+func TestClaimsFallBackToSAN(t *testing.T) {
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	client := newTestCert(t, &x509.Certificate{
+		EmailAddresses: []string{"sender@dph.ga.gov"},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	claims := Claims(client.cert)
+	if claims.Subject != "sender@dph.ga.gov" {
+		t.Errorf("expected subject from email SAN, got %s", claims.Subject)
+	}
+	if claims.Issuer != "CN=Test CA" {
+		t.Errorf("expected issuer of the CA, got %s", claims.Issuer)
+	}
+	if len(claims.ClientID) != 64 {
+		t.Errorf("expected sha-256 fingerprint client id, got %s", claims.ClientID)
+	}
+}
@@ -0,0 +1,211 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrLoginFailed = errors.New("failed to complete login")
+var ErrNonceMismatch = errors.New("id token nonce does not match the login request")
+
+// LoginRequest is what a browser has to remember between starting a login and the provider redirecting back.
+type LoginRequest struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+func NewLoginRequest() (LoginRequest, error) {
+	state, err := randomString()
+	if err != nil {
+		return LoginRequest{}, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return LoginRequest{}, err
+	}
+	return LoginRequest{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Tokens are the result of a login or refresh.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Expiry       time.Time
+}
+
+// Bearer returns the token to send to the upload API.  Access tokens are used when they are JWTs, since opaque
+// access tokens can not be validated by the API, otherwise the ID token is used.
+func (t Tokens) Bearer() string {
+	if strings.Count(t.AccessToken, ".") == 2 {
+		return t.AccessToken
+	}
+	if t.IDToken != "" {
+		return t.IDToken
+	}
+	return t.AccessToken
+}
+
+type ClientConfig struct {
+	IssuerURL             string
+	ClientID              string
+	ClientSecret          string
+	RedirectURL           string
+	PostLogoutRedirectURL string
+	Scopes                []string
+}
+
+// Client signs users in to the UI with the authorization code flow and PKCE.  The provider is discovered on
+// first use so the server can start while the provider is unreachable.
+type Client struct {
+	ClientConfig
+	mu            sync.Mutex
+	oauth2        *oauth2.Config
+	verifier      *oidc.IDTokenVerifier
+	endSessionURL string
+}
+
+func NewClient(config ClientConfig) *Client {
+	return &Client{ClientConfig: config}
+}
+
+func (c *Client) init(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.oauth2 != nil {
+		return nil
+	}
+	p, err := oidc.NewProvider(ctx, c.IssuerURL)
+	if err != nil {
+		return err
+	}
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := p.Claims(&metadata); err != nil {
+		return err
+	}
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID}
+	}
+	c.verifier = p.Verifier(&oidc.Config{ClientID: c.ClientID})
+	c.endSessionURL = metadata.EndSessionEndpoint
+	c.oauth2 = &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint:     p.Endpoint(),
+		RedirectURL:  c.RedirectURL,
+		Scopes:       scopes,
+	}
+	return nil
+}
+
+// AuthCodeURL returns where to send the browser to sign in.
+func (c *Client) AuthCodeURL(ctx context.Context, req LoginRequest) (string, error) {
+	if err := c.init(ctx); err != nil {
+		return "", err
+	}
+	return c.oauth2.AuthCodeURL(req.State, oauth2.S256ChallengeOption(req.Verifier), oidc.Nonce(req.Nonce)), nil
+}
+
+// Exchange trades the code the provider redirected back with for tokens, and checks that the ID token was
+// issued for this login request.
+func (c *Client) Exchange(ctx context.Context, code string, req LoginRequest) (Tokens, error) {
+	if err := c.init(ctx); err != nil {
+		return Tokens{}, err
+	}
+	token, err := c.oauth2.Exchange(ctx, code, oauth2.VerifierOption(req.Verifier))
+	if err != nil {
+		return Tokens{}, errors.Join(ErrLoginFailed, err)
+	}
+	tokens := tokensFrom(token)
+	if tokens.IDToken == "" {
+		return Tokens{}, errors.Join(ErrLoginFailed, errors.New("no id token in response"))
+	}
+	idToken, err := c.verifier.Verify(ctx, tokens.IDToken)
+	if err != nil {
+		return Tokens{}, errors.Join(ErrLoginFailed, err)
+	}
+	if idToken.Nonce != req.Nonce {
+		return Tokens{}, errors.Join(ErrLoginFailed, ErrNonceMismatch)
+	}
+	return tokens, nil
+}
+
+// Refresh uses a refresh token to get new tokens.  Providers do not always return a new refresh or ID token,
+// in which case the ones given are kept.
+func (c *Client) Refresh(ctx context.Context, current Tokens) (Tokens, error) {
+	if err := c.init(ctx); err != nil {
+		return Tokens{}, err
+	}
+	token, err := c.oauth2.TokenSource(ctx, &oauth2.Token{RefreshToken: current.RefreshToken}).Token()
+	if err != nil {
+		return Tokens{}, err
+	}
+	tokens := tokensFrom(token)
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = current.RefreshToken
+	}
+	if tokens.IDToken == "" {
+		tokens.IDToken = current.IDToken
+	}
+	return tokens, nil
+}
+
+func tokensFrom(token *oauth2.Token) Tokens {
+	idToken, _ := token.Extra("id_token").(string)
+	return Tokens{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		IDToken:      idToken,
+		Expiry:       token.Expiry,
+	}
+}
+
+// LogoutURL returns the provider's end session url for the ID token, or an empty string when the provider does
+// not support logout.
+func (c *Client) LogoutURL(ctx context.Context, idToken string) (string, error) {
+	if err := c.init(ctx); err != nil {
+		return "", err
+	}
+	if c.endSessionURL == "" {
+		return "", nil
+	}
+	u, err := url.Parse(c.endSessionURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("client_id", c.ClientID)
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	if c.PostLogoutRedirectURL != "" {
+		q.Set("post_logout_redirect_uri", c.PostLogoutRedirectURL)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,194 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is an OIDC provider that issues codes for a single login and checks the PKCE verifier on exchange.
+type mockProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	refreshed int
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key}
+	mux := http.NewServeMux()
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+			"end_session_endpoint":   p.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"kid": "test-key-id",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{
+			"token_type": "Bearer",
+			"expires_in": 300,
+		}
+		switch r.FormValue("grant_type") {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			if r.FormValue("code") != "code" || base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			resp["access_token"] = "opaque-access"
+			resp["refresh_token"] = "refresh-1"
+			resp["id_token"] = p.idToken(t, p.nonce)
+		case "refresh_token":
+			if r.FormValue("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			p.refreshed++
+			resp["access_token"] = "opaque-access-2"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return p
+}
+
+func (p *mockProvider) idToken(t *testing.T, nonce string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.URL,
+		"sub":   "alice",
+		"aud":   "dex-upload",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	})
+	token.Header["kid"] = "test-key-id"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// authorize plays the part of the browser, following the auth code url and recording what the provider was sent.
+func (p *mockProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("expected an S256 code challenge, got %q", q.Get("code_challenge_method"))
+	}
+	p.challenge = q.Get("code_challenge")
+	p.nonce = q.Get("nonce")
+}
+
+func TestClient_CodeFlow(t *testing.T) {
+	ctx := context.Background()
+	p := newMockProvider(t)
+	c := NewClient(ClientConfig{
+		IssuerURL:             p.URL,
+		ClientID:              "dex-upload",
+		RedirectURL:           "https://upload.example.com/oauth_callback",
+		PostLogoutRedirectURL: "https://upload.example.com/login",
+	})
+
+	req, err := NewLoginRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := c.AuthCodeURL(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := mustParse(t, authURL).Query().Get("state"); state != req.State {
+		t.Errorf("expected state %q, got %q", req.State, state)
+	}
+	p.authorize(t, authURL)
+
+	stolen := req
+	stolen.Verifier = "not-the-verifier-that-was-challenged-for-this-login"
+	if _, err := c.Exchange(ctx, "code", stolen); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("expected a wrong verifier to fail, got %v", err)
+	}
+
+	replayed := req
+	replayed.Nonce = "another-login"
+	if _, err := c.Exchange(ctx, "code", replayed); !errors.Is(err, ErrNonceMismatch) {
+		t.Errorf("expected a nonce mismatch, got %v", err)
+	}
+
+	tokens, err := c.Exchange(ctx, "code", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "refresh-1" || tokens.Expiry.IsZero() {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+	if tokens.Bearer() != tokens.IDToken {
+		t.Error("expected the id token to be used in place of an opaque access token")
+	}
+
+	refreshed, err := c.Refresh(ctx, tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.refreshed != 1 || refreshed.AccessToken != "opaque-access-2" {
+		t.Errorf("expected a refreshed access token, got %+v", refreshed)
+	}
+	if refreshed.RefreshToken != tokens.RefreshToken || refreshed.IDToken != tokens.IDToken {
+		t.Error("expected tokens the provider did not reissue to be kept")
+	}
+
+	logoutURL, err := c.LogoutURL(ctx, tokens.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := mustParse(t, logoutURL)
+	if u.Path != "/logout" || u.Query().Get("id_token_hint") != tokens.IDToken || u.Query().Get("post_logout_redirect_uri") != "https://upload.example.com/login" {
+		t.Errorf("unexpected logout url %s", logoutURL)
+	}
+}
+
+func mustParse(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
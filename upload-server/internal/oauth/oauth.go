@@ -3,8 +3,10 @@ package oauth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"log/slog"
 	"net/http"
@@ -17,9 +19,96 @@ var ErrTokenVerificationFailed = errors.New("failed to verify token")
 var ErrTokenClaimsFailed = errors.New("failed to parse token claims")
 var ErrTokenScopesMismatch = errors.New("one or more required scopes not found")
 
+// How a principal was authenticated.
+const (
+	MethodJWT               = "jwt"
+	MethodAPIKey            = "api_key"
+	MethodClientCertificate = "client_certificate"
+)
+
+// DataStreamsClaim optionally limits a principal to the listed "<data_stream_id>/<data_stream_route>" globs.
+const DataStreamsClaim = "data_streams"
+
 type Claims struct {
-	Expiry int64  `json:"exp"`
-	Scopes string `json:"scope"`
+	Expiry   int64  `json:"exp"`
+	Scopes   string `json:"scope"`
+	Subject  string `json:"sub"`
+	Issuer   string `json:"iss"`
+	ClientID string `json:"client_id"`
+	// Method is how the claims were authenticated, one of the Method constants.
+	Method string `json:"-"`
+	// Raw holds every claim in the token so that groups and custom claims can be matched by name.
+	Raw map[string]any `json:"-"`
+	// Roles are granted by the AuthMiddleware's RoleMapping once the claims are validated.
+	Roles []Role `json:"-"`
+}
+
+// Values returns the values of the named claim as strings.
+// Array claims return one value per element; the scope claim is split on spaces.
+func (c Claims) Values(name string) []string {
+	v, ok := c.Raw[name]
+	if !ok {
+		switch name {
+		case "sub":
+			v = c.Subject
+		case "iss":
+			v = c.Issuer
+		case "client_id":
+			v = c.ClientID
+		case "scope":
+			v = c.Scopes
+		default:
+			return nil
+		}
+	}
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if t == "" {
+			return nil
+		}
+		if name == "scope" {
+			return strings.Fields(t)
+		}
+		return []string{t}
+	case []any:
+		values := make([]string, 0, len(t))
+		for _, e := range t {
+			values = append(values, fmt.Sprint(e))
+		}
+		return values
+	case []string:
+		return t
+	default:
+		return []string{fmt.Sprint(t)}
+	}
+}
+
+// Principal returns the identity to record on the uploads and reports of these claims.
+func (c Claims) Principal() *metadata.Principal {
+	return &metadata.Principal{
+		Subject:  c.Subject,
+		ClientID: c.ClientID,
+		Issuer:   c.Issuer,
+		Method:   c.Method,
+	}
+}
+
+type claimsKey struct{}
+
+// NewContext returns a copy of ctx carrying the validated token claims.
+func NewContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the validated token claims carried by ctx, if any.
+func FromContext(ctx context.Context) (Claims, bool) {
+	if ctx == nil {
+		return Claims{}, false
+	}
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
 }
 
 type Validator interface {
@@ -76,7 +165,7 @@ func (v *OAuthValidator) ValidateJWT(ctx context.Context, token string) (Claims,
 		}
 		v.provider = p
 	}
-	var claims Claims
+	claims := Claims{Method: MethodJWT}
 
 	verifier := v.provider.Verifier(&oidc.Config{SkipClientIDCheck: true})
 	idToken, err := verifier.Verify(ctx, token)
@@ -87,6 +176,9 @@ func (v *OAuthValidator) ValidateJWT(ctx context.Context, token string) (Claims,
 	if err = idToken.Claims(&claims); err != nil {
 		return claims, errors.Join(ErrTokenClaimsFailed, err)
 	}
+	if err = idToken.Claims(&claims.Raw); err != nil {
+		return claims, errors.Join(ErrTokenClaimsFailed, err)
+	}
 
 	actualScopes := strings.Split(claims.Scopes, " ")
 
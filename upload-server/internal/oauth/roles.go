@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+)
+
+type Role string
+
+// Every authenticated principal is a sender.  The other roles are granted by the role mapping.
+const (
+	// RoleSender can upload and see their own uploads.
+	RoleSender Role = "sender"
+	// RoleProgramViewer can also see the uploads of the data streams in their data_streams claim.
+	RoleProgramViewer Role = "program_viewer"
+	// RoleOperator can see every upload and retry deliveries.
+	RoleOperator Role = "operator"
+	// RoleAdmin can do everything, including managing API keys and sessions.
+	RoleAdmin Role = "admin"
+)
+
+// RoleMapping grants roles to principals whose role claim holds one of a role's values.  The admin scope grants
+// the admin role as well.  API keys only ever grant the sender role.
+type RoleMapping struct {
+	Claim      string
+	AdminScope string
+	Values     map[Role][]string
+}
+
+// Roles returns the roles granted to the claims, most privileged first.
+func (m RoleMapping) Roles(claims Claims) []Role {
+	if claims.Method == MethodAPIKey {
+		return []Role{RoleSender}
+	}
+	var roles []Role
+	values := claims.Values(m.Claim)
+	for _, role := range []Role{RoleAdmin, RoleOperator, RoleProgramViewer} {
+		granted := slices.ContainsFunc(m.Values[role], func(v string) bool {
+			return slices.Contains(values, v)
+		})
+		if role == RoleAdmin && m.AdminScope != "" && slices.Contains(claims.Values("scope"), m.AdminScope) {
+			granted = true
+		}
+		if granted {
+			roles = append(roles, role)
+		}
+	}
+	return append(roles, RoleSender)
+}
+
+// HasRole reports whether the claims were granted the role, or a more privileged one.
+func (c Claims) HasRole(role Role) bool {
+	switch role {
+	case RoleSender:
+		return slices.Contains(c.Roles, RoleSender) || c.HasRole(RoleProgramViewer)
+	case RoleProgramViewer:
+		return slices.Contains(c.Roles, RoleProgramViewer) || c.HasRole(RoleOperator)
+	case RoleOperator:
+		return slices.Contains(c.Roles, RoleOperator) || c.HasRole(RoleAdmin)
+	default:
+		return slices.Contains(c.Roles, role)
+	}
+}
+
+// CanView reports whether the claims may see the upload with the manifest.  Operators and admins see every
+// upload, program viewers see the uploads of their data streams, and senders see the uploads they sent.
+func (c Claims) CanView(manifest map[string]string) bool {
+	if c.HasRole(RoleOperator) {
+		return true
+	}
+	if c.HasRole(RoleProgramViewer) {
+		stream := strings.ToLower(manifest["data_stream_id"] + "/" + manifest["data_stream_route"])
+		for _, glob := range c.Values(DataStreamsClaim) {
+			if ok, err := path.Match(strings.ToLower(glob), stream); err == nil && ok {
+				return true
+			}
+		}
+	}
+	p := metadata.GetPrincipal(manifest)
+	return p != nil && p.Subject != "" && p.Subject == c.Subject && p.Issuer == c.Issuer
+}
@@ -0,0 +1,124 @@
+package oauth
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+)
+
+var mapping = RoleMapping{
+	Claim:      "groups",
+	AdminScope: "dex:admin",
+	Values: map[Role][]string{
+		RoleAdmin:         {"dex-admins"},
+		RoleOperator:      {"dex-ops"},
+		RoleProgramViewer: {"program-a", "program-b"},
+	},
+}
+
+func TestRoleMapping(t *testing.T) {
+	cases := map[string]struct {
+		claims Claims
+		want   []Role
+	}{
+		"sender": {
+			claims: Claims{Subject: "alice", Raw: map[string]any{"groups": []any{"everyone"}}},
+			want:   []Role{RoleSender},
+		},
+		"program viewer": {
+			claims: Claims{Subject: "alice", Raw: map[string]any{"groups": []any{"everyone", "program-b"}}},
+			want:   []Role{RoleProgramViewer, RoleSender},
+		},
+		"operator": {
+			claims: Claims{Subject: "alice", Raw: map[string]any{"groups": "dex-ops"}},
+			want:   []Role{RoleOperator, RoleSender},
+		},
+		"admin scope": {
+			claims: Claims{Subject: "alice", Scopes: "openid dex:admin"},
+			want:   []Role{RoleAdmin, RoleSender},
+		},
+		"api key": {
+			claims: Claims{Subject: "alice", Method: MethodAPIKey, Raw: map[string]any{"groups": "dex-admins"}},
+			want:   []Role{RoleSender},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := mapping.Roles(c.claims); !slices.Equal(got, c.want) {
+				t.Errorf("expected roles %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestClaims_HasRole(t *testing.T) {
+	admin := Claims{Roles: []Role{RoleAdmin, RoleSender}}
+	for _, role := range []Role{RoleSender, RoleProgramViewer, RoleOperator, RoleAdmin} {
+		if !admin.HasRole(role) {
+			t.Errorf("expected admin to have the %s role", role)
+		}
+	}
+	viewer := Claims{Roles: []Role{RoleProgramViewer, RoleSender}}
+	if viewer.HasRole(RoleOperator) || viewer.HasRole(RoleAdmin) {
+		t.Error("expected program viewer not to have operator or admin roles")
+	}
+	if (Claims{}).HasRole(RoleSender) {
+		t.Error("expected claims without roles to have none")
+	}
+}
+
+func TestClaims_CanView(t *testing.T) {
+	manifest := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+	}
+	for k, v := range (&metadata.Principal{Subject: "alice", Issuer: "https://idp", Method: MethodJWT}).Fields() {
+		manifest[k] = v
+	}
+
+	cases := map[string]struct {
+		claims Claims
+		want   bool
+	}{
+		"own upload": {
+			claims: Claims{Subject: "alice", Issuer: "https://idp", Roles: []Role{RoleSender}},
+			want:   true,
+		},
+		"another sender's upload": {
+			claims: Claims{Subject: "bob", Issuer: "https://idp", Roles: []Role{RoleSender}},
+			want:   false,
+		},
+		"same subject from another issuer": {
+			claims: Claims{Subject: "alice", Issuer: "https://other", Roles: []Role{RoleSender}},
+			want:   false,
+		},
+		"program viewer of the data stream": {
+			claims: Claims{Subject: "bob", Roles: []Role{RoleProgramViewer, RoleSender}, Raw: map[string]any{DataStreamsClaim: []any{"DEXTesting/*"}}},
+			want:   true,
+		},
+		"program viewer of another data stream": {
+			claims: Claims{Subject: "bob", Roles: []Role{RoleProgramViewer, RoleSender}, Raw: map[string]any{DataStreamsClaim: []any{"other/*"}}},
+			want:   false,
+		},
+		"sender limited to the data stream": {
+			claims: Claims{Subject: "bob", Roles: []Role{RoleSender}, Raw: map[string]any{DataStreamsClaim: []any{"dextesting/*"}}},
+			want:   false,
+		},
+		"operator": {
+			claims: Claims{Subject: "bob", Roles: []Role{RoleOperator, RoleSender}},
+			want:   true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.claims.CanView(manifest); got != c.want {
+				t.Errorf("expected %t, got %t", c.want, got)
+			}
+		})
+	}
+
+	if (Claims{Subject: "", Roles: []Role{RoleSender}}).CanView(map[string]string{}) {
+		t.Error("expected unauthenticated uploads to only be visible to operators")
+	}
+}
@@ -13,7 +13,11 @@ import (
 
 func RouteAndDeliverHook() func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error) {
 	return func(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+		// publish from the request's span so delivery continues the upload's trace, but not its cancellation
 		ctx := context.TODO()
+		if event.Context != nil {
+			ctx = context.WithoutCancel(event.Context)
+		}
 		id := event.Upload.ID
 		meta := event.Upload.MetaData
 		if resp.ChangeFileInfo.MetaData != nil {
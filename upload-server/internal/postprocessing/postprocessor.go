@@ -2,6 +2,7 @@ package postprocessing
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -23,6 +24,14 @@ func ProcessFileReadyEvent(ctx context.Context, e *event.FileReady) error {
 		return fmt.Errorf("malformed file ready event %+v", e)
 	}
 
+	if src, ok := delivery.GetSource(delivery.UploadSrc); ok {
+		if _, err := src.GetSize(ctx, e.UploadId); errors.Is(err, delivery.ErrSrcFileNotExist) {
+			// the upload was terminated after it finished, so there is nothing left to deliver or retry
+			logger.Info("skipping file copy of terminated upload", "target", e.DestinationTarget)
+			return nil
+		}
+	}
+
 	logger.Info("starting file copy")
 
 	rb := reports.NewBuilder[reports.FileCopyContent](
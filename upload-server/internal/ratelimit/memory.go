@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// sweepSize is how many keys are kept before expired ones are cleared out.
+const sweepSize = 10000
+
+type counter struct {
+	value   int64
+	expires time.Time
+}
+
+// MemoryStore keeps the counters in process, so limits only apply per instance.
+type MemoryStore struct {
+	mux      sync.Mutex
+	buckets  map[string]time.Time
+	active   map[string]map[string]time.Time
+	counters map[string]counter
+	received map[string]counter
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  map[string]time.Time{},
+		active:   map[string]map[string]time.Time{},
+		counters: map[string]counter{},
+		received: map[string]counter{},
+	}
+}
+
+// Take implements the generic cell rate algorithm; the bucket is the theoretical arrival time of the next request.
+func (s *MemoryStore) Take(_ context.Context, key string, rate float64, burst int, now time.Time) (time.Duration, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.buckets) > sweepSize {
+		for k, tat := range s.buckets {
+			if tat.Before(now) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	tat, ok := s.buckets[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	allowAt := next.Add(-time.Duration(burst) * interval)
+	if now.Before(allowAt) {
+		return allowAt.Sub(now), nil
+	}
+	s.buckets[key] = next
+	return 0, nil
+}
+
+func (s *MemoryStore) Acquire(_ context.Context, key string, id string, limit int64, ttl time.Duration, now time.Time) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	set, ok := s.active[key]
+	if !ok {
+		set = map[string]time.Time{}
+		s.active[key] = set
+	}
+	for k, expires := range set {
+		if !expires.After(now) {
+			delete(set, k)
+		}
+	}
+	if _, ok := set[id]; !ok && int64(len(set)) >= limit {
+		return false, nil
+	}
+	set[id] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.active[key], id)
+	if len(s.active[key]) == 0 {
+		delete(s.active, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Add(_ context.Context, key string, n int64, limit int64, expires time.Time, now time.Time) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.counters) > sweepSize {
+		for k, c := range s.counters {
+			if !c.expires.After(now) {
+				delete(s.counters, k)
+			}
+		}
+	}
+	c := s.counters[key]
+	if !c.expires.After(now) {
+		c = counter{expires: expires}
+	}
+	if c.value+n > limit {
+		return false, nil
+	}
+	c.value += n
+	s.counters[key] = c
+	return true, nil
+}
+
+func (s *MemoryStore) Track(_ context.Context, id string, ttl time.Duration, now time.Time) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.received) > sweepSize {
+		for k, c := range s.received {
+			if !c.expires.After(now) {
+				delete(s.received, k)
+			}
+		}
+	}
+	if c, ok := s.received[id]; ok && c.expires.After(now) {
+		return nil
+	}
+	s.received[id] = counter{expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Advance(_ context.Context, id string, offset int64, ttl time.Duration, now time.Time) (int64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	c, ok := s.received[id]
+	if !ok || !c.expires.After(now) {
+		delete(s.received, id)
+		return 0, nil
+	}
+	added := max(0, offset-c.value)
+	s.received[id] = counter{value: c.value + added, expires: now.Add(ttl)}
+	return added, nil
+}
+
+func (s *MemoryStore) Untrack(_ context.Context, id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.received, id)
+	return nil
+}
+
+func (s *MemoryStore) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Rate limit memory store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	return rsp
+}
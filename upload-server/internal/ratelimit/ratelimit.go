@@ -0,0 +1,437 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	pkgmetadata "github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+const (
+	ScopePrincipal  = "principal"
+	ScopeDataStream = "data_stream"
+
+	LimitRequests          = "requests"
+	LimitConcurrentUploads = "concurrent_uploads"
+	LimitBytesPerDay       = "bytes_per_day"
+	LimitUploadSize        = "upload_size"
+
+	// ConcurrentRetryAfter is suggested to senders that hit the concurrent upload limit, since there is no way to
+	// know when one of their uploads will finish.
+	ConcurrentRetryAfter = 30 * time.Second
+	// DefaultActiveUploadTTL bounds how long an upload that is never finished or terminated counts against the
+	// concurrent upload limit.
+	DefaultActiveUploadTTL = 24 * time.Hour
+)
+
+var ErrLimitExceeded = errors.New("rate limit exceeded")
+
+// Limits are applied independently to each principal or data stream.  A zero value disables that limit.
+type Limits struct {
+	RequestsPerSecond    float64
+	Burst                int
+	MaxConcurrentUploads int64
+	MaxBytesPerDay       int64
+	MaxUploadSize        int64
+}
+
+func (l Limits) IsEmpty() bool {
+	return l == Limits{}
+}
+
+func (l Limits) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return max(1, int(math.Ceil(l.RequestsPerSecond)))
+}
+
+// Store keeps the counters behind the limits so that they can be shared between instances.
+type Store interface {
+	health.Checkable
+	// Take spends one request from the key's bucket and returns how long to wait before retrying when it is empty.
+	Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (time.Duration, error)
+	// Acquire adds id to the key's active set unless that would exceed limit.  Entries expire after ttl.
+	Acquire(ctx context.Context, key string, id string, limit int64, ttl time.Duration, now time.Time) (bool, error)
+	Release(ctx context.Context, key string, id string) error
+	// Add adds n to the key's counter, which resets at expires, unless that would exceed limit.
+	Add(ctx context.Context, key string, n int64, limit int64, expires time.Time, now time.Time) (bool, error)
+	// Track starts counting the bytes of an upload as they are received.  Entries expire after ttl without progress.
+	Track(ctx context.Context, id string, ttl time.Duration, now time.Time) error
+	// Advance moves the counted offset of a tracked upload forward to offset and returns how many bytes that
+	// added.  Uploads that are not tracked add nothing.
+	Advance(ctx context.Context, id string, offset int64, ttl time.Duration, now time.Time) (int64, error)
+	Untrack(ctx context.Context, id string) error
+}
+
+// ErrorLimitExceeded describes which limit rejected a request.
+type ErrorLimitExceeded struct {
+	Scope      string
+	Limit      string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *ErrorLimitExceeded) Error() string {
+	return fmt.Sprintf("%s %s limit exceeded", strings.ReplaceAll(e.Scope, "_", " "), strings.ReplaceAll(e.Limit, "_", " "))
+}
+
+func (e *ErrorLimitExceeded) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+func (e *ErrorLimitExceeded) Header() map[string]string {
+	if e.RetryAfter <= 0 {
+		return nil
+	}
+	return map[string]string{
+		"Retry-After": strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))),
+	}
+}
+
+type Limiter struct {
+	Store           Store
+	Principal       Limits
+	DataStream      Limits
+	ActiveUploadTTL time.Duration
+	now             func() time.Time
+}
+
+func (l *Limiter) time() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now().UTC()
+}
+
+func (l *Limiter) ttl() time.Duration {
+	if l.ActiveUploadTTL > 0 {
+		return l.ActiveUploadTTL
+	}
+	return DefaultActiveUploadTTL
+}
+
+// PrincipalKey identifies the sender of a request by the principal recorded on its manifest or its authenticated
+// identity, or by its address when the request was not authenticated.
+func PrincipalKey(ctx context.Context, manifest map[string]string, remoteAddr string) string {
+	if p := pkgmetadata.GetPrincipal(manifest); p != nil && (p.Subject != "" || p.ClientID != "") {
+		return identity(p.Method, p.Issuer, p.Subject, p.ClientID)
+	}
+	if claims, ok := oauth.FromContext(ctx); ok && (claims.Subject != "" || claims.ClientID != "") {
+		return identity(claims.Method, claims.Issuer, claims.Subject, claims.ClientID)
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "addr:" + host
+}
+
+func identity(method string, issuer string, subject string, clientID string) string {
+	if subject == "" {
+		subject = clientID
+	}
+	return strings.Join([]string{method, issuer, subject}, ":")
+}
+
+func reject(err *ErrorLimitExceeded) error {
+	metrics.RateLimitRejections.WithLabelValues(err.Scope, err.Limit).Inc()
+	return err
+}
+
+func (l *Limiter) takeRequest(ctx context.Context, scope string, key string, limits Limits) error {
+	if limits.RequestsPerSecond <= 0 {
+		return nil
+	}
+	wait, err := l.Store.Take(ctx, "requests:"+scope+":"+key, limits.RequestsPerSecond, limits.burst(), l.time())
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return reject(&ErrorLimitExceeded{
+			Scope:      scope,
+			Limit:      LimitRequests,
+			StatusCode: http.StatusTooManyRequests,
+			RetryAfter: wait,
+		})
+	}
+	return nil
+}
+
+// Middleware rejects requests with 429 once the sender has used up its requests per second.  It must run after
+// authentication so that senders are identified by their token rather than their address.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := PrincipalKey(r.Context(), nil, r.RemoteAddr)
+		if err := l.takeRequest(r.Context(), ScopePrincipal, key, l.Principal); err != nil {
+			var exceeded *ErrorLimitExceeded
+			if !errors.As(err, &exceeded) {
+				// an unavailable store should not take uploads down with it
+				sloger.FromContext(r.Context()).Error("failed to check rate limit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			for k, v := range exceeded.Header() {
+				w.Header().Set(k, v)
+			}
+			http.Error(w, exceeded.Error(), exceeded.StatusCode)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type scoped struct {
+	scope  string
+	key    string
+	limits Limits
+}
+
+func checkSize(size int64, scopes []scoped) error {
+	for _, s := range scopes {
+		if s.limits.MaxUploadSize > 0 && size > s.limits.MaxUploadSize {
+			return reject(&ErrorLimitExceeded{
+				Scope:      s.scope,
+				Limit:      LimitUploadSize,
+				StatusCode: http.StatusRequestEntityTooLarge,
+			})
+		}
+	}
+	return nil
+}
+
+// addBytes counts n bytes against the daily byte limits of the scopes.
+func (l *Limiter) addBytes(ctx context.Context, n int64, scopes []scoped) error {
+	now := l.time()
+	day := now.Truncate(24 * time.Hour)
+	tomorrow := day.Add(24 * time.Hour)
+	for _, s := range scopes {
+		if s.limits.MaxBytesPerDay <= 0 {
+			continue
+		}
+		ok, err := l.Store.Add(ctx, "bytes:"+s.scope+":"+s.key+":"+day.Format(time.DateOnly), n, s.limits.MaxBytesPerDay, tomorrow, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return reject(&ErrorLimitExceeded{
+				Scope:      s.scope,
+				Limit:      LimitBytesPerDay,
+				StatusCode: http.StatusTooManyRequests,
+				RetryAfter: tomorrow.Sub(now),
+			})
+		}
+	}
+	return nil
+}
+
+func countsBytes(scopes []scoped) bool {
+	for _, s := range scopes {
+		if s.limits.MaxUploadSize > 0 || s.limits.MaxBytesPerDay > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Limiter) check(ctx context.Context, uploadID string, size int64, scopes []scoped) error {
+	now := l.time()
+	if err := checkSize(size, scopes); err != nil {
+		return err
+	}
+	for _, s := range scopes {
+		if s.scope == ScopeDataStream {
+			// principals are limited per request by the middleware, data streams are only known once an upload is created
+			if err := l.takeRequest(ctx, s.scope, s.key, s.limits); err != nil {
+				return err
+			}
+		}
+	}
+
+	var acquired []scoped
+	release := func() {
+		for _, a := range acquired {
+			l.Store.Release(ctx, "active:"+a.scope+":"+a.key, uploadID)
+		}
+	}
+	for _, s := range scopes {
+		if s.limits.MaxConcurrentUploads <= 0 {
+			continue
+		}
+		ok, err := l.Store.Acquire(ctx, "active:"+s.scope+":"+s.key, uploadID, s.limits.MaxConcurrentUploads, l.ttl(), now)
+		if err != nil {
+			release()
+			return err
+		}
+		if !ok {
+			release()
+			return reject(&ErrorLimitExceeded{
+				Scope:      s.scope,
+				Limit:      LimitConcurrentUploads,
+				StatusCode: http.StatusTooManyRequests,
+				RetryAfter: ConcurrentRetryAfter,
+			})
+		}
+		acquired = append(acquired, s)
+	}
+
+	if err := l.addBytes(ctx, size, scopes); err != nil {
+		release()
+		return err
+	}
+	return nil
+}
+
+func (l *Limiter) scopes(event *handler.HookEvent, manifest map[string]string) []scoped {
+	scopes := []scoped{}
+	if !l.Principal.IsEmpty() {
+		scopes = append(scopes, scoped{ScopePrincipal, PrincipalKey(event.Context, manifest, event.HTTPRequest.RemoteAddr), l.Principal})
+	}
+	if !l.DataStream.IsEmpty() {
+		if path, err := metadata.NewFromManifest(manifest); err == nil {
+			scopes = append(scopes, scoped{ScopeDataStream, strings.ToLower(path.Path()), l.DataStream})
+		}
+	}
+	return scopes
+}
+
+// Check is a pre-create hook that enforces the upload size, concurrent upload and daily byte limits of the sender
+// and of the manifest's data stream, along with the data stream's requests per second.  The declared size of the
+// upload counts against the daily bytes as soon as it is created.  Uploads created without a length are counted as
+// their bytes are received instead, by Enforce and Count.  The partial uploads of a concatenation are only limited
+// by the sender's limits, and their final upload counts its bytes against the data stream alone.
+func (l *Limiter) Check(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	tuid, err := metadata.GetUploadId(*event, resp)
+	if err != nil {
+		return resp, err
+	}
+	manifest := event.Upload.MetaData
+	if resp.ChangeFileInfo.MetaData != nil {
+		manifest = resp.ChangeFileInfo.MetaData
+	}
+
+	size, deferred := event.Upload.Size, event.Upload.SizeIsDeferred
+	scopes := l.scopes(event, manifest)
+	if event.Upload.IsFinal {
+		// the partial uploads, which carry no manifest, already counted these bytes against the sender
+		for i, s := range scopes {
+			if s.scope == ScopePrincipal {
+				scopes[i].limits.MaxBytesPerDay = 0
+			}
+		}
+	}
+	err = l.check(event.Context, tuid, size, scopes)
+	if err == nil && deferred && countsBytes(scopes) {
+		err = l.Store.Track(event.Context, tuid, l.ttl(), l.time())
+	}
+	var exceeded *ErrorLimitExceeded
+	if errors.As(err, &exceeded) {
+		sloger.FromContext(event.Context).Warn("upload rejected by rate limit", "scope", exceeded.Scope, "limit", exceeded.Limit)
+		resp.RejectUpload = true
+		resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+			StatusCode: exceeded.StatusCode,
+			Body:       exceeded.Error() + "\n",
+			Header:     exceeded.Header(),
+		})
+		return resp, nil
+	}
+	if err != nil {
+		sloger.FromContext(event.Context).Error("failed to check upload limits", "error", err)
+	}
+	return resp, nil
+}
+
+// uploadID is the id the upload was assigned at creation, since stores such as s3 add their own suffix to it.
+func uploadID(event *handler.HookEvent) string {
+	if id, ok := event.Upload.MetaData["upload_id"]; ok {
+		return id
+	}
+	return event.Upload.ID
+}
+
+// count counts the bytes received since the last count of an upload created without a length, and checks it
+// against the upload size limits.
+func (l *Limiter) count(event *handler.HookEvent) error {
+	ctx := event.Context
+	n, err := l.Store.Advance(ctx, uploadID(event), event.Upload.Offset, l.ttl(), l.time())
+	if err != nil || n == 0 {
+		return err
+	}
+	size := event.Upload.Offset
+	if !event.Upload.SizeIsDeferred {
+		size = max(size, event.Upload.Size)
+	}
+	scopes := l.scopes(event, event.Upload.MetaData)
+	if err := checkSize(size, scopes); err != nil {
+		return err
+	}
+	return l.addBytes(ctx, n, scopes)
+}
+
+// Enforce is a post-receive hook that counts the bytes of uploads created without a length as they are received,
+// and stops and terminates them once they go over an upload size or daily byte limit.
+func (l *Limiter) Enforce(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	err := l.count(event)
+	var exceeded *ErrorLimitExceeded
+	if errors.As(err, &exceeded) {
+		sloger.FromContext(event.Context).Warn("upload stopped by rate limit", "scope", exceeded.Scope, "limit", exceeded.Limit)
+		resp.StopUpload = true
+		resp.HTTPResponse = resp.HTTPResponse.MergeWith(handler.HTTPResponse{
+			StatusCode: exceeded.StatusCode,
+			Body:       exceeded.Error() + "\n",
+			Header:     exceeded.Header(),
+		})
+		return resp, nil
+	}
+	if err != nil {
+		sloger.FromContext(event.Context).Error("failed to count upload bytes", "error", err)
+	}
+	return resp, nil
+}
+
+// Count is a post-finish hook that counts the bytes of uploads created without a length that were received after
+// the last run of Enforce.  The upload is already finished, so they are counted even over the limits.
+func (l *Limiter) Count(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	err := l.count(event)
+	var exceeded *ErrorLimitExceeded
+	if errors.As(err, &exceeded) {
+		sloger.FromContext(event.Context).Warn("upload finished over rate limit", "scope", exceeded.Scope, "limit", exceeded.Limit)
+		return resp, nil
+	}
+	if err != nil {
+		sloger.FromContext(event.Context).Error("failed to count upload bytes", "error", err)
+	}
+	return resp, nil
+}
+
+// Release is a post-finish and post-terminate hook that frees the upload's place in the concurrent upload limits
+// and stops counting its bytes.
+func (l *Limiter) Release(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	tuid := uploadID(event)
+	if err := l.Store.Untrack(event.Context, tuid); err != nil {
+		sloger.FromContext(event.Context).Error("failed to stop counting upload bytes", "error", err)
+	}
+	for _, s := range l.scopes(event, event.Upload.MetaData) {
+		if s.limits.MaxConcurrentUploads <= 0 {
+			continue
+		}
+		if err := l.Store.Release(event.Context, "active:"+s.scope+":"+s.key, tuid); err != nil {
+			sloger.FromContext(event.Context).Error("failed to release concurrent upload", "scope", s.scope, "error", err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,327 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/redis/go-redis/v9"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+var start = time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)
+
+func stores(t *testing.T) map[string]Store {
+	mr := miniredis.RunT(t)
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  &RedisStore{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()})},
+	}
+}
+
+func TestStoreTake(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 3; i++ {
+				wait, err := store.Take(ctx, "k", 2, 3, start)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wait != 0 {
+					t.Fatalf("request %d within burst was limited, wait %s", i, wait)
+				}
+			}
+			wait, err := store.Take(ctx, "k", 2, 3, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait != 500*time.Millisecond {
+				t.Errorf("expected to wait 500ms after burst, got %s", wait)
+			}
+			wait, err = store.Take(ctx, "k", 2, 3, start.Add(500*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait != 0 {
+				t.Errorf("expected a request after waiting, got wait %s", wait)
+			}
+		})
+	}
+}
+
+func TestStoreAcquire(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, id := range []string{"a", "b", "a"} {
+				if ok, err := store.Acquire(ctx, "k", id, 2, time.Hour, start); err != nil || !ok {
+					t.Fatalf("failed to acquire %s: %v %v", id, ok, err)
+				}
+			}
+			if ok, err := store.Acquire(ctx, "k", "c", 2, time.Hour, start); err != nil || ok {
+				t.Fatalf("acquired past the limit: %v %v", ok, err)
+			}
+			if err := store.Release(ctx, "k", "a"); err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := store.Acquire(ctx, "k", "c", 2, time.Hour, start); err != nil || !ok {
+				t.Fatalf("failed to acquire after release: %v %v", ok, err)
+			}
+			if ok, err := store.Acquire(ctx, "k", "d", 2, time.Hour, start.Add(2*time.Hour)); err != nil || !ok {
+				t.Fatalf("failed to acquire after expiry: %v %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestStoreAdd(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			expires := time.Now().Add(time.Hour)
+			if ok, err := store.Add(ctx, "k", 60, 100, expires, time.Now()); err != nil || !ok {
+				t.Fatalf("failed to add: %v %v", ok, err)
+			}
+			if ok, err := store.Add(ctx, "k", 60, 100, expires, time.Now()); err != nil || ok {
+				t.Fatalf("added past the limit: %v %v", ok, err)
+			}
+			if ok, err := store.Add(ctx, "k", 40, 100, expires, time.Now()); err != nil || !ok {
+				t.Fatalf("failed to add up to the limit: %v %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestStoreAdvance(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if n, err := store.Advance(ctx, "untracked", 10, time.Hour, time.Now()); err != nil || n != 0 {
+				t.Fatalf("counted an untracked upload: %d %v", n, err)
+			}
+			if err := store.Track(ctx, "id", time.Hour, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := store.Advance(ctx, "id", 10, time.Hour, time.Now()); err != nil || n != 10 {
+				t.Fatalf("expected 10 bytes, got %d %v", n, err)
+			}
+			if n, err := store.Advance(ctx, "id", 25, time.Hour, time.Now()); err != nil || n != 15 {
+				t.Fatalf("expected 15 more bytes, got %d %v", n, err)
+			}
+			if n, err := store.Advance(ctx, "id", 20, time.Hour, time.Now()); err != nil || n != 0 {
+				t.Fatalf("counted an offset that went back: %d %v", n, err)
+			}
+			if err := store.Untrack(ctx, "id"); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := store.Advance(ctx, "id", 30, time.Hour, time.Now()); err != nil || n != 0 {
+				t.Fatalf("counted an untracked upload: %d %v", n, err)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := &Limiter{
+		Store:     NewMemoryStore(),
+		Principal: Limits{RequestsPerSecond: 1},
+		now:       func() time.Time { return start },
+	}
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(subject string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPatch, "/files/abc", nil)
+		r = r.WithContext(oauth.NewContext(r.Context(), oauth.Claims{Subject: subject, Issuer: "test", Method: oauth.MethodJWT}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := send("alice"); w.Code != http.StatusOK {
+		t.Fatalf("first request rejected with %d", w.Code)
+	}
+	w := send("alice")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if ra := w.Header().Get("Retry-After"); ra != "1" {
+		t.Errorf("expected Retry-After 1, got %q", ra)
+	}
+	if w := send("bob"); w.Code != http.StatusOK {
+		t.Errorf("another principal was limited with %d", w.Code)
+	}
+}
+
+func preCreate(id string, size int64, manifest map[string]string) (*handler.HookEvent, hooks.HookResponse) {
+	ctx := oauth.NewContext(context.Background(), oauth.Claims{Subject: "alice", Issuer: "test", Method: oauth.MethodJWT})
+	return &handler.HookEvent{
+		Context: ctx,
+		Upload: handler.FileInfo{
+			Size:     size,
+			MetaData: manifest,
+		},
+		HTTPRequest: handler.HTTPRequest{RemoteAddr: "127.0.0.1:1234"},
+	}, hooks.HookResponse{ChangeFileInfo: handler.FileInfoChanges{ID: id}}
+}
+
+func TestCheck(t *testing.T) {
+	manifest := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+	}
+	l := &Limiter{
+		Store:      NewMemoryStore(),
+		Principal:  Limits{MaxConcurrentUploads: 2, MaxUploadSize: 100},
+		DataStream: Limits{MaxBytesPerDay: 150},
+		now:        func() time.Time { return start },
+	}
+
+	check := func(id string, size int64) hooks.HookResponse {
+		event, resp := preCreate(id, size, manifest)
+		resp, err := l.Check(event, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := check("too-big", 101)
+	if !resp.RejectUpload || resp.HTTPResponse.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an upload over the size limit, got %+v", resp)
+	}
+
+	if resp := check("one", 50); resp.RejectUpload {
+		t.Fatalf("first upload rejected: %+v", resp.HTTPResponse)
+	}
+	if resp := check("two", 50); resp.RejectUpload {
+		t.Fatalf("second upload rejected: %+v", resp.HTTPResponse)
+	}
+	resp = check("three", 10)
+	if resp.HTTPResponse.StatusCode != http.StatusTooManyRequests || resp.HTTPResponse.Header["Retry-After"] != "30" {
+		t.Errorf("expected 429 for too many concurrent uploads, got %+v", resp.HTTPResponse)
+	}
+
+	event, _ := preCreate("", 0, manifest)
+	event.Upload.ID = "one"
+	if _, err := l.Release(event, hooks.HookResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	resp = check("four", 60)
+	if resp.HTTPResponse.StatusCode != http.StatusTooManyRequests || resp.HTTPResponse.Header["Retry-After"] != "3600" {
+		t.Errorf("expected 429 until midnight for the daily byte quota, got %+v", resp.HTTPResponse)
+	}
+	if resp := check("five", 50); resp.RejectUpload {
+		t.Errorf("upload within quota rejected after release: %+v", resp.HTTPResponse)
+	}
+}
+
+func TestDeferredLength(t *testing.T) {
+	manifest := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+	}
+	l := &Limiter{
+		Store:      NewMemoryStore(),
+		Principal:  Limits{MaxUploadSize: 100},
+		DataStream: Limits{MaxBytesPerDay: 150},
+		now:        func() time.Time { return start },
+	}
+
+	create := func(id string) {
+		event, resp := preCreate(id, 0, manifest)
+		event.Upload.SizeIsDeferred = true
+		resp, err := l.Check(event, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.RejectUpload {
+			t.Fatalf("deferred length upload rejected: %+v", resp.HTTPResponse)
+		}
+	}
+	receive := func(hook func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error), id string, offset int64) hooks.HookResponse {
+		event, _ := preCreate("", 0, manifest)
+		event.Upload.ID = id
+		event.Upload.SizeIsDeferred = true
+		event.Upload.Offset = offset
+		resp, err := hook(event, hooks.HookResponse{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	create("one")
+	if resp := receive(l.Enforce, "one", 60); resp.StopUpload {
+		t.Fatalf("upload within limits stopped: %+v", resp.HTTPResponse)
+	}
+	resp := receive(l.Enforce, "one", 101)
+	if !resp.StopUpload || resp.HTTPResponse.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 once the upload went over the size limit, got %+v", resp)
+	}
+
+	create("two")
+	receive(l.Enforce, "two", 50)
+	receive(l.Count, "two", 80)
+	receive(l.Release, "two", 80)
+	if resp := receive(l.Enforce, "two", 90); resp.StopUpload {
+		t.Errorf("released upload still counted: %+v", resp.HTTPResponse)
+	}
+
+	create("three")
+	resp = receive(l.Enforce, "three", 20)
+	if !resp.StopUpload || resp.HTTPResponse.StatusCode != http.StatusTooManyRequests || resp.HTTPResponse.Header["Retry-After"] != "3600" {
+		t.Errorf("expected 429 once the daily byte quota was used up, got %+v", resp)
+	}
+}
+
+func TestConcatenation(t *testing.T) {
+	manifest := map[string]string{
+		"data_stream_id":    "dextesting",
+		"data_stream_route": "testevent1",
+	}
+	l := &Limiter{
+		Store:      NewMemoryStore(),
+		Principal:  Limits{MaxBytesPerDay: 100},
+		DataStream: Limits{MaxBytesPerDay: 100},
+		now:        func() time.Time { return start },
+	}
+
+	check := func(id string, size int64, partial bool, meta map[string]string) hooks.HookResponse {
+		event, resp := preCreate(id, size, meta)
+		event.Upload.IsPartial = partial
+		event.Upload.IsFinal = !partial
+		resp, err := l.Check(event, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := check("part-1", 60, true, nil); resp.RejectUpload {
+		t.Fatalf("partial upload rejected: %+v", resp.HTTPResponse)
+	}
+	resp := check("part-2", 60, true, nil)
+	if resp.HTTPResponse.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected partial uploads to count against the sender's daily bytes, got %+v", resp.HTTPResponse)
+	}
+	if resp := check("final", 60, false, manifest); resp.RejectUpload {
+		t.Errorf("expected the final upload to count only against the data stream, got %+v", resp.HTTPResponse)
+	}
+}
+
+func TestErrorLimitExceeded(t *testing.T) {
+	err := error(&ErrorLimitExceeded{Scope: ScopeDataStream, Limit: LimitBytesPerDay})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Error("expected ErrLimitExceeded")
+	}
+	if err.Error() != "data stream bytes per day limit exceeded" {
+		t.Errorf("unexpected message %q", err)
+	}
+}
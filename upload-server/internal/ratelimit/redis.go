@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "ratelimit:"
+
+// takeScript is the generic cell rate algorithm; the key holds the theoretical arrival time of the next request
+// in milliseconds.
+var takeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next = tat + interval
+local allow_at = next - burst * interval
+if now < allow_at then
+	return math.ceil(allow_at - now)
+end
+redis.call('SET', KEYS[1], next, 'PX', math.ceil(next - now))
+return 0
+`)
+
+// acquireScript keeps active ids in a sorted set scored by when they expire.
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expires = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if not redis.call('ZSCORE', KEYS[1], ARGV[4]) and redis.call('ZCARD', KEYS[1]) >= limit then
+	return 0
+end
+redis.call('ZADD', KEYS[1], expires, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+var addScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local current = tonumber(redis.call('GET', KEYS[1]) or 0)
+if current + n > limit then
+	return 0
+end
+redis.call('INCRBY', KEYS[1], n)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// advanceScript moves the received offset of a tracked upload forward and returns how many bytes that added.
+var advanceScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+local offset = tonumber(ARGV[1])
+local added = offset - tonumber(current)
+if added < 0 then
+	added = 0
+	offset = tonumber(current)
+end
+redis.call('SET', KEYS[1], offset, 'PX', ARGV[2])
+return added
+`)
+
+// RedisStore shares the counters between instances.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+func NewRedisStore(uri string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &RedisStore{Client: client}, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (time.Duration, error) {
+	interval := float64(time.Second.Milliseconds()) / rate
+	wait, err := takeScript.Run(ctx, s.Client, []string{redisKeyPrefix + key}, now.UnixMilli(), interval, burst).Float64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait * float64(time.Millisecond)), nil
+}
+
+func (s *RedisStore) Acquire(ctx context.Context, key string, id string, limit int64, ttl time.Duration, now time.Time) (bool, error) {
+	return runBool(ctx, s.Client, acquireScript, redisKeyPrefix+key, now.UnixMilli(), now.Add(ttl).UnixMilli(), limit, id, ttl.Milliseconds())
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string, id string) error {
+	return s.Client.ZRem(ctx, redisKeyPrefix+key, id).Err()
+}
+
+func (s *RedisStore) Add(ctx context.Context, key string, n int64, limit int64, expires time.Time, now time.Time) (bool, error) {
+	return runBool(ctx, s.Client, addScript, redisKeyPrefix+key, n, limit, max(1, expires.Sub(now).Milliseconds()))
+}
+
+func (s *RedisStore) Track(ctx context.Context, id string, ttl time.Duration, _ time.Time) error {
+	return s.Client.SetNX(ctx, redisKeyPrefix+"received:"+id, 0, ttl).Err()
+}
+
+func (s *RedisStore) Advance(ctx context.Context, id string, offset int64, ttl time.Duration, _ time.Time) (int64, error) {
+	return advanceScript.Run(ctx, s.Client, []string{redisKeyPrefix + "received:" + id}, offset, ttl.Milliseconds()).Int64()
+}
+
+func (s *RedisStore) Untrack(ctx context.Context, id string) error {
+	return s.Client.Del(ctx, redisKeyPrefix+"received:"+id).Err()
+}
+
+func runBool(ctx context.Context, client *redis.Client, script *redis.Script, key string, args ...any) (bool, error) {
+	ok, err := script.Run(ctx, client, []string{key}, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+func (s *RedisStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Rate limit redis store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.Client.Ping(ctx).Err(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
@@ -0,0 +1,147 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// clamdChunkSize is how much of an upload is sent to clamd at a time; clamd's own StreamMaxLength limits the
+// size of the whole upload.
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner streams uploads to a clamd daemon with the INSTREAM command.
+type ClamdScanner struct {
+	// Network is tcp or unix.
+	Network string
+	Address string
+	// Timeout limits a whole scan, including sending the upload.
+	Timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner for a clamd daemon at a tcp://host:port or unix:///path/to/clamd.sock uri.
+func NewClamdScanner(uri string, timeout time.Duration) (*ClamdScanner, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	s := &ClamdScanner{Network: u.Scheme, Timeout: timeout}
+	switch u.Scheme {
+	case "tcp":
+		s.Address = u.Host
+	case "unix":
+		s.Address = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported clamd address %s, expected tcp:// or unix://", uri)
+	}
+	return s, nil
+}
+
+func (s *ClamdScanner) Name() string {
+	return "clamd"
+}
+
+// command sends a command to clamd and returns its reply.  The connection is closed when ctx is done.
+func (s *ClamdScanner) command(ctx context.Context, cmd string, body func(io.Writer) error) (string, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	_, err = conn.Write([]byte("z" + cmd + "\x00"))
+	if err == nil && body != nil {
+		err = body(conn)
+	}
+	// clamd replies before closing the connection on errors such as the stream size limit, so the reply explains
+	// a failed write better than the write error does
+	reply, rerr := bufio.NewReader(conn).ReadString(0)
+	if rerr != nil {
+		if err != nil {
+			return "", err
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", rerr
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+func instream(r io.Reader) func(io.Writer) error {
+	return func(w io.Writer) error {
+		buf := make([]byte, 4+clamdChunkSize)
+		for {
+			n, err := io.ReadFull(r, buf[4:])
+			if n > 0 {
+				binary.BigEndian.PutUint32(buf, uint32(n))
+				if _, werr := w.Write(buf[:4+n]); werr != nil {
+					return werr
+				}
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err := w.Write([]byte{0, 0, 0, 0})
+		return err
+	}
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	reply, err := s.command(ctx, "INSTREAM", instream(r))
+	if err != nil {
+		return nil, err
+	}
+	return parseReply(reply)
+}
+
+// parseReply reads replies of the form "stream: OK", "stream: <signature> FOUND" and "<reason> ERROR".
+func parseReply(reply string) (*Result, error) {
+	status := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case status == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+func (s *ClamdScanner) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Clamd malware scanner"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	reply, err := s.command(ctx, "PING", nil)
+	if err == nil && reply != "PONG" {
+		err = fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	if err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
@@ -0,0 +1,123 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metrics"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/sloger"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+type Result struct {
+	Infected bool
+	// Signature names the malware found.
+	Signature string
+}
+
+// Scanner scans the content of an upload for malware.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// Stage scans uploads before they are delivered.  Uploads are quarantined, left in upload storage and not
+// delivered, when they are infected or when they could not be scanned.
+type Stage struct {
+	Scanner Scanner
+	Source  delivery.Source
+}
+
+// Scan scans the upload in src and records the verdict in a malware-scan report.  Only clean uploads may be
+// delivered; an upload that could not be scanned is not clean, and the error says why.
+func (s *Stage) Scan(ctx context.Context, src delivery.Source, id string, manifest map[string]string) (clean bool, err error) {
+	logger := sloger.FromContext(ctx)
+	logger.Info("starting malware-scan")
+	start := time.Now()
+	rb := reports.NewBuilderWithManifest[reports.MalwareScanContent](
+		"1.0.0",
+		reports.StageMalwareScan,
+		id,
+		manifest,
+		reports.DispositionTypeAdd).SetStartTime(start.UTC())
+	content := reports.MalwareScanContent{
+		ReportContent: reports.ReportContent{
+			ContentSchemaVersion: "1.0.0",
+			ContentSchemaName:    reports.StageMalwareScan,
+		},
+		Scanner: s.Scanner.Name(),
+		Verdict: metrics.ScanVerdictClean,
+	}
+	defer func() {
+		metrics.MalwareScans.WithLabelValues(content.Verdict).Inc()
+		metrics.MalwareScanDurations.WithLabelValues(content.Verdict).Observe(time.Since(start).Seconds())
+		rb.SetEndTime(time.Now().UTC()).SetContent(content)
+		report := rb.Build()
+		logger.Info("REPORT malware-scan", "report", report)
+		reports.Publish(ctx, report)
+		logger.Info("malware-scan complete", "verdict", content.Verdict)
+	}()
+
+	result, err := s.scan(ctx, src, id)
+	if err != nil {
+		logger.Error("unable to scan upload for malware", "error", err)
+		content.Verdict = metrics.ScanVerdictError
+		content.Quarantined = true
+		rb.SetStatus(reports.StatusFailed).AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelError,
+			Message: "unable to scan upload: " + err.Error(),
+		})
+		return false, err
+	}
+	if result.Infected {
+		logger.Warn("malware found in upload", "signature", result.Signature)
+		content.Verdict = metrics.ScanVerdictInfected
+		content.Signature = result.Signature
+		content.Quarantined = true
+		rb.SetStatus(reports.StatusFailed).AppendIssue(reports.ReportIssue{
+			Level:   reports.IssueLevelError,
+			Message: "malware found: " + result.Signature,
+		})
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *Stage) scan(ctx context.Context, src delivery.Source, id string) (*Result, error) {
+	r, err := src.Reader(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	return s.Scanner.Scan(ctx, r)
+}
+
+// BeforeDelivery wraps the delivery hook so uploads are only delivered once they are scanned clean.
+func (s *Stage) BeforeDelivery(deliver func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error)) func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error) {
+	return func(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+		// scan without the request's cancellation, like delivery itself
+		ctx := context.TODO()
+		if event.Context != nil {
+			ctx = context.WithoutCancel(event.Context)
+		}
+		meta := event.Upload.MetaData
+		if resp.ChangeFileInfo.MetaData != nil {
+			meta = resp.ChangeFileInfo.MetaData
+		}
+		clean, err := s.Scan(ctx, s.Source, event.Upload.ID, meta)
+		if err != nil {
+			return resp, err
+		}
+		if !clean {
+			sloger.FromContext(ctx).Info("skipping delivery of quarantined upload")
+			return resp, nil
+		}
+		return deliver(event, resp)
+	}
+}
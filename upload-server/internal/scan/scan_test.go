@@ -0,0 +1,192 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/hooks"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM and PING like clamd, finding the EICAR test file and limiting streams to maxLength.
+func fakeClamd(t *testing.T, network string, address string, maxLength int) net.Listener {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				cmd := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, cmd[:len("zPING\x00")]); err != nil {
+					return
+				}
+				if string(cmd[:len("zPING\x00")]) == "zPING\x00" {
+					conn.Write([]byte("PONG\x00"))
+					return
+				}
+				if _, err := io.ReadFull(conn, cmd[len("zPING\x00"):]); err != nil {
+					return
+				}
+				var stream bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&stream, conn, int64(size)); err != nil {
+						return
+					}
+					if stream.Len() > maxLength {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+				}
+				if bytes.Contains(stream.Bytes(), []byte(eicar)) {
+					conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+	return l
+}
+
+func TestClamdScanner(t *testing.T) {
+	tcp := fakeClamd(t, "tcp", "127.0.0.1:0", 1024*1024)
+	unix := fakeClamd(t, "unix", filepath.Join(t.TempDir(), "clamd.sock"), 1024*1024)
+	scanners := map[string]string{
+		"tcp":  "tcp://" + tcp.Addr().String(),
+		"unix": "unix://" + unix.Addr().String(),
+	}
+	for name, uri := range scanners {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			scanner, err := NewClamdScanner(uri, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rsp := scanner.Health(ctx); rsp.Status != models.STATUS_UP {
+				t.Errorf("expected clamd to be up, got %+v", rsp)
+			}
+
+			result, err := scanner.Scan(ctx, bytes.NewReader(bytes.Repeat([]byte("clean "), 20000)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Infected {
+				t.Errorf("expected a clean upload, got %+v", result)
+			}
+
+			result, err = scanner.Scan(ctx, bytes.NewReader([]byte(eicar)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Infected || result.Signature != "Win.Test.EICAR_HDB-1" {
+				t.Errorf("expected the EICAR test file to be found, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestClamdScannerErrors(t *testing.T) {
+	ctx := context.Background()
+	l := fakeClamd(t, "tcp", "127.0.0.1:0", 10)
+	scanner, err := NewClamdScanner("tcp://"+l.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanner.Scan(ctx, bytes.NewReader(bytes.Repeat([]byte("large "), 100000))); err == nil {
+		t.Error("expected an upload over the stream limit to fail the scan")
+	}
+
+	if _, err := NewClamdScanner("http://localhost:3310", time.Second); err == nil {
+		t.Error("expected an unsupported address to be rejected")
+	}
+
+	l.Close()
+	if rsp := scanner.Health(ctx); rsp.Status != models.STATUS_DOWN {
+		t.Errorf("expected clamd to be down, got %+v", rsp)
+	}
+}
+
+type fakeScanner struct {
+	err error
+}
+
+func (s *fakeScanner) Name() string {
+	return "fake"
+}
+
+func (s *fakeScanner) Scan(_ context.Context, r io.Reader) (*Result, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(b, []byte(eicar)) {
+		return &Result{Infected: true, Signature: "EICAR"}, nil
+	}
+	return &Result{}, nil
+}
+
+func TestStageBeforeDelivery(t *testing.T) {
+	src := &delivery.FileSource{FS: fstest.MapFS{
+		"clean":    &fstest.MapFile{Data: []byte("hello world")},
+		"infected": &fstest.MapFile{Data: []byte(eicar)},
+	}}
+	type testCase struct {
+		id        string
+		scanErr   error
+		delivered bool
+		err       bool
+	}
+	testCases := []testCase{
+		{id: "clean", delivered: true},
+		{id: "infected"},
+		{id: "missing", err: true},
+		{id: "clean", scanErr: errors.New("clamd is down"), err: true},
+	}
+	for _, c := range testCases {
+		stage := &Stage{Scanner: &fakeScanner{err: c.scanErr}, Source: src}
+		delivered := false
+		deliver := stage.BeforeDelivery(func(*handler.HookEvent, hooks.HookResponse) (hooks.HookResponse, error) {
+			delivered = true
+			return hooks.HookResponse{}, nil
+		})
+		_, err := deliver(&handler.HookEvent{
+			Context: context.Background(),
+			Upload:  handler.FileInfo{ID: c.id},
+		}, hooks.HookResponse{})
+		if (err != nil) != c.err {
+			t.Errorf("%s: expected error %t, got %v", c.id, c.err, err)
+		}
+		if delivered != c.delivered {
+			t.Errorf("%s: expected delivered %t, got %t", c.id, c.delivered, delivered)
+		}
+	}
+}
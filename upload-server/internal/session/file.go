@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// FileStore keeps every session in a single JSON file.  It is meant for local development and single instance
+// deployments.
+type FileStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	sess, ok := sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return sess, nil
+}
+
+// Put also drops expired sessions so the file does not grow without bound.
+func (s *FileStore) Put(_ context.Context, sess *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, err := s.read()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for id, other := range sessions {
+		if !now.Before(other.ExpiresAt) {
+			delete(sessions, id)
+		}
+	}
+	sessions[sess.ID] = sess
+	return s.write(sessions)
+}
+
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(sessions, id)
+	return s.write(sessions)
+}
+
+func (s *FileStore) DeleteSubject(_ context.Context, subject string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for id, sess := range sessions {
+		if sess.Subject == subject {
+			delete(sessions, id)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, s.write(sessions)
+}
+
+func (s *FileStore) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Session file store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.read(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
+
+func (s *FileStore) read() (map[string]*Session, error) {
+	sessions := map[string]*Session{}
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return sessions, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// write replaces the file atomically so a crash never leaves a partial session file behind.  Sessions hold
+// tokens, so the file is only readable by its owner.
+func (s *FileStore) write(sessions map[string]*Session) error {
+	b, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
@@ -0,0 +1,113 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix     = "session:"
+	redisSubjectPrefix = "sessions:subject:"
+)
+
+// RedisStore keeps each session as JSON that expires with the session, along with a set of session ids per
+// subject so all of a user's sessions can be revoked.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+func NewRedisStore(uri string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &RedisStore{Client: client}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := s.Client.Get(ctx, redisKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess := &Session{}
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (s *RedisStore) Put(ctx context.Context, sess *Session) error {
+	ttl := time.Until(sess.ExpiresAt)
+	if ttl <= 0 {
+		return s.Delete(ctx, sess.ID)
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, redisKeyPrefix+sess.ID, data, ttl)
+		p.SAdd(ctx, redisSubjectPrefix+sess.Subject, sess.ID)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	sess, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, redisKeyPrefix+id)
+		p.SRem(ctx, redisSubjectPrefix+sess.Subject, id)
+		return nil
+	})
+	return err
+}
+
+// DeleteSubject only counts sessions that had not yet expired.
+func (s *RedisStore) DeleteSubject(ctx context.Context, subject string) (int, error) {
+	ids, err := s.Client.SMembers(ctx, redisSubjectPrefix+subject).Result()
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, redisKeyPrefix+id)
+	}
+	n := int64(0)
+	if len(keys) > 0 {
+		n, err = s.Client.Del(ctx, keys...).Result()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := s.Client.Del(ctx, redisSubjectPrefix+subject).Err(); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (s *RedisStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Session redis store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.Client.Ping(ctx).Err(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
@@ -0,0 +1,168 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+)
+
+const (
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultAbsoluteTimeout = 12 * time.Hour
+	// TouchInterval limits how often the last seen time of a session is written back to the store.
+	TouchInterval = time.Minute
+)
+
+var (
+	ErrNotFound = errors.New("session not found")
+	ErrExpired  = errors.New("session expired")
+)
+
+// Session holds a signed in user's token on the server.  The browser only holds the opaque session id, and the
+// store only holds a hash of it.
+type Session struct {
+	ID         string    `json:"id"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	Token      string    `json:"token"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// RefreshToken and IDToken are only set for sessions started with the authorization code flow.
+	RefreshToken   string    `json:"refresh_token,omitempty"`
+	IDToken        string    `json:"id_token,omitempty"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+}
+
+// Tokens returns the tokens held by the session.
+func (s *Session) Tokens() oauth.Tokens {
+	return oauth.Tokens{
+		AccessToken:  s.Token,
+		RefreshToken: s.RefreshToken,
+		IDToken:      s.IDToken,
+		Expiry:       s.TokenExpiresAt,
+	}
+}
+
+type Store interface {
+	health.Checkable
+	Get(ctx context.Context, id string) (*Session, error)
+	Put(ctx context.Context, s *Session) error
+	Delete(ctx context.Context, id string) error
+	// DeleteSubject removes every session of the subject and returns how many there were.
+	DeleteSubject(ctx context.Context, subject string) (int, error)
+}
+
+// Manager creates sessions and enforces their idle and absolute timeouts.
+type Manager struct {
+	Store           Store
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+}
+
+func (m *Manager) idleTimeout() time.Duration {
+	if m.IdleTimeout > 0 {
+		return m.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+func (m *Manager) absoluteTimeout() time.Duration {
+	if m.AbsoluteTimeout > 0 {
+		return m.AbsoluteTimeout
+	}
+	return DefaultAbsoluteTimeout
+}
+
+func hash(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create stores a new session for the tokens and returns the id to hand to the browser.  The session ends at
+// the absolute timeout, or when the token expires if it can not be refreshed.
+func (m *Manager) Create(ctx context.Context, tokens oauth.Tokens, claims oauth.Claims) (string, *Session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now().UTC()
+	s := &Session{
+		ID:         hash(id),
+		Subject:    claims.Subject,
+		Issuer:     claims.Issuer,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(m.absoluteTimeout()),
+	}
+	setTokens(s, tokens, claims)
+	if s.RefreshToken == "" && !s.TokenExpiresAt.IsZero() && s.TokenExpiresAt.Before(s.ExpiresAt) {
+		s.ExpiresAt = s.TokenExpiresAt
+	}
+	if err := m.Store.Put(ctx, s); err != nil {
+		return "", nil, err
+	}
+	return id, s, nil
+}
+
+// Refresh replaces the session's tokens with refreshed ones.
+func (m *Manager) Refresh(ctx context.Context, s *Session, tokens oauth.Tokens, claims oauth.Claims) error {
+	setTokens(s, tokens, claims)
+	return m.Store.Put(ctx, s)
+}
+
+func setTokens(s *Session, tokens oauth.Tokens, claims oauth.Claims) {
+	s.Token = tokens.Bearer()
+	s.RefreshToken = tokens.RefreshToken
+	s.IDToken = tokens.IDToken
+	s.TokenExpiresAt = tokens.Expiry.UTC()
+	if claims.Expiry > 0 {
+		s.TokenExpiresAt = time.Unix(claims.Expiry, 0).UTC()
+	}
+}
+
+// Get returns the session for the browser's id.  Sessions past their idle or absolute timeout are deleted and
+// ErrExpired is returned.
+func (m *Manager) Get(ctx context.Context, id string) (*Session, error) {
+	s, err := m.Store.Get(ctx, hash(id))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if !now.Before(s.ExpiresAt) || !now.Before(s.LastSeenAt.Add(m.idleTimeout())) {
+		if err := m.Store.Delete(ctx, s.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return nil, ErrExpired
+	}
+	if now.Sub(s.LastSeenAt) > TouchInterval {
+		s.LastSeenAt = now
+		if err := m.Store.Put(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Delete ends the session for the browser's id.
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	err := m.Store.Delete(ctx, hash(id))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// RevokeSubject ends every session of the subject, wherever it was started.
+func (m *Manager) RevokeSubject(ctx context.Context, subject string) (int, error) {
+	return m.Store.DeleteSubject(ctx, subject)
+}
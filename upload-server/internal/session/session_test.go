@@ -0,0 +1,111 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/redis/go-redis/v9"
+)
+
+func stores(t *testing.T) map[string]Store {
+	mr := miniredis.RunT(t)
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   &FileStore{Path: filepath.Join(t.TempDir(), "sessions.json")},
+		"redis":  &RedisStore{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()})},
+	}
+}
+
+func TestManager(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := &Manager{Store: store}
+			claims := oauth.Claims{Subject: "alice", Issuer: "test", Expiry: time.Now().Add(time.Hour).Unix()}
+
+			id, sess, err := m.Create(ctx, oauth.Tokens{AccessToken: "token"}, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sess.ID == id {
+				t.Error("store should only hold a hash of the session id")
+			}
+			if sess.ExpiresAt.After(time.Unix(claims.Expiry, 0)) {
+				t.Errorf("session outlives its token: %s", sess.ExpiresAt)
+			}
+
+			got, err := m.Get(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Token != "token" || got.Subject != "alice" {
+				t.Errorf("unexpected session %+v", got)
+			}
+
+			other, _, err := m.Create(ctx, oauth.Tokens{AccessToken: "other-token"}, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Delete(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected a logged out session to be gone, got %v", err)
+			}
+			if _, err := m.Get(ctx, other); err != nil {
+				t.Errorf("logging out ended another session: %v", err)
+			}
+
+			if _, _, err := m.Create(ctx, oauth.Tokens{AccessToken: "bob-token"}, oauth.Claims{Subject: "bob"}); err != nil {
+				t.Fatal(err)
+			}
+			n, err := m.RevokeSubject(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Errorf("expected to revoke 1 session, revoked %d", n)
+			}
+			if _, err := m.Get(ctx, other); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected a revoked session to be gone, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManagerTimeouts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	m := &Manager{Store: store, IdleTimeout: time.Minute, AbsoluteTimeout: time.Hour}
+
+	idle, sess, err := m.Create(ctx, oauth.Tokens{AccessToken: "token"}, oauth.Claims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.LastSeenAt = sess.LastSeenAt.Add(-2 * time.Minute)
+	store.Put(ctx, sess)
+	if _, err := m.Get(ctx, idle); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected an idle session to expire, got %v", err)
+	}
+
+	absolute, sess, err := m.Create(ctx, oauth.Tokens{AccessToken: "token"}, oauth.Claims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := sess.ExpiresAt.Sub(sess.CreatedAt); d != time.Hour {
+		t.Errorf("expected the absolute timeout to bound the session, got %s", d)
+	}
+	sess.ExpiresAt = time.Now().Add(-time.Second)
+	store.sessions[sess.ID] = *sess
+	if _, err := m.Get(ctx, absolute); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected a session past its absolute timeout to expire, got %v", err)
+	}
+	if _, err := store.Get(ctx, sess.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an expired session to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS ui_sessions (
+	id TEXT PRIMARY KEY,
+	subject TEXT NOT NULL,
+	issuer TEXT NOT NULL,
+	token TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	last_seen_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	refresh_token TEXT NOT NULL DEFAULT '',
+	id_token TEXT NOT NULL DEFAULT '',
+	token_expires_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS ui_sessions_subject ON ui_sessions (subject)`
+
+// SQLStore keeps sessions in a Postgres ui_sessions table, which is created if it does not exist.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+func NewSQLStore(ctx context.Context, connection string) (*SQLStore, error) {
+	db, err := sql.Open("pgx", connection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLStore{DB: db}, nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (*Session, error) {
+	sess := &Session{}
+	var tokenExpires sql.NullTime
+	err := s.DB.QueryRowContext(ctx, `SELECT id, subject, issuer, token, created_at, last_seen_at, expires_at, refresh_token, id_token, token_expires_at FROM ui_sessions WHERE id = $1`, id).
+		Scan(&sess.ID, &sess.Subject, &sess.Issuer, &sess.Token, &sess.CreatedAt, &sess.LastSeenAt, &sess.ExpiresAt, &sess.RefreshToken, &sess.IDToken, &tokenExpires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tokenExpires.Valid {
+		sess.TokenExpiresAt = tokenExpires.Time
+	}
+	return sess, nil
+}
+
+// Put also drops expired sessions so the table does not grow without bound.
+func (s *SQLStore) Put(ctx context.Context, sess *Session) error {
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM ui_sessions WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	var tokenExpires sql.NullTime
+	if !sess.TokenExpiresAt.IsZero() {
+		tokenExpires = sql.NullTime{Time: sess.TokenExpiresAt, Valid: true}
+	}
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO ui_sessions (id, subject, issuer, token, created_at, last_seen_at, expires_at, refresh_token, id_token, token_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET token = $4, last_seen_at = $6, expires_at = $7, refresh_token = $8, id_token = $9, token_expires_at = $10`,
+		sess.ID, sess.Subject, sess.Issuer, sess.Token, sess.CreatedAt, sess.LastSeenAt, sess.ExpiresAt, sess.RefreshToken, sess.IDToken, tokenExpires)
+	return err
+}
+
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM ui_sessions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteSubject(ctx context.Context, subject string) (int, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM ui_sessions WHERE subject = $1 AND expires_at > now()`, subject)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Session sql store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.DB.PingContext(ctx); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
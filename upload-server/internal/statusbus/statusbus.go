@@ -0,0 +1,242 @@
+package statusbus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+)
+
+const UpdateEventType = "UploadStatusUpdate"
+
+// BufferSize is how many updates a subscriber can fall behind before it is dropped.
+var BufferSize = 64
+
+// RemoteProgressInterval is how often the progress of an upload is shared with the other instances.  tusd reports
+// progress about once a second, which is only worth sending to the subscribers on this instance.
+var RemoteProgressInterval = 10 * time.Second
+
+// sweepSize is how many uploads are tracked before the ones whose progress was last shared more than
+// RemoteProgressInterval ago are cleared out.
+const sweepSize = 10000
+
+// Default is the bus the server publishes upload status updates on.
+var Default *Bus
+
+// Update is a change in the status of an upload, or in the status of its delivery to one target.
+type Update struct {
+	event.Event
+	UploadID string `json:"upload_id"`
+	// Origin is the instance that published the update.
+	Origin string `json:"origin"`
+	Stage  string `json:"stage"`
+	Status string `json:"status"`
+	Offset int64  `json:"offset,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// Target and Location are only set on delivery updates.
+	Target   string                `json:"target,omitempty"`
+	Location string                `json:"location,omitempty"`
+	Issues   []reports.ReportIssue `json:"issues,omitempty"`
+	Time     time.Time             `json:"time"`
+}
+
+func (u *Update) RetryCount() int {
+	return u.Event.RetryCount
+}
+
+func (u *Update) IncrementRetryCount() {
+	u.Event.RetryCount++
+}
+
+func (u *Update) Identifier() string {
+	return u.UploadID + u.Stage + u.Target
+}
+
+func (u *Update) GetUploadID() string {
+	return u.UploadID
+}
+
+func (u *Update) Type() string {
+	return u.Event.Type
+}
+
+func (u *Update) SetIdentifier(id string) {
+	u.Event.ID = id
+}
+
+func (u *Update) SetType(t string) {
+	u.Event.Type = t
+}
+
+// FromReport returns the update for reports of upload progress, completion, termination, duplicates, malware
+// scans, and delivery.  Other reports are not status transitions, and ok is false for them.
+func FromReport(r *reports.Report) (u *Update, ok bool) {
+	u = &Update{
+		Event:    event.Event{Type: UpdateEventType},
+		UploadID: r.UploadID,
+		Stage:    r.StageInfo.Action,
+		Status:   r.StageInfo.Status,
+		Issues:   r.StageInfo.Issues,
+		Time:     time.Now().UTC(),
+	}
+	if t, err := time.Parse(time.RFC3339Nano, r.StageInfo.EndProcessTime); err == nil {
+		u.Time = t
+	}
+	switch r.StageInfo.Action {
+	case reports.StageUploadStarted, reports.StageUploadCompleted, reports.StageUploadTerminated, reports.StageDuplicateCheck, reports.StageMalwareScan:
+	case reports.StageUploadStatus:
+		if c, ok := r.Content.(reports.UploadStatusContent); ok {
+			u.Offset = c.Offset
+			u.Size = c.Size
+		}
+	case reports.StageFileCopy:
+		if c, ok := r.Content.(reports.FileCopyContent); ok {
+			u.Target = c.DestinationName
+			u.Location = c.FileDestinationBlobUrl
+		}
+	default:
+		return nil, false
+	}
+	return u, r.UploadID != ""
+}
+
+type subscriber struct {
+	ch chan *Update
+}
+
+// Bus hands upload status updates to the subscribers of the upload on this instance, and shares them with the
+// other instances through Remote.
+type Bus struct {
+	// Origin identifies this instance, so it can skip its own updates when they come back from the other
+	// instances.
+	Origin string
+	Remote event.Publishers[*Update]
+
+	mu          sync.Mutex
+	subscribers map[string]map[*subscriber]struct{}
+	// shared is when the progress of each upload was last shared with the other instances.
+	shared map[string]time.Time
+}
+
+func New(remote ...event.Publisher[*Update]) *Bus {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &Bus{
+		Origin:      hex.EncodeToString(b),
+		Remote:      remote,
+		subscribers: map[string]map[*subscriber]struct{}{},
+		shared:      map[string]time.Time{},
+	}
+}
+
+// Subscribe returns the updates of the upload until cancel is called.  The channel is closed when the
+// subscriber falls more than BufferSize updates behind, and the subscriber should start over from the upload's
+// current status.
+func (b *Bus) Subscribe(uploadID string) (updates <-chan *Update, cancel func()) {
+	s := &subscriber{ch: make(chan *Update, BufferSize)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[uploadID] == nil {
+		b.subscribers[uploadID] = map[*subscriber]struct{}{}
+	}
+	b.subscribers[uploadID][s] = struct{}{}
+	return s.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(uploadID, s)
+	}
+}
+
+func (b *Bus) remove(uploadID string, s *subscriber) {
+	subs, ok := b.subscribers[uploadID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(b.subscribers, uploadID)
+	}
+	close(s.ch)
+}
+
+// Subscribers returns how many subscribers the upload has on this instance.
+func (b *Bus) Subscribers(uploadID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers[uploadID])
+}
+
+func (b *Bus) deliver(u *Update) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers[u.UploadID] {
+		select {
+		case s.ch <- u:
+		default:
+			b.remove(u.UploadID, s)
+		}
+	}
+}
+
+// share reports whether the update should be sent to the other instances.  Every update but progress happens
+// once per upload or delivery target.  Progress is shared at most once per RemoteProgressInterval, and always once
+// the upload has been fully received.
+func (b *Bus) share(u *Update) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch u.Stage {
+	case reports.StageUploadStatus:
+	case reports.StageUploadCompleted, reports.StageUploadTerminated:
+		delete(b.shared, u.UploadID)
+		return true
+	default:
+		return true
+	}
+	now := time.Now()
+	if len(b.shared) > sweepSize {
+		for id, t := range b.shared {
+			if now.Sub(t) >= RemoteProgressInterval {
+				delete(b.shared, id)
+			}
+		}
+	}
+	if last, ok := b.shared[u.UploadID]; ok && now.Sub(last) < RemoteProgressInterval && u.Offset < u.Size {
+		return false
+	}
+	b.shared[u.UploadID] = now
+	return true
+}
+
+// Publish hands the update for the report to this instance's subscribers and shares it with the other
+// instances.  It lets the bus be registered as a reporter.
+func (b *Bus) Publish(ctx context.Context, r *reports.Report) error {
+	u, ok := FromReport(r)
+	if !ok {
+		return nil
+	}
+	u.Origin = b.Origin
+	b.deliver(u)
+	if !b.share(u) {
+		return nil
+	}
+	// the remote publishers retry and log on their own, and failing here would deliver the update locally again
+	b.Remote.Publish(ctx, u)
+	return nil
+}
+
+// Receive hands an update shared by another instance to this instance's subscribers.
+func (b *Bus) Receive(_ context.Context, u *Update) error {
+	if u.Origin == b.Origin {
+		return nil
+	}
+	b.deliver(u)
+	return nil
+}
@@ -0,0 +1,125 @@
+package statusbus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+)
+
+type recorder struct {
+	updates []*Update
+}
+
+func (r *recorder) Publish(_ context.Context, u *Update) error {
+	r.updates = append(r.updates, u)
+	return nil
+}
+
+func statusReport(id string, offset int64) *reports.Report {
+	return reports.NewBuilder[reports.UploadStatusContent]("1.0.0", reports.StageUploadStatus, id, reports.DispositionTypeReplace).
+		SetContent(reports.UploadStatusContent{Offset: offset, Size: 11}).
+		Build()
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	remote := &recorder{}
+	bus := New(remote)
+	updates, cancel := bus.Subscribe("upload1")
+	other, cancelOther := bus.Subscribe("upload2")
+	defer cancelOther()
+
+	if err := bus.Publish(ctx, statusReport("upload1", 5)); err != nil {
+		t.Fatal(err)
+	}
+	delivery := reports.NewBuilder[reports.FileCopyContent]("1.0.0", reports.StageFileCopy, "upload1", reports.DispositionTypeAdd).
+		SetStatus(reports.StatusFailed).
+		SetContent(reports.FileCopyContent{DestinationName: "edav"}).
+		Build()
+	if err := bus.Publish(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+	verify := reports.NewBuilder[reports.MetaDataVerifyContent]("1.0.0", reports.StageMetadataVerify, "upload1", reports.DispositionTypeAdd).Build()
+	if err := bus.Publish(ctx, verify); err != nil {
+		t.Fatal(err)
+	}
+
+	u := <-updates
+	if u.Stage != reports.StageUploadStatus || u.Offset != 5 || u.Size != 11 || u.Origin != bus.Origin {
+		t.Errorf("unexpected progress update %+v", u)
+	}
+	u = <-updates
+	if u.Stage != reports.StageFileCopy || u.Target != "edav" || u.Status != reports.StatusFailed {
+		t.Errorf("unexpected delivery update %+v", u)
+	}
+	select {
+	case u := <-updates:
+		t.Errorf("expected only status transitions, got %+v", u)
+	case u := <-other:
+		t.Errorf("expected updates only for the subscribed upload, got %+v", u)
+	default:
+	}
+	if len(remote.updates) != 2 {
+		t.Errorf("expected updates to be shared with other instances, got %+v", remote.updates)
+	}
+
+	if err := bus.Receive(ctx, remote.updates[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Receive(ctx, &Update{UploadID: "upload1", Origin: "other", Stage: reports.StageUploadCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	if u := <-updates; u.Origin != "other" {
+		t.Errorf("expected this instance's own update to be skipped, got %+v", u)
+	}
+
+	cancel()
+	if _, ok := <-updates; ok {
+		t.Error("expected updates to end when the subscription is cancelled")
+	}
+	if n := bus.Subscribers("upload1"); n != 0 {
+		t.Errorf("expected no subscribers, got %d", n)
+	}
+}
+
+func TestBus_SlowSubscriber(t *testing.T) {
+	bus := New()
+	updates, cancel := bus.Subscribe("upload1")
+	defer cancel()
+	for i := range BufferSize + 1 {
+		bus.Publish(context.Background(), statusReport("upload1", int64(i)))
+	}
+	n := 0
+	for range updates {
+		n++
+	}
+	if n != BufferSize {
+		t.Errorf("expected a subscriber that fell behind to be dropped after %d updates, got %d", BufferSize, n)
+	}
+}
+
+func TestBus_RemoteProgress(t *testing.T) {
+	ctx := context.Background()
+	remote := &recorder{}
+	bus := New(remote)
+	updates, cancel := bus.Subscribe("upload1")
+	defer cancel()
+
+	for _, offset := range []int64{1, 5, 9, 11} {
+		bus.Publish(ctx, statusReport("upload1", offset))
+	}
+	completed := reports.NewBuilder[reports.UploadLifecycleContent]("1.0.0", reports.StageUploadCompleted, "upload1", reports.DispositionTypeAdd).Build()
+	bus.Publish(ctx, completed)
+
+	if len(updates) != 5 {
+		t.Errorf("expected every update to reach this instance's subscribers, got %d", len(updates))
+	}
+	var offsets []int64
+	for _, u := range remote.updates {
+		offsets = append(offsets, u.Offset)
+	}
+	if len(remote.updates) != 3 || offsets[0] != 1 || offsets[1] != 11 || remote.updates[2].Stage != reports.StageUploadCompleted {
+		t.Errorf("expected throttled progress, the received upload and its completion to be shared, got offsets %v", offsets)
+	}
+}
@@ -0,0 +1,74 @@
+package storeaz
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/concat"
+	"github.com/tus/tusd/v2/pkg/azurestore"
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+// AzureStore adds the concatenation extension to the tus azurestore, which does not support it.  Uploads can be
+// listed when Container, the client of the store's container, is set.
+type AzureStore struct {
+	Store     *azurestore.AzureStore
+	Container *container.Client
+}
+
+type concatableUpload struct {
+	handler.Upload
+}
+
+func (u *concatableUpload) ConcatUploads(ctx context.Context, partials []handler.Upload) error {
+	return concat.ByCopy(ctx, u.Upload, partials)
+}
+
+func (s *AzureStore) NewUpload(ctx context.Context, info handler.FileInfo) (handler.Upload, error) {
+	return s.Store.NewUpload(ctx, info)
+}
+
+func (s *AzureStore) GetUpload(ctx context.Context, id string) (handler.Upload, error) {
+	return s.Store.GetUpload(ctx, id)
+}
+
+func (s *AzureStore) AsConcatableUpload(upload handler.Upload) handler.ConcatableUpload {
+	return &concatableUpload{upload}
+}
+
+// ListUploads lists the ids of the uploads from their info blobs.
+func (s *AzureStore) ListUploads(ctx context.Context) ([]string, error) {
+	if s.Container == nil {
+		return nil, errors.New("azure store has no container client to list uploads with")
+	}
+	prefix := s.Store.ObjectPrefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ids := []string{}
+	pages := s.Container.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix})
+	for pages.More() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range page.Segment.BlobItems {
+			if b.Name == nil {
+				continue
+			}
+			id, ok := strings.CutSuffix(strings.TrimPrefix(*b.Name, prefix), azurestore.InfoBlobSuffix)
+			if ok && !strings.Contains(id, "/") {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (s *AzureStore) UseIn(composer *handler.StoreComposer) {
+	s.Store.UseIn(composer)
+	composer.UseCore(s)
+	composer.UseConcater(s)
+}
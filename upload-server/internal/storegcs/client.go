@@ -0,0 +1,25 @@
+package storegcs
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// NewClient creates a GCS client.  Credentials come from the credentials file when one is given and from the
+// application default credentials otherwise.  A custom endpoint without a credentials file, such as a local
+// fake GCS server, is used without authentication.
+func NewClient(ctx context.Context, endpoint string, credentialsFile string) (*storage.Client, error) {
+	var opts []option.ClientOption
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	switch {
+	case credentialsFile != "":
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	case endpoint != "":
+		opts = append(opts, option.WithoutAuthentication())
+	}
+	return storage.NewClient(ctx, opts...)
+}
@@ -0,0 +1,34 @@
+package storegcs
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+type GCSHealthCheck struct {
+	Client     *storage.Client
+	BucketName string
+}
+
+func (c *GCSHealthCheck) Health(ctx context.Context) models.ServiceHealthResp {
+	var shr models.ServiceHealthResp
+	shr.Service = models.TUS_STORAGE_HEALTH_PREFIX + " GCS Bucket " + c.BucketName
+
+	if c.Client == nil {
+		shr.Status = models.STATUS_DOWN
+		shr.HealthIssue = "GCS client not available"
+		return shr
+	}
+
+	if _, err := c.Client.Bucket(c.BucketName).Attrs(ctx); err != nil {
+		shr.Status = models.STATUS_DOWN
+		shr.HealthIssue = err.Error()
+		return shr
+	}
+
+	shr.Status = models.STATUS_UP
+	shr.HealthIssue = models.HEALTH_ISSUE_NONE
+	return shr
+}
@@ -0,0 +1,143 @@
+package storegcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/concat"
+	"github.com/tus/tusd/v2/pkg/gcsstore"
+	"github.com/tus/tusd/v2/pkg/handler"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStore wraps the tus gcsstore, which only supports the core and termination extensions, so that uploads
+// can also be created with a deferred length or by concatenation, and so that terminating an upload also removes
+// its info object.
+type GCSStore struct {
+	Store gcsstore.GCSStore
+}
+
+type GCSStoreUpload struct {
+	handler.Upload
+	id    string
+	store *GCSStore
+}
+
+func (s *GCSStore) NewUpload(ctx context.Context, info handler.FileInfo) (handler.Upload, error) {
+	u, err := s.Store.NewUpload(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	if info.ID == "" {
+		i, err := u.GetInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		info.ID = i.ID
+	}
+	return &GCSStoreUpload{Upload: u, id: info.ID, store: s}, nil
+}
+
+func (s *GCSStore) GetUpload(ctx context.Context, id string) (handler.Upload, error) {
+	u, err := s.Store.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSStoreUpload{Upload: u, id: id, store: s}, nil
+}
+
+func (s *GCSStore) AsTerminatableUpload(upload handler.Upload) handler.TerminatableUpload {
+	return upload.(*GCSStoreUpload)
+}
+
+func (s *GCSStore) AsLengthDeclarableUpload(upload handler.Upload) handler.LengthDeclarableUpload {
+	return upload.(*GCSStoreUpload)
+}
+
+func (s *GCSStore) AsConcatableUpload(upload handler.Upload) handler.ConcatableUpload {
+	return upload.(*GCSStoreUpload)
+}
+
+func (s *GCSStore) UseIn(composer *handler.StoreComposer) {
+	composer.UseCore(s)
+	composer.UseTerminater(s)
+	composer.UseConcater(s)
+	composer.UseLengthDeferrer(s)
+}
+
+func (s *GCSStore) infoKey(id string) string {
+	prefix := s.Store.ObjectPrefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + id + ".info"
+}
+
+// ListUploads lists the ids of the uploads from their info objects.
+func (s *GCSStore) ListUploads(ctx context.Context) ([]string, error) {
+	service, ok := s.Store.Service.(*GCSService)
+	if !ok {
+		return nil, errors.New("gcs service can not list uploads")
+	}
+	prefix := strings.TrimSuffix(s.infoKey(""), ".info")
+	ids := []string{}
+	objects := service.Client.Bucket(s.Store.Bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		id, ok := strings.CutSuffix(strings.TrimPrefix(attrs.Name, prefix), ".info")
+		if ok && !strings.Contains(id, "/") {
+			ids = append(ids, id)
+		}
+	}
+}
+
+// DeclareLength sets the size of an upload created with a deferred length by rewriting its info object.
+func (u *GCSStoreUpload) DeclareLength(ctx context.Context, length int64) error {
+	info, err := u.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	info.Size = length
+	info.SizeIsDeferred = false
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	_, err = u.store.Store.Service.WriteObject(ctx, gcsstore.GCSObjectParams{
+		Bucket: u.store.Store.Bucket,
+		ID:     u.store.infoKey(u.id),
+	}, bytes.NewReader(b))
+	return err
+}
+
+// Terminate deletes the upload's chunks and object, which is all the gcsstore removes, and then its info object.
+func (u *GCSStoreUpload) Terminate(ctx context.Context) error {
+	if err := u.store.Store.AsTerminatableUpload(u.Upload).Terminate(ctx); err != nil {
+		return err
+	}
+	err := u.store.Store.Service.DeleteObject(ctx, gcsstore.GCSObjectParams{
+		Bucket: u.store.Store.Bucket,
+		ID:     u.store.infoKey(u.id),
+	})
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+// ConcatUploads copies the partial uploads into chunks of the final upload and finishes it, which composes the
+// chunks and sets the final object's metadata.
+func (u *GCSStoreUpload) ConcatUploads(ctx context.Context, partials []handler.Upload) error {
+	return concat.ByCopy(ctx, u, partials)
+}
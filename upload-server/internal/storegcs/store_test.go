@@ -0,0 +1,233 @@
+package storegcs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/loaders"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/storegcs"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/gcsinspector"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/info"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/tus/tusd/v2/pkg/gcsstore"
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+const bucket = "dex-uploads"
+
+func newServer(t *testing.T) *fakestorage.Server {
+	t.Helper()
+	// The storage client reads objects through the XML API on the endpoint's host, so the fake server has to
+	// be told which host it is served on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	srv, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		Scheme:     "http",
+		Host:       "127.0.0.1",
+		Port:       uint16(port),
+		PublicHost: fmt.Sprintf("127.0.0.1:%d", port),
+		InitialObjects: []fakestorage.Object{
+			{ObjectAttrs: fakestorage.ObjectAttrs{BucketName: bucket, Name: "configs/dextesting_testevent1.json"}, Content: []byte(`{"metadata_config":{}}`)},
+			{ObjectAttrs: fakestorage.ObjectAttrs{BucketName: bucket, Name: "configs/v1/nested.json"}, Content: []byte(`{}`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "partner-analytics"})
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+func TestGCSStore(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	client, err := storegcs.NewClient(ctx, srv.URL()+"/storage/v1/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp := (&storegcs.GCSHealthCheck{Client: client, BucketName: bucket}).Health(ctx); rsp.Status != models.STATUS_UP {
+		t.Errorf("expected store to be healthy, got %+v", rsp)
+	}
+	if rsp := (&storegcs.GCSHealthCheck{Client: client, BucketName: "missing"}).Health(ctx); rsp.Status != models.STATUS_DOWN {
+		t.Errorf("expected missing bucket to be unhealthy, got %+v", rsp)
+	}
+
+	store := gcsstore.New(bucket, storegcs.NewService(client))
+	store.ObjectPrefix = "tus-prefix"
+	s := &storegcs.GCSStore{Store: store}
+	composer := handler.NewStoreComposer()
+	s.UseIn(composer)
+	if !composer.UsesLengthDeferrer || !composer.UsesTerminater {
+		t.Fatal("expected the store to support deferred lengths and termination")
+	}
+
+	manifest := map[string]string{"data_stream_id": "dextesting", "data_stream_route": "testevent1", "filename": "test.txt"}
+	u, err := s.NewUpload(ctx, handler.FileInfo{ID: "upload1", SizeIsDeferred: true, MetaData: manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, chunk := range []string{"hello ", "world"} {
+		if _, err := u.WriteChunk(ctx, int64(i), strings.NewReader(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inspector := gcsinspector.NewGCSUploadInspector(client, bucket, "tus-prefix")
+	if _, err := inspector.InspectUploadedFile(ctx, "upload1"); !errors.Is(err, info.ErrNotFound) {
+		t.Errorf("expected unfinished upload to not be found, got %v", err)
+	}
+
+	u, err = s.GetUpload(ctx, "upload1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AsLengthDeclarableUpload(u).DeclareLength(ctx, 11); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := u.GetInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size != 11 || fi.SizeIsDeferred || fi.Offset != 11 {
+		t.Errorf("expected declared length 11 at offset 11, got %+v", fi)
+	}
+	if err := u.FinishUpload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := inspector.InspectInfoFile(ctx, "upload1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["data_stream_id"] != "dextesting" {
+		t.Errorf("expected info file metadata, got %+v", m)
+	}
+	f, err := inspector.InspectUploadedFile(ctx, "upload1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f["size_bytes"] != int64(11) {
+		t.Errorf("expected uploaded file size, got %+v", f)
+	}
+	if _, err := inspector.InspectInfoFile(ctx, "missing"); !errors.Is(err, info.ErrNotFound) {
+		t.Errorf("expected missing info file to not be found, got %v", err)
+	}
+
+	src := &delivery.GCSSource{FromClient: client, BucketName: bucket, Prefix: "tus-prefix"}
+	dst := &delivery.GCSDestination{Name: "partner", BucketName: "partner-analytics", Endpoint: srv.URL() + "/storage/v1/"}
+	if rsp := dst.Health(ctx); rsp.Status != models.STATUS_UP {
+		t.Errorf("expected destination to be healthy, got %+v", rsp)
+	}
+	if _, err := delivery.Deliver(ctx, "upload1", "dextesting/test.txt", src, dst); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := srv.GetObject("partner-analytics", "dextesting/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(obj.Content) != "hello world" || obj.Metadata["filename"] != "test.txt" {
+		t.Errorf("expected delivered file with metadata, got %q %+v", obj.Content, obj.Metadata)
+	}
+
+	if err := s.AsTerminatableUpload(u).Terminate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.GetInfo(ctx); !errors.Is(err, handler.ErrNotFound) {
+		t.Errorf("expected terminated upload to be gone, got %v", err)
+	}
+}
+
+func TestGCSStore_Concat(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	client, err := storegcs.NewClient(ctx, srv.URL()+"/storage/v1/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := gcsstore.New(bucket, storegcs.NewService(client))
+	store.ObjectPrefix = "tus-prefix"
+	s := &storegcs.GCSStore{Store: store}
+
+	var partials []handler.Upload
+	for i, chunk := range []string{"hello ", "world"} {
+		u, err := s.NewUpload(ctx, handler.FileInfo{ID: fmt.Sprintf("partial%d", i), Size: int64(len(chunk)), IsPartial: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.WriteChunk(ctx, 0, strings.NewReader(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		if err := u.FinishUpload(ctx); err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, u)
+	}
+
+	final, err := s.NewUpload(ctx, handler.FileInfo{ID: "final", Size: 11, IsFinal: true, MetaData: handler.MetaData{"filename": "test.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AsConcatableUpload(final).ConcatUploads(ctx, partials); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := srv.GetObject(bucket, "tus-prefix/final")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(obj.Content) != "hello world" || obj.Metadata["filename"] != "test.txt" {
+		t.Errorf("expected concatenated object with metadata, got %q %+v", obj.Content, obj.Metadata)
+	}
+
+	ids, err := s.ListUploads(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	if want := []string{"final", "partial0", "partial1"}; !slices.Equal(ids, want) {
+		t.Errorf("expected the uploads %v to be listed, got %v", want, ids)
+	}
+}
+
+func TestGCSConfigLoader(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	client, err := storegcs.NewClient(ctx, srv.URL()+"/storage/v1/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &loaders.GCSConfigLoader{Client: client, BucketName: bucket, Folder: "configs"}
+
+	paths, err := l.ListConfigs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "dextesting_testevent1.json" {
+		t.Errorf("expected only top level configs, got %v", paths)
+	}
+
+	b, err := l.LoadConfig(ctx, "dextesting_testevent1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"metadata_config":{}}` {
+		t.Errorf("unexpected config %s", b)
+	}
+	if _, err := l.LoadConfig(ctx, "missing.json"); !errors.Is(err, validation.ErrNotFound) {
+		t.Errorf("expected missing config to not be found, got %v", err)
+	}
+}
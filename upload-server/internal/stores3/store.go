@@ -70,6 +70,33 @@ func (s *S3Store) GetUpload(ctx context.Context, id string) (handler.Upload, err
 	}, err
 }
 
+// ListUploads lists the ids of the uploads from their info objects.
+func (s *S3Store) ListUploads(ctx context.Context) ([]string, error) {
+	c, ok := s.Store.Service.(*s3.Client)
+	if !ok {
+		return nil, fmt.Errorf("Bad configuration, non-standard s3 client")
+	}
+	prefix := aws.ToString(s.metadataKeyWithPrefix(""))
+	ids := []string{}
+	pages := s3.NewListObjectsV2Paginator(c, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Store.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range page.Contents {
+			id, ok := strings.CutSuffix(strings.TrimPrefix(aws.ToString(o.Key), prefix), ".info")
+			if ok && !strings.Contains(id, "/") {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
 func (s *S3Store) AsTerminatableUpload(upload handler.Upload) handler.TerminatableUpload {
 	u := upload.(*S3StoreUpload)
 	return s.Store.AsTerminatableUpload(u.Upload)
@@ -82,7 +109,23 @@ func (s *S3Store) AsLengthDeclarableUpload(upload handler.Upload) handler.Length
 
 func (s *S3Store) AsConcatableUpload(upload handler.Upload) handler.ConcatableUpload {
 	u := upload.(*S3StoreUpload)
-	return s.Store.AsConcatableUpload(u.Upload)
+	return &s3ConcatableUpload{s.Store.AsConcatableUpload(u.Upload)}
+}
+
+// s3ConcatableUpload unwraps the partial uploads, which the s3store expects to be its own.
+type s3ConcatableUpload struct {
+	handler.ConcatableUpload
+}
+
+func (u *s3ConcatableUpload) ConcatUploads(ctx context.Context, partials []handler.Upload) error {
+	unwrapped := make([]handler.Upload, len(partials))
+	for i, p := range partials {
+		unwrapped[i] = p
+		if su, ok := p.(*S3StoreUpload); ok {
+			unwrapped[i] = su.Upload
+		}
+	}
+	return u.ConcatableUpload.ConcatUploads(ctx, unwrapped)
 }
 
 func (s *S3Store) UseIn(composer *handler.StoreComposer) {
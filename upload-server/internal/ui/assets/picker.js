@@ -0,0 +1,26 @@
+// Limits the data stream route options to the routes of the selected data stream.
+(function () {
+  const streamSelect = document.getElementById("data_stream_id");
+  const routeSelect = document.getElementById("data_stream_route");
+  if (!streamSelect || !routeSelect) {
+    return;
+  }
+
+  function filterRoutes() {
+    let firstVisible = null;
+    for (const option of routeSelect.options) {
+      const visible = option.dataset.streamId === streamSelect.value;
+      option.hidden = !visible;
+      option.disabled = !visible;
+      if (visible && firstVisible === null) {
+        firstVisible = option;
+      }
+    }
+    if (routeSelect.selectedOptions.length === 0 || routeSelect.selectedOptions[0].disabled) {
+      routeSelect.value = firstVisible ? firstVisible.value : "";
+    }
+  }
+
+  streamSelect.addEventListener("change", filterRoutes);
+  filterRoutes();
+})();
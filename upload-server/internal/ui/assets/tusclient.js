@@ -6,6 +6,7 @@ let upload = null;
 let previousUpload = null;
 let uploadIsRunning = false;
 let file = null;
+let statusEventsOpen = false;
 
 const fileInput = document.querySelector("input[type=file]");
 const pauseButton = document.querySelector("#pause-upload-button");
@@ -19,10 +20,12 @@ const progressBar = progressContainer.querySelector(".bar");
 const UPLOAD_INITIATED = "Initiated";
 const UPLOAD_IN_PROGRESS = "In Progress";
 const UPLOAD_COMPLETE = "Complete";
+const UPLOAD_TERMINATED = "Terminated";
 
 const UPLOAD_STATUS_LABEL_INITIALIZED = " Upload Initialized At: ";
 const UPLOAD_STATUS_LABEL_IN_PROGRESS = " Last Chunk Received At: ";
 const UPLOAD_STATUS_LABEL_COMPLETE = " Upload Completed At: ";
+const UPLOAD_STATUS_LABEL_TERMINATED = " Upload Cancelled At: ";
 const UPLOAD_STATUS_LABEL_DEFAULT = " Uploaded At: ";
 
 // ------------------------------------------
@@ -162,6 +165,38 @@ function _refreshPage() {
   }, 1000);
 }
 
+// Listens for the upload's status updates from the server. Progress of an
+// upload sent from another page is shown as it arrives, and the page is
+// refreshed for the latest upload info when the upload completes or one
+// of its deliveries finishes.
+function _listenForStatusUpdates() {
+  if (!window.EventSource) {
+    return;
+  }
+
+  const statusEvents = new EventSource(statusEventsUrl, {
+    withCredentials: true,
+  });
+  statusEvents.onopen = () => {
+    statusEventsOpen = true;
+  };
+  statusEvents.onerror = () => {
+    // the browser reconnects on its own
+    statusEventsOpen = false;
+  };
+  statusEvents.addEventListener("upload-status", () => {
+    // the tus client already shows the progress of uploads from this page
+    if (uploadIsRunning) {
+      return;
+    }
+    _updateUploadStatusInProgress();
+    _updateLastChunkReceived();
+  });
+  statusEvents.addEventListener("upload-completed", () => _refreshPage());
+  statusEvents.addEventListener("upload-terminated", () => _refreshPage());
+  statusEvents.addEventListener("blob-file-copy", () => _refreshPage());
+}
+
 // Triggered by a file being selected. Gets the file from
 // the file input. Gets the other values from the form, if
 // this is a new upload, or from the previous upload metadata,
@@ -335,8 +370,11 @@ async function uploadFile(file, { chunkSize, parallelUploads }) {
 
       fileInput.value = "";
 
-      // refresh the page for the complete upload info
-      _refreshPage();
+      // the status updates refresh the page for the complete upload info,
+      // unless they could not be received
+      if (!statusEventsOpen) {
+        _refreshPage();
+      }
     },
   };
 
@@ -424,6 +462,9 @@ async function findResumableUpload() {
     case UPLOAD_COMPLETE:
       _showReadOnlyFileInfo(UPLOAD_STATUS_LABEL_COMPLETE);
       break;
+    case UPLOAD_TERMINATED:
+      _showReadOnlyFileInfo(UPLOAD_STATUS_LABEL_TERMINATED);
+      break;
     default:
       console.error(`${uploadStatus} is an invalid status`);
       _showReadOnlyFileInfo(UPLOAD_STATUS_LABEL_DEFAULT);
@@ -440,4 +481,6 @@ async function findResumableUpload() {
     pauseButton.addEventListener("click", () => pauseUpload());
     resumeButton.addEventListener("click", () => resumeUpload());
   }
+
+  _listenForStatusUpdates();
 })();
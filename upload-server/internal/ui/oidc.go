@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/middleware"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+)
+
+// oauthLogin starts the authorization code flow by sending the browser to the provider.  The state, nonce, and
+// PKCE verifier are kept in the session cookie until the provider redirects back.
+func oauthLogin(client *oauth.Client) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		req, err := oauth.NewLoginRequest()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		authURL, err := client.AuthCodeURL(r.Context(), req)
+		if err != nil {
+			slog.Error("failed to reach oidc provider", "error", err)
+			http.Error(rw, "sign in is unavailable", http.StatusBadGateway)
+			return
+		}
+
+		us, err := middleware.GetUserSession(r)
+		if err != nil {
+			slog.Warn("replacing unreadable user session", "error", err)
+		}
+		if err := us.SetLoginRequest(r, rw, req); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(rw, r, authURL, http.StatusFound)
+	}
+}
+
+// oauthCodeCallback completes the authorization code flow and starts a session for the user.
+func oauthCodeCallback(authMiddleware *middleware.AuthMiddleware) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		failed := func(msg string, args ...any) {
+			slog.Error(msg, args...)
+			http.Redirect(rw, r, "/login?auth_failed=true", http.StatusSeeOther)
+		}
+		if e := r.FormValue("error"); e != "" {
+			failed("failed login attempt", "error", e, "description", r.FormValue("error_description"))
+			return
+		}
+
+		us, err := middleware.GetUserSession(r)
+		if err != nil {
+			failed("failed login attempt", "error", err)
+			return
+		}
+		req, ok := us.LoginRequest()
+		if !ok {
+			failed("failed login attempt, no login in progress")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(req.State), []byte(r.FormValue("state"))) != 1 {
+			failed("failed login attempt, state does not match")
+			return
+		}
+
+		tokens, err := authMiddleware.Client().Exchange(r.Context(), r.FormValue("code"), req)
+		if err != nil {
+			failed("failed login attempt", "error", err)
+			return
+		}
+		claims, err := authMiddleware.Validator().ValidateJWT(r.Context(), tokens.Bearer())
+		if err != nil {
+			failed("failed login attempt", "error", err)
+			return
+		}
+
+		if err := us.SetTokens(r, rw, tokens, claims); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		redirect := us.Data().Redirect
+		if !isValidRedirectURL(redirect) {
+			redirect = "/"
+		}
+		http.Redirect(rw, r, redirect, http.StatusFound)
+	}
+}
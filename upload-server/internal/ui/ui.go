@@ -4,13 +4,13 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +18,7 @@ import (
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/middleware"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/ui/components"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/info"
 
@@ -101,10 +102,13 @@ type LoginTemplateData struct {
 	AuthFailed bool
 	Redirect   string
 	CsrfToken  string
+	CodeFlow   bool
 }
 
 type IndexTemplateData struct {
-	Navbar components.Navbar
+	Navbar        components.Navbar
+	DataStreams   []metadata.DataStreamConfig
+	DataStreamIDs []string
 }
 
 type ManifestTemplateData struct {
@@ -120,19 +124,21 @@ type ManifestTemplateData struct {
 type UploadTemplateData struct {
 	UploadEndpoint string
 	UploadUrl      string
-	UploadStatus   string
-	Info           info.InfoResponse
-	Navbar         components.Navbar
-	NewUploadBtn   components.LinkBtn
+	// StatusEventsUrl streams the upload's status updates to the page.
+	StatusEventsUrl string
+	UploadStatus    string
+	Info            info.InfoResponse
+	Navbar          components.Navbar
+	NewUploadBtn    components.LinkBtn
 }
 
 var StaticHandler = http.FileServer(http.FS(content))
 
-func NewServer(port string, csrfConfig appconfig.CSRFConfig, externalUploadUrl string, externalInfoUrl string, internalUploadUrl string, authMiddleware *middleware.AuthMiddleware) *http.Server {
-	router := GetRouter(externalUploadUrl, externalInfoUrl, internalUploadUrl, authMiddleware)
+func NewServer(port string, csrfConfig appconfig.CSRFConfig, externalServerUrl string, externalUploadUrl string, externalInfoUrl string, internalUploadUrl string, authMiddleware *middleware.AuthMiddleware) *http.Server {
+	router := GetRouter(externalServerUrl, externalUploadUrl, externalInfoUrl, internalUploadUrl, authMiddleware)
 	secureRouter := csrf.Protect(
 		[]byte(csrfConfig.Token),
-		csrf.Secure(false), // TODO: make dynamic when supporting TLS
+		csrf.Secure(csrfConfig.Secure),
 		csrf.TrustedOrigins(strings.Split(csrfConfig.TrustedOrigins, ",")),
 	)(router)
 
@@ -145,7 +151,7 @@ func NewServer(port string, csrfConfig appconfig.CSRFConfig, externalUploadUrl s
 	return s
 }
 
-func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadUrl string, authMiddleware *middleware.AuthMiddleware) *mux.Router {
+func GetRouter(externalServerUrl string, externalUploadUrl string, internalInfoUrl string, internalUploadUrl string, authMiddleware *middleware.AuthMiddleware) *mux.Router {
 	router := mux.NewRouter()
 	protectedRouter := router.PathPrefix("/").Subrouter()
 	protectedRouter.Use(authMiddleware.VerifyUserSession)
@@ -159,6 +165,7 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 		err = loginTemplate.Execute(rw, &LoginTemplateData{
 			AuthFailed: authFailed,
 			CsrfToken:  csrf.Token(r),
+			CodeFlow:   authMiddleware.Client() != nil,
 		})
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -171,14 +178,39 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = sess.Delete(r, rw)
+		idToken := sess.Tokens().IDToken
+		if r.FormValue("everywhere") == "true" {
+			err = sess.DeleteEverywhere(r, rw)
+		} else {
+			err = sess.Delete(r, rw)
+		}
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if client := authMiddleware.Client(); client != nil {
+			// end the session at the provider too, otherwise signing in again skips the provider's login
+			logoutURL, err := client.LogoutURL(r.Context(), idToken)
+			if err != nil {
+				slog.Error("failed to get provider logout url", "error", err)
+			}
+			if logoutURL != "" {
+				http.Redirect(rw, r, logoutURL, http.StatusFound)
+				return
+			}
+		}
 		http.Redirect(rw, r, "/login", http.StatusFound)
 	})
+	if client := authMiddleware.Client(); client != nil {
+		router.HandleFunc("/oauth_login", oauthLogin(client)).Methods("GET")
+		router.HandleFunc("/oauth_callback", oauthCodeCallback(authMiddleware)).Methods("GET")
+	}
 	router.HandleFunc("/oauth_callback", func(rw http.ResponseWriter, r *http.Request) {
+		if authMiddleware.Client() != nil {
+			// pasted tokens are only accepted when the authorization code flow is not configured
+			http.Redirect(rw, r, "/login", http.StatusSeeOther)
+			return
+		}
 		token := r.FormValue("token")
 
 		if token == "" {
@@ -198,8 +230,7 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 		}
 
-		exp := time.Unix(claims.Expiry, 0).Sub(time.Now())
-		err = us.SetToken(r, rw, token, int(exp.Seconds()))
+		err = us.SetToken(r, rw, token, claims)
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 		}
@@ -220,7 +251,7 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 			DataStreamRoute: dataStreamRoute,
 		}
 
-		config, err := metadata.Cache.GetConfig(r.Context(), configId.Path())
+		config, err := metadata.Cache.GetConfigForManifest(r.Context(), configId.Path(), nil)
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusNotFound)
 			return
@@ -353,6 +384,11 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// the info endpoint enforces this as well, but the page must never show another sender's upload
+		if claims, ok := oauth.FromContext(r.Context()); ok && !claims.CanView(info.ManifestValues(fileInfo.Manifest)) {
+			http.Error(rw, "upload not visible to this user", http.StatusForbidden)
+			return
+		}
 
 		uploadDestinationUrl, err := url.JoinPath(externalUploadUrl, id)
 		if err != nil {
@@ -360,13 +396,20 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 			return
 		}
 
+		statusEventsUrl, err := url.JoinPath(externalServerUrl, "uploads", id, "events")
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		err = uploadTemplate.Execute(rw, &UploadTemplateData{
-			UploadEndpoint: externalUploadUrl,
-			UploadUrl:      uploadDestinationUrl,
-			Info:           fileInfo,
-			UploadStatus:   fileInfo.UploadStatus.Status,
-			Navbar:         components.NewNavbar(true, isLoggedIn(*r)),
-			NewUploadBtn:   components.LinkBtn{Href: "/", Text: "Upload New File"},
+			UploadEndpoint:  externalUploadUrl,
+			UploadUrl:       uploadDestinationUrl,
+			StatusEventsUrl: statusEventsUrl,
+			Info:            fileInfo,
+			UploadStatus:    fileInfo.UploadStatus.Status,
+			Navbar:          components.NewNavbar(true, isLoggedIn(*r)),
+			NewUploadBtn:    components.LinkBtn{Href: "/", Text: "Upload New File"},
 		})
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -374,8 +417,22 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 		}
 	})
 	protectedRouter.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
-		err := indexTemplate.Execute(rw, &IndexTemplateData{
-			Navbar: components.NewNavbar(false, isLoggedIn(*r)),
+		// Fall back to free text inputs when the data streams can't be listed.
+		streams, err := metadata.Cache.ListAuthorizedDataStreams(r.Context())
+		if err != nil {
+			slog.Warn("unable to list data streams", "error", err)
+		}
+		var ids []string
+		for _, s := range streams {
+			if !slices.Contains(ids, s.DataStreamID) {
+				ids = append(ids, s.DataStreamID)
+			}
+		}
+
+		err = indexTemplate.Execute(rw, &IndexTemplateData{
+			Navbar:        components.NewNavbar(false, isLoggedIn(*r)),
+			DataStreams:   streams,
+			DataStreamIDs: ids,
 		})
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -390,8 +447,8 @@ func GetRouter(externalUploadUrl string, internalInfoUrl string, internalUploadU
 
 var DefaultServer *http.Server
 
-func Start(uiPort string, csrfConfig appconfig.CSRFConfig, externalUploadURL string, internalInfoURL string, internalUploadUrl string, authMiddleware *middleware.AuthMiddleware) error {
-	DefaultServer = NewServer(uiPort, csrfConfig, externalUploadURL, internalInfoURL, internalUploadUrl, authMiddleware)
+func Start(uiPort string, csrfConfig appconfig.CSRFConfig, externalServerURL string, externalUploadURL string, internalInfoURL string, internalUploadUrl string, authMiddleware *middleware.AuthMiddleware) error {
+	DefaultServer = NewServer(uiPort, csrfConfig, externalServerURL, externalUploadURL, internalInfoURL, internalUploadUrl, authMiddleware)
 
 	return DefaultServer.ListenAndServe()
 }
@@ -416,11 +473,11 @@ func filterMetadataFields(config *validation.ManifestConfig) []validation.FieldC
 }
 
 func isLoggedIn(r http.Request) bool {
-	_, err := r.Cookie(middleware.UserSessionCookieName)
-	if err != nil && errors.Is(err, http.ErrNoCookie) {
+	us, err := middleware.GetUserSession(&r)
+	if err != nil {
 		return false
 	}
-	return true
+	return us.Data().Token != ""
 }
 
 func isValidRedirectURL(redirectURL string) bool {
@@ -69,6 +69,13 @@ func ReportUploadStarted(event *handler.HookEvent, resp hooks.HookResponse) (hoo
 	logger.Info("REPORT upload-started", "report", report)
 	reports.Publish(event.Context, report)
 
+	if event.Upload.IsFinal {
+		// final uploads are concatenated while they are created, and tusd notifies of both at once, so only the
+		// completed report carries their status
+		logger.Info("upload-started report complete")
+		return resp, nil
+	}
+
 	report = reports.NewBuilderWithManifest[reports.UploadStatusContent](
 		"1.0.0",
 		reports.StageUploadStatus,
@@ -140,3 +147,33 @@ func ReportUploadComplete(event *handler.HookEvent, resp hooks.HookResponse) (ho
 	logger.Info("upload-completed report complete")
 	return resp, nil
 }
+
+func ReportUploadTerminated(event *handler.HookEvent, resp hooks.HookResponse) (hooks.HookResponse, error) {
+	uploadId := event.Upload.ID
+	manifest := event.Upload.MetaData
+	logger := sloger.FromContext(event.Context)
+
+	logger.Info("starting upload-terminated report")
+
+	report := reports.NewBuilderWithManifest[reports.UploadTerminatedContent](
+		"1.0.0",
+		reports.StageUploadTerminated,
+		uploadId,
+		manifest,
+		reports.DispositionTypeAdd).SetContent(reports.UploadTerminatedContent{
+		ReportContent: reports.ReportContent{
+			ContentSchemaVersion: "1.0.0",
+			ContentSchemaName:    reports.StageUploadTerminated,
+		},
+		Filename: metadataPkg.GetFilename(manifest),
+		Offset:   event.Upload.Offset,
+		Size:     event.Upload.Size,
+		Manifest: manifest,
+	}).Build()
+
+	logger.Info("REPORT upload-terminated", "report", report)
+	reports.Publish(event.Context, report)
+
+	logger.Info("upload-terminated report complete")
+	return resp, nil
+}
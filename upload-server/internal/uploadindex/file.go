@@ -0,0 +1,99 @@
+package uploadindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// FileStore keeps the whole index in a single JSON file.  It is meant for local development and single instance
+// deployments.
+type FileStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileStore) Update(_ context.Context, id string, fn func(*Entry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	e := entries[id]
+	fn(&e)
+	entries[id] = e
+	return s.write(entries)
+}
+
+func (s *FileStore) List(_ context.Context, q Query) (Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.read()
+	if err != nil {
+		return Page{}, err
+	}
+	all := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		all = append(all, e)
+	}
+	return page(all, q), nil
+}
+
+func (s *FileStore) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Upload index file store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.read(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
+
+func (s *FileStore) read() (map[string]Entry, error) {
+	entries := map[string]Entry{}
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// write replaces the file atomically so a crash never leaves a partial index behind.
+func (s *FileStore) write(entries map[string]Entry) error {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
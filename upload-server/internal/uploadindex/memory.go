@@ -0,0 +1,92 @@
+package uploadindex
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+)
+
+// MemoryStore keeps the index in process.  It is lost on restart and is not shared between instances.  Only the
+// newest MaxEntries uploads created within TTL are kept, zero keeps them all.
+type MemoryStore struct {
+	MaxEntries int
+	TTL        time.Duration
+	mu         sync.Mutex
+	entries    map[string]*Entry
+	// order holds the entries oldest first, so new uploads are appended and the oldest are evicted from the front.
+	order []*Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*Entry{}}
+}
+
+func (s *MemoryStore) Update(_ context.Context, id string, fn func(*Entry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.entries[id]
+	e := &Entry{}
+	if ok {
+		*e = *old
+		e.Deliveries = maps.Clone(e.Deliveries)
+		s.remove(old)
+	}
+	fn(e)
+	s.entries[id] = e
+	i, _ := slices.BinarySearchFunc(s.order, e, compare)
+	s.order = slices.Insert(s.order, i, e)
+	s.evict(time.Now())
+	return nil
+}
+
+// remove takes the entry out of the order it is listed in.
+func (s *MemoryStore) remove(e *Entry) {
+	i, ok := slices.BinarySearchFunc(s.order, e, compare)
+	if ok {
+		s.order = slices.Delete(s.order, i, i+1)
+	}
+}
+
+func (s *MemoryStore) evict(now time.Time) {
+	n := 0
+	for n < len(s.order) {
+		e := s.order[n]
+		if (s.MaxEntries <= 0 || len(s.order)-n <= s.MaxEntries) && (s.TTL <= 0 || now.Sub(e.CreatedAt) < s.TTL) {
+			break
+		}
+		delete(s.entries, e.ID)
+		n++
+	}
+	s.order = slices.Delete(s.order, 0, n)
+}
+
+func (s *MemoryStore) List(_ context.Context, q Query) (Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := len(s.order)
+	if q.Cursor != "" {
+		t, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		end, _ = slices.BinarySearchFunc(s.order, &Entry{CreatedAt: t, ID: id}, compare)
+	}
+	return collect(q, func(yield func(*Entry) bool) {
+		for i := end - 1; i >= 0; i-- {
+			if !yield(s.order[i]) {
+				return
+			}
+		}
+	}), nil
+}
+
+func (s *MemoryStore) Health(_ context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Upload index memory store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	return rsp
+}
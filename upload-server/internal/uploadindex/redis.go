@@ -0,0 +1,137 @@
+package uploadindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "upload-index:"
+	redisByCreated = "upload-index:by-created"
+	// redisBatchSize is how many entries are read at a time while filling a page.
+	redisBatchSize = 200
+)
+
+// RedisStore keeps each entry as JSON, along with a sorted set of upload ids scored by creation time that pages
+// are read from.  Redis orders ids with the same score by the id, which is the same order the other stores use.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+func NewRedisStore(uri string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &RedisStore{Client: client}, nil
+}
+
+// Update retries when another instance changes the entry at the same time.
+func (s *RedisStore) Update(ctx context.Context, id string, fn func(*Entry)) error {
+	key := redisKeyPrefix + id
+	for {
+		err := s.Client.Watch(ctx, func(tx *redis.Tx) error {
+			e := &Entry{}
+			data, err := tx.Get(ctx, key).Bytes()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if err == nil {
+				if err := json.Unmarshal(data, e); err != nil {
+					return err
+				}
+			}
+			fn(e)
+			data, err = json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Set(ctx, key, data, 0)
+				p.ZAdd(ctx, redisByCreated, redis.Z{Score: float64(e.CreatedAt.UnixMicro()), Member: id})
+				return nil
+			})
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+}
+
+func (s *RedisStore) List(ctx context.Context, q Query) (Page, error) {
+	hi := "+inf"
+	if q.Cursor != "" {
+		t, _, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		hi = strconv.FormatInt(t.UnixMicro(), 10)
+	}
+	lo := "-inf"
+	if !q.From.IsZero() {
+		lo = strconv.FormatInt(q.From.UnixMicro(), 10)
+	}
+
+	var err error
+	p := collect(q, func(yield func(*Entry) bool) {
+		for offset := int64(0); ; offset += redisBatchSize {
+			var ids []string
+			ids, err = s.Client.ZRevRangeByScore(ctx, redisByCreated, &redis.ZRangeBy{
+				Min:    lo,
+				Max:    hi,
+				Offset: offset,
+				Count:  redisBatchSize,
+			}).Result()
+			if err != nil || len(ids) == 0 {
+				return
+			}
+			keys := make([]string, len(ids))
+			for i, id := range ids {
+				keys[i] = redisKeyPrefix + id
+			}
+			var values []any
+			values, err = s.Client.MGet(ctx, keys...).Result()
+			if err != nil {
+				return
+			}
+			for _, v := range values {
+				data, ok := v.(string)
+				if !ok {
+					continue
+				}
+				var e Entry
+				if err = json.Unmarshal([]byte(data), &e); err != nil {
+					return
+				}
+				if !yield(&e) {
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	return p, nil
+}
+
+func (s *RedisStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Upload index redis store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.Client.Ping(ctx).Err(); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
@@ -0,0 +1,212 @@
+package uploadindex
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS upload_index (
+	id TEXT COLLATE "C" PRIMARY KEY,
+	data_stream_id TEXT NOT NULL DEFAULT '',
+	data_stream_route TEXT NOT NULL DEFAULT '',
+	sender_id TEXT NOT NULL DEFAULT '',
+	jurisdiction TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	entry JSONB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS upload_index_created ON upload_index (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS upload_index_data_stream ON upload_index (lower(data_stream_id), lower(data_stream_route), created_at DESC)`
+
+// SQLStore keeps the index in a Postgres upload_index table, which is created if it does not exist.  The fields
+// uploads are filtered by have their own columns, and the whole entry is kept as JSON.  Ids are compared byte by
+// byte so pages come back in the same order as the other stores.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+func NewSQLStore(ctx context.Context, connection string) (*SQLStore, error) {
+	db, err := sql.Open("pgx", connection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLStore{DB: db}, nil
+}
+
+// Update locks the row of the upload until the updated entry is written.
+func (s *SQLStore) Update(ctx context.Context, id string, fn func(*Entry)) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO upload_index (id, created_at, entry) VALUES ($1, now(), '{}') ON CONFLICT (id) DO NOTHING`, id); err != nil {
+		return err
+	}
+	var data []byte
+	if err := tx.QueryRowContext(ctx, `SELECT entry FROM upload_index WHERE id = $1 FOR UPDATE`, id).Scan(&data); err != nil {
+		return err
+	}
+	e := &Entry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return err
+	}
+	fn(e)
+	data, err = json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE upload_index SET data_stream_id = $2, data_stream_route = $3, sender_id = $4, jurisdiction = $5, status = $6, created_at = $7, entry = $8 WHERE id = $1`,
+		id, e.DataStreamID, e.DataStreamRoute, e.SenderID, e.Jurisdiction, e.Status, e.CreatedAt, data); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) List(ctx context.Context, q Query) (Page, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, f := range []struct{ column, value string }{
+		{"data_stream_id", q.DataStreamID},
+		{"data_stream_route", q.DataStreamRoute},
+		{"sender_id", q.SenderID},
+		{"jurisdiction", q.Jurisdiction},
+	} {
+		if f.value != "" {
+			where = append(where, fmt.Sprintf("lower(%s) = lower(%s)", f.column, arg(f.value)))
+		}
+	}
+	if q.Status != "" {
+		where = append(where, "status = "+arg(q.Status))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < "+arg(q.To))
+	}
+	if v := q.Viewer; v != nil {
+		// the same as Viewer.CanView, so uploads that are not visible are never read
+		visible := []string{fmt.Sprintf("(%s <> '' AND entry->'principal'->>'subject' = %s AND entry->'principal'->>'issuer' = %s)", arg(v.Subject), arg(v.Subject), arg(v.Issuer))}
+		for _, glob := range v.DataStreams {
+			if re, ok := globRegexp(strings.ToLower(glob)); ok {
+				visible = append(visible, "lower(data_stream_id || '/' || data_stream_route) ~ "+arg(re))
+			}
+		}
+		where = append(where, "("+strings.Join(visible, " OR ")+")")
+	}
+	if q.Cursor != "" {
+		t, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(t), arg(id)))
+	}
+	query := "SELECT entry FROM upload_index"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// one more row than the page holds tells whether there is a next page
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(q.limit()+1)
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+	p := Page{Uploads: []Entry{}}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return Page{}, err
+		}
+		if len(p.Uploads) == q.limit() {
+			p.NextCursor = cursor(&p.Uploads[len(p.Uploads)-1])
+			break
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return Page{}, err
+		}
+		p.Uploads = append(p.Uploads, e)
+	}
+	return p, rows.Err()
+}
+
+// globRegexp translates a path.Match glob to a Postgres regular expression matching the same strings.  Globs that
+// are malformed match nothing, as they do for path.Match.
+func globRegexp(glob string) (string, bool) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return "", false
+	}
+	// a backslash followed by any other character than a letter or digit stands for that character, in and out of
+	// brackets
+	quote := func(b *strings.Builder, r rune) {
+		if r < utf8.RuneSelf && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	var b strings.Builder
+	b.WriteByte('^')
+	runes := []rune(glob)
+	inClass := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			i++
+			quote(&b, runes[i])
+		case inClass && r == ']':
+			inClass = false
+			b.WriteRune(r)
+		case inClass && r == '-':
+			b.WriteRune(r)
+		case inClass:
+			quote(&b, r)
+		case r == '[':
+			inClass = true
+			b.WriteRune(r)
+			if i+1 < len(runes) && runes[i+1] == '^' {
+				i++
+				b.WriteRune('^')
+			}
+		case r == '*':
+			b.WriteString("[^/]*")
+		case r == '?':
+			b.WriteString("[^/]")
+		default:
+			quote(&b, r)
+		}
+	}
+	b.WriteByte('$')
+	return b.String(), true
+}
+
+func (s *SQLStore) Health(ctx context.Context) (rsp models.ServiceHealthResp) {
+	rsp.Service = "Upload index sql store"
+	rsp.Status = models.STATUS_UP
+	rsp.HealthIssue = models.HEALTH_ISSUE_NONE
+	if err := s.DB.PingContext(ctx); err != nil {
+		rsp.Status = models.STATUS_DOWN
+		rsp.HealthIssue = err.Error()
+	}
+	return rsp
+}
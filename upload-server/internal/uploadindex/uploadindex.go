@@ -0,0 +1,352 @@
+package uploadindex
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"iter"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/health"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+)
+
+const (
+	StatusInitiated  = "initiated"
+	StatusInProgress = "in progress"
+	StatusComplete   = "complete"
+	StatusDelivered  = "delivered"
+	StatusFailed     = "failed"
+	StatusTerminated = "terminated"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+	// MaxScanned is how many uploads a store looks at for one page.  A page that is not full by then is returned
+	// short, with a cursor to carry on from.
+	MaxScanned = 5000
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidStatus = errors.New("invalid status")
+)
+
+// Default is the index the server keeps of its uploads.
+var Default *Index
+
+// Entry is what the index knows about an upload, gathered from the reports published for it.
+type Entry struct {
+	ID              string              `json:"upload_id"`
+	DataStreamID    string              `json:"data_stream_id"`
+	DataStreamRoute string              `json:"data_stream_route"`
+	SenderID        string              `json:"sender_id,omitempty"`
+	Jurisdiction    string              `json:"jurisdiction,omitempty"`
+	Principal       *metadata.Principal `json:"principal,omitempty"`
+	Filename        string              `json:"filename,omitempty"`
+	Status          string              `json:"status"`
+	Size            int64               `json:"size"`
+	Offset          int64               `json:"offset"`
+	// Error is the reason the upload was rejected.
+	Error string `json:"error,omitempty"`
+	// Deliveries holds the status of the last delivery to each destination.
+	Deliveries   map[string]string `json:"deliveries,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	CompletedAt  *time.Time        `json:"completed_at,omitempty"`
+	TerminatedAt *time.Time        `json:"terminated_at,omitempty"`
+}
+
+// Apply records the report on the entry and updates its status.
+func (e *Entry) Apply(r *reports.Report) {
+	setIfEmpty := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	setIfEmpty(&e.DataStreamID, r.DataStreamID)
+	setIfEmpty(&e.DataStreamRoute, r.DataStreamRoute)
+	setIfEmpty(&e.SenderID, r.SenderID)
+	setIfEmpty(&e.Jurisdiction, r.Jurisdiction)
+	if r.StageInfo.Principal != nil {
+		e.Principal = r.StageInfo.Principal
+	}
+
+	switch r.StageInfo.Action {
+	case reports.StageMetadataVerify, reports.StageSenderAuthorization, reports.StageDuplicateCheck, reports.StageMalwareScan:
+		if c, ok := r.Content.(reports.MetaDataVerifyContent); ok {
+			setIfEmpty(&e.Filename, c.Filename)
+		}
+		if r.StageInfo.Status == reports.StatusFailed {
+			e.Error = r.StageInfo.Action + " failed"
+			for _, issue := range r.StageInfo.Issues {
+				if issue.Level == reports.IssueLevelError {
+					e.Error = issue.Message
+					break
+				}
+			}
+		}
+	case reports.StageUploadStatus:
+		if c, ok := r.Content.(reports.UploadStatusContent); ok {
+			setIfEmpty(&e.Filename, c.Filename)
+			e.Size = c.Size
+			e.Offset = max(e.Offset, c.Offset)
+		}
+	case reports.StageUploadCompleted:
+		t, err := time.Parse(time.RFC3339Nano, r.StageInfo.EndProcessTime)
+		if err != nil {
+			t = time.Now().UTC()
+		}
+		e.CompletedAt = &t
+	case reports.StageUploadTerminated:
+		t, err := time.Parse(time.RFC3339Nano, r.StageInfo.EndProcessTime)
+		if err != nil {
+			t = time.Now().UTC()
+		}
+		e.TerminatedAt = &t
+	case reports.StageFileCopy:
+		if c, ok := r.Content.(reports.FileCopyContent); ok && c.DestinationName != "" {
+			if e.Deliveries == nil {
+				e.Deliveries = map[string]string{}
+			}
+			e.Deliveries[c.DestinationName] = r.StageInfo.Status
+		}
+	}
+	e.Status = e.status()
+}
+
+func (e *Entry) status() string {
+	if e.TerminatedAt != nil {
+		return StatusTerminated
+	}
+	if e.Error != "" {
+		return StatusFailed
+	}
+	for _, s := range e.Deliveries {
+		if s == reports.StatusFailed {
+			return StatusFailed
+		}
+	}
+	if e.CompletedAt != nil {
+		if len(e.Deliveries) > 0 {
+			return StatusDelivered
+		}
+		return StatusComplete
+	}
+	if e.Offset > 0 {
+		return StatusInProgress
+	}
+	return StatusInitiated
+}
+
+// Viewer limits the uploads listed to the ones a caller may view: the uploads to the data streams matching one of
+// DataStreams, which are path.Match globs of "<data_stream_id>/<data_stream_route>", and the uploads made by the
+// principal with Subject and Issuer.
+type Viewer struct {
+	DataStreams []string
+	Subject     string
+	Issuer      string
+}
+
+// CanView reports whether the viewer may view the upload.
+func (v *Viewer) CanView(e *Entry) bool {
+	stream := strings.ToLower(e.DataStreamID + "/" + e.DataStreamRoute)
+	for _, glob := range v.DataStreams {
+		if ok, err := path.Match(strings.ToLower(glob), stream); err == nil && ok {
+			return true
+		}
+	}
+	p := e.Principal
+	return p != nil && p.Subject != "" && p.Subject == v.Subject && p.Issuer == v.Issuer
+}
+
+// Query filters the uploads listed by the index.  Empty fields match every upload, From is inclusive and To is
+// exclusive.  A nil Viewer lists the uploads of every caller.
+type Query struct {
+	Viewer          *Viewer
+	DataStreamID    string
+	DataStreamRoute string
+	SenderID        string
+	Jurisdiction    string
+	Status          string
+	From            time.Time
+	To              time.Time
+	Cursor          string
+	Limit           int
+}
+
+func (q Query) Validate() error {
+	switch q.Status {
+	case "", StatusInitiated, StatusInProgress, StatusComplete, StatusDelivered, StatusFailed, StatusTerminated:
+	default:
+		return fmt.Errorf("%w %q", ErrInvalidStatus, q.Status)
+	}
+	if q.Cursor != "" {
+		if _, _, err := decodeCursor(q.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultLimit
+	}
+	return min(q.Limit, MaxLimit)
+}
+
+// Match reports whether the entry passes the filters of the query, ignoring the cursor.
+func (q Query) Match(e *Entry) bool {
+	match := func(want, got string) bool {
+		return want == "" || strings.EqualFold(want, got)
+	}
+	return match(q.DataStreamID, e.DataStreamID) &&
+		match(q.DataStreamRoute, e.DataStreamRoute) &&
+		match(q.SenderID, e.SenderID) &&
+		match(q.Jurisdiction, e.Jurisdiction) &&
+		(q.Status == "" || q.Status == e.Status) &&
+		(q.From.IsZero() || !e.CreatedAt.Before(q.From)) &&
+		(q.To.IsZero() || e.CreatedAt.Before(q.To)) &&
+		(q.Viewer == nil || q.Viewer.CanView(e))
+}
+
+// Page is one page of uploads, newest first.  NextCursor is empty on the last page, and a page can be short when
+// the store stopped after MaxScanned uploads.
+type Page struct {
+	Uploads    []Entry `json:"uploads"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type Store interface {
+	health.Checkable
+	// Update applies fn to the entry for the upload, which starts out empty for uploads the store has not seen,
+	// and saves it.  Concurrent updates of the same upload are applied one after the other.
+	Update(ctx context.Context, id string, fn func(*Entry)) error
+	// List returns the entries matching the query, ordered by creation time and id, newest first.  Entries are
+	// filtered before the page is filled, and no more than MaxScanned are looked at.
+	List(ctx context.Context, q Query) (Page, error)
+}
+
+// Index keeps the upload entries up to date from the published reports.
+type Index struct {
+	Store Store
+}
+
+func (i *Index) Publish(ctx context.Context, r *reports.Report) error {
+	if r.UploadID == "" {
+		return nil
+	}
+	return i.Store.Update(ctx, r.UploadID, func(e *Entry) {
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		if e.CreatedAt.IsZero() {
+			e.ID = r.UploadID
+			e.CreatedAt = now
+		}
+		e.UpdatedAt = now
+		e.Apply(r)
+	})
+}
+
+// List returns a page of the uploads matching the query.
+func (i *Index) List(ctx context.Context, q Query) (Page, error) {
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	q.Limit = q.limit()
+	return i.Store.List(ctx, q)
+}
+
+func cursor(e *Entry) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(e.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + e.ID))
+}
+
+func decodeCursor(c string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return t, id, nil
+}
+
+// before reports whether a sorts after b in the newest first order of the index.
+func before(a *Entry, b *Entry) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// afterCursor reports whether the entry belongs on a page after the cursor.
+func afterCursor(e *Entry, c string) bool {
+	if c == "" {
+		return true
+	}
+	t, id, err := decodeCursor(c)
+	if err != nil {
+		return false
+	}
+	return before(&Entry{CreatedAt: t, ID: id}, e)
+}
+
+// compare orders entries oldest first, the reverse of the order they are listed in.
+func compare(a, b *Entry) int {
+	if before(b, a) {
+		return -1
+	}
+	if before(a, b) {
+		return 1
+	}
+	return 0
+}
+
+// collect fills a page with the entries past the cursor that match the query, from entries ordered newest first.
+func collect(q Query, entries iter.Seq[*Entry]) Page {
+	p := Page{Uploads: []Entry{}}
+	scanned := 0
+	for e := range entries {
+		if !afterCursor(e, q.Cursor) {
+			continue
+		}
+		if q.Match(e) {
+			if len(p.Uploads) == q.limit() {
+				p.NextCursor = cursor(&p.Uploads[len(p.Uploads)-1])
+				break
+			}
+			p.Uploads = append(p.Uploads, *e)
+		}
+		if scanned++; scanned == MaxScanned {
+			p.NextCursor = cursor(e)
+			break
+		}
+	}
+	return p
+}
+
+// page sorts the entries and returns the page of them matching the query.
+func page(entries []Entry, q Query) Page {
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return compare(&b, &a)
+	})
+	return collect(q, func(yield func(*Entry) bool) {
+		for i := range entries {
+			if !yield(&entries[i]) {
+				return
+			}
+		}
+	})
+}
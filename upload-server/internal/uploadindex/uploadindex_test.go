@@ -0,0 +1,319 @@
+package uploadindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+	"github.com/redis/go-redis/v9"
+)
+
+func stores(t *testing.T) map[string]Store {
+	mr := miniredis.RunT(t)
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   &FileStore{Path: filepath.Join(t.TempDir(), "uploads.json")},
+		"redis":  &RedisStore{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()})},
+	}
+}
+
+func report[T any](stage string, id string, manifest map[string]string, status string, content T) *reports.Report {
+	return reports.NewBuilderWithManifest[T]("1.0.0", stage, id, manifest, reports.DispositionTypeAdd).
+		SetStatus(status).
+		SetContent(content).
+		Build()
+}
+
+func statusReport(id string, manifest map[string]string, offset int64, size int64) *reports.Report {
+	return report(reports.StageUploadStatus, id, manifest, reports.StatusSuccess, reports.UploadStatusContent{
+		Filename: manifest["filename"],
+		Offset:   offset,
+		Size:     size,
+	})
+}
+
+func TestIndex(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			index := &Index{Store: store}
+			manifest := func(route string, sender string) map[string]string {
+				return map[string]string{
+					"data_stream_id":    "dextesting",
+					"data_stream_route": route,
+					"sender_id":         sender,
+					"jurisdiction":      "CA",
+					"filename":          "test.txt",
+				}
+			}
+			publish := func(r *reports.Report) {
+				t.Helper()
+				if err := index.Publish(ctx, r); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// initiated
+			publish(report(reports.StageUploadStarted, "initiated", manifest("testevent1", "alice"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			publish(statusReport("initiated", manifest("testevent1", "alice"), 0, 11))
+			// in progress
+			publish(report(reports.StageUploadStarted, "progress", manifest("testevent1", "alice"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			publish(statusReport("progress", manifest("testevent1", "alice"), 5, 11))
+			// complete
+			publish(report(reports.StageUploadStarted, "complete", manifest("testevent1", "bob"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			publish(statusReport("complete", manifest("testevent1", "bob"), 11, 11))
+			publish(report(reports.StageUploadCompleted, "complete", manifest("testevent1", "bob"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			// delivered, reports of deliveries do not always carry the manifest
+			publish(report(reports.StageUploadStarted, "delivered", manifest("testevent2", "bob"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			publish(report(reports.StageUploadCompleted, "delivered", manifest("testevent2", "bob"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			publish(report(reports.StageFileCopy, "delivered", nil, reports.StatusSuccess, reports.FileCopyContent{DestinationName: "edav"}))
+			// failed
+			publish(report(reports.StageMetadataVerify, "failed", manifest("testevent2", "alice"), reports.StatusFailed, reports.MetaDataVerifyContent{}))
+			// terminated
+			publish(report(reports.StageUploadStarted, "terminated", manifest("testevent2", "alice"), reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			publish(statusReport("terminated", manifest("testevent2", "alice"), 5, 11))
+			publish(report(reports.StageUploadTerminated, "terminated", manifest("testevent2", "alice"), reports.StatusSuccess, reports.UploadTerminatedContent{}))
+
+			p, err := index.List(ctx, Query{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]Entry{}
+			for _, e := range p.Uploads {
+				got[e.ID] = e
+			}
+			for id, status := range map[string]string{
+				"initiated":  StatusInitiated,
+				"progress":   StatusInProgress,
+				"complete":   StatusComplete,
+				"delivered":  StatusDelivered,
+				"failed":     StatusFailed,
+				"terminated": StatusTerminated,
+			} {
+				if got[id].Status != status {
+					t.Errorf("expected %s to be %s, got %+v", id, status, got[id])
+				}
+			}
+			if e := got["delivered"]; e.DataStreamRoute != "testevent2" || e.SenderID != "bob" || e.Deliveries["edav"] != reports.StatusSuccess {
+				t.Errorf("expected delivery to keep the upload's fields, got %+v", e)
+			}
+			if e := got["progress"]; e.Offset != 5 || e.Size != 11 || e.Filename != "test.txt" {
+				t.Errorf("expected upload progress, got %+v", e)
+			}
+
+			publish(report(reports.StageFileCopy, "delivered", nil, reports.StatusFailed, reports.FileCopyContent{DestinationName: "routing"}))
+			p, err = index.List(ctx, Query{Status: StatusFailed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 2 {
+				t.Errorf("expected a failed delivery to fail the upload, got %+v", p.Uploads)
+			}
+
+			p, err = index.List(ctx, Query{DataStreamID: "DEXTESTING", DataStreamRoute: "testevent1", SenderID: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 2 || p.Uploads[0].ID != "progress" || p.Uploads[1].ID != "initiated" {
+				t.Errorf("expected alice's testevent1 uploads newest first, got %+v", p.Uploads)
+			}
+
+			p, err = index.List(ctx, Query{From: time.Now().Add(time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 0 {
+				t.Errorf("expected no uploads in the future, got %+v", p.Uploads)
+			}
+
+			p, err = index.List(ctx, Query{Viewer: &Viewer{DataStreams: []string{"DEXTESTING/*event1"}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 3 {
+				t.Errorf("expected only the uploads of visible data streams, got %+v", p.Uploads)
+			}
+
+			sender := manifest("testevent2", "carol")
+			sender[metadata.PrincipalSubjectKey] = "carol"
+			sender[metadata.PrincipalIssuerKey] = "https://idp.example.com"
+			sender[metadata.PrincipalMethodKey] = "oauth"
+			publish(report(reports.StageUploadStarted, "sent", sender, reports.StatusSuccess, reports.UploadLifecycleContent{}))
+			for _, tc := range []struct {
+				viewer Viewer
+				want   int
+			}{
+				{Viewer{Subject: "carol", Issuer: "https://idp.example.com"}, 1},
+				{Viewer{Subject: "carol", Issuer: "https://other.example.com"}, 0},
+				{Viewer{}, 0},
+			} {
+				p, err = index.List(ctx, Query{Viewer: &tc.viewer})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(p.Uploads) != tc.want {
+					t.Errorf("expected %+v to view %d uploads, got %+v", tc.viewer, tc.want, p.Uploads)
+				}
+			}
+		})
+	}
+}
+
+func TestIndex_Pagination(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			index := &Index{Store: store}
+			for i := range 7 {
+				r := report(reports.StageUploadStarted, fmt.Sprintf("upload%d", i), map[string]string{"sender_id": fmt.Sprintf("sender%d", i%2)}, reports.StatusSuccess, reports.UploadLifecycleContent{})
+				if err := index.Publish(ctx, r); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var ids []string
+			q := Query{Limit: 2}
+			for pages := 0; ; pages++ {
+				if pages > 4 {
+					t.Fatal("too many pages")
+				}
+				p, err := index.List(ctx, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, e := range p.Uploads {
+					ids = append(ids, e.ID)
+				}
+				if p.NextCursor == "" {
+					break
+				}
+				q.Cursor = p.NextCursor
+			}
+			if fmt.Sprint(ids) != "[upload6 upload5 upload4 upload3 upload2 upload1 upload0]" {
+				t.Errorf("expected every upload once newest first, got %v", ids)
+			}
+
+			p, err := index.List(ctx, Query{Limit: 3, SenderID: "sender0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 3 || p.NextCursor == "" {
+				t.Errorf("expected a full page of matching uploads, got %+v", p)
+			}
+		})
+	}
+}
+
+func TestIndex_MaxScanned(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			index := &Index{Store: store}
+			entries := map[string]Entry{}
+			for i := range MaxScanned + 1 {
+				id := fmt.Sprintf("upload%05d", i)
+				entries[id] = Entry{ID: id, SenderID: "alice", CreatedAt: time.Unix(int64(i), 0).UTC()}
+			}
+			if fs, ok := store.(*FileStore); ok {
+				// the file is written once, instead of on every update
+				if err := fs.write(entries); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				for id, entry := range entries {
+					if err := store.Update(ctx, id, func(e *Entry) { *e = entry }); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			p, err := index.List(ctx, Query{SenderID: "bob"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 0 || p.NextCursor == "" {
+				t.Fatalf("expected an empty page to carry on from, got %+v", p)
+			}
+			p, err = index.List(ctx, Query{SenderID: "bob", Cursor: p.NextCursor})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Uploads) != 0 || p.NextCursor != "" {
+				t.Errorf("expected the last page, got %+v", p)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Evict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.MaxEntries = 3
+	store.TTL = time.Hour
+	now := time.Now().UTC()
+	for i, age := range []time.Duration{2 * time.Hour, 4 * time.Minute, 3 * time.Minute, 2 * time.Minute, time.Minute} {
+		id := fmt.Sprintf("upload%d", i)
+		if err := store.Update(ctx, id, func(e *Entry) {
+			e.ID = id
+			e.CreatedAt = now.Add(-age)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// updating an upload keeps its place
+	if err := store.Update(ctx, "upload2", func(e *Entry) { e.Status = StatusInProgress }); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := store.List(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range p.Uploads {
+		ids = append(ids, e.ID)
+	}
+	if fmt.Sprint(ids) != "[upload4 upload3 upload2]" {
+		t.Errorf("expected the newest uploads to be kept, got %v", ids)
+	}
+	if len(store.entries) != 3 {
+		t.Errorf("expected evicted uploads to be forgotten, got %d", len(store.entries))
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	names := []string{"dextesting/testevent1", "dextesting/testevent2", "dex.testing/x", "dextesting/", "a/b/c", "x*y/z", "[a]/b"}
+	for _, glob := range []string{"*/*", "dextesting/*", "dex?testing/*", "dextesting/testevent[1]", "dextesting/testevent[^1]", "dextesting/testevent[0-1]", "dex.testing/*", `x\*y/*`, `\[a]/?`, "*", "[a"} {
+		re, ok := globRegexp(glob)
+		for _, name := range names {
+			want, err := path.Match(glob, name)
+			if err != nil {
+				want = false
+			}
+			got := ok && regexp.MustCompile(re).MatchString(name)
+			if got != want {
+				t.Errorf("expected %q to match %q %v, got %v with %q", glob, name, want, got, re)
+			}
+		}
+	}
+}
+
+func TestQuery_Validate(t *testing.T) {
+	if err := (Query{Status: "done"}).Validate(); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("expected invalid status, got %v", err)
+	}
+	if err := (Query{Cursor: "not a cursor"}).Validate(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected invalid cursor, got %v", err)
+	}
+	if err := (Query{Status: StatusInProgress}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
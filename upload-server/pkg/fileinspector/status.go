@@ -63,7 +63,44 @@ func (fsusi *FileSystemUploadStatusInspector) InspectFileDeliveryStatus(_ contex
 	return deliveries, nil
 }
 
+func (fsusi *FileSystemUploadStatusInspector) InspectTerminatedUpload(_ context.Context, id string) (map[string]any, error) {
+	uploadTerminatedReportFilename := filepath.Join(fsusi.ReportsDir, id+event.TypeSeparator+reports.StageUploadTerminated)
+	b, err := os.ReadFile(uploadTerminatedReportFilename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Join(err, info.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	// an upload is only terminated once, so the report file holds a single report
+	var report struct {
+		Content reports.UploadTerminatedContent `json:"content"`
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, err
+	}
+	manifest := map[string]any{}
+	for k, v := range report.Content.Manifest {
+		manifest[k] = v
+	}
+	return manifest, nil
+}
+
 func (fsusi *FileSystemUploadStatusInspector) InspectFileUploadStatus(ctx context.Context, id string) (info.FileUploadStatus, error) {
+	// a terminated upload keeps its reports, so check for termination before completion
+	uploadTerminatedReportFilename := filepath.Join(fsusi.ReportsDir, id+event.TypeSeparator+reports.StageUploadTerminated)
+	uploadTerminatedFileInfo, err := os.Stat(uploadTerminatedReportFilename)
+	if err == nil {
+		return info.FileUploadStatus{
+			Status:            info.UploadTerminated,
+			LastChunkReceived: uploadTerminatedFileInfo.ModTime().UTC().Format(time.RFC3339Nano),
+		}, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return info.FileUploadStatus{}, err
+	}
+
 	// check if the upload-completed file exists
 	uploadCompletedReportFilename := filepath.Join(fsusi.ReportsDir, id+event.TypeSeparator+reports.StageUploadCompleted)
 	uploadCompletedFileInfo, err := os.Stat(uploadCompletedReportFilename)
@@ -0,0 +1,67 @@
+package gcsinspector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/info"
+)
+
+type GCSUploadInspector struct {
+	Client     *storage.Client
+	BucketName string
+	TusPrefix  string
+}
+
+func NewGCSUploadInspector(client *storage.Client, bucketName string, tusPrefix string) *GCSUploadInspector {
+	return &GCSUploadInspector{
+		Client:     client,
+		BucketName: bucketName,
+		TusPrefix:  tusPrefix,
+	}
+}
+
+func (gui *GCSUploadInspector) InspectInfoFile(c context.Context, id string) (map[string]any, error) {
+	filename := gui.TusPrefix + "/" + id + ".info"
+	r, err := gui.Client.Bucket(gui.BucketName).Object(filename).NewReader(c)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, errors.Join(err, info.ErrNotFound)
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonMap := &info.InfoFileData{}
+	if err := json.Unmarshal(b, jsonMap); err != nil {
+		return nil, err
+	}
+
+	return jsonMap.MetaData, nil
+}
+
+func (gui *GCSUploadInspector) InspectUploadedFile(c context.Context, id string) (map[string]any, error) {
+	filename := gui.TusPrefix + "/" + id
+	attrs, err := gui.Client.Bucket(gui.BucketName).Object(filename).Attrs(c)
+	if err != nil {
+		// Support deferred uploads, whose chunks are only composed into the object when the upload finishes.
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, errors.Join(err, info.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return map[string]any{
+		"updated_at": attrs.Updated.Format(time.RFC3339Nano),
+		"size_bytes": attrs.Size,
+	}, nil
+}
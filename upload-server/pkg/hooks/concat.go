@@ -0,0 +1,28 @@
+package hooks
+
+import (
+	"github.com/tus/tusd/v2/pkg/handler"
+	tusHooks "github.com/tus/tusd/v2/pkg/hooks"
+)
+
+// SkipPartialUploads wraps a hook so it is not run for the partial uploads of a concatenation, which only carry
+// chunks of a file.  The final upload they are concatenated into carries the manifest.
+func SkipPartialUploads(hf HookHandlerFunc) HookHandlerFunc {
+	return func(event *handler.HookEvent, resp tusHooks.HookResponse) (tusHooks.HookResponse, error) {
+		if event.Upload.IsPartial {
+			return resp, nil
+		}
+		return hf(event, resp)
+	}
+}
+
+// FinalUploadsOnly wraps a hook so it is only run for final uploads of a concatenation.  tusd builds final
+// uploads from their partial uploads without the pre-finish hook, so pre-finish work has to be done after.
+func FinalUploadsOnly(hf HookHandlerFunc) HookHandlerFunc {
+	return func(event *handler.HookEvent, resp tusHooks.HookResponse) (tusHooks.HookResponse, error) {
+		if !event.Upload.IsFinal {
+			return resp, nil
+		}
+		return hf(event, resp)
+	}
+}
@@ -1,6 +1,7 @@
 package hooks
 
 import (
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
 	"github.com/tus/tusd/v2/pkg/handler"
 	tusHooks "github.com/tus/tusd/v2/pkg/hooks"
 )
@@ -22,6 +23,16 @@ func (ph *PrebuiltHook) InvokeHook(req tusHooks.HookRequest) (res tusHooks.HookR
 		return res, nil
 	}
 
+	if req.Type == tusHooks.HookPreCreate && req.Event.Context != nil {
+		// the upload does not exist until every pre-create hook lets it through
+		ctx := req.Event.Context
+		var held *reports.Held
+		req.Event.Context, held = reports.Hold(ctx)
+		defer func() {
+			held.Release(ctx, err != nil || res.RejectUpload)
+		}()
+	}
+
 	resp := tusHooks.HookResponse{}
 	for _, hf := range hookFuncs {
 		resp, err = hf(&req.Event, resp)
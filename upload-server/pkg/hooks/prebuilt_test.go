@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/ratelimit"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
+	"github.com/tus/tusd/v2/pkg/handler"
+	tusHooks "github.com/tus/tusd/v2/pkg/hooks"
+)
+
+// reporter keeps the reports published to it.
+type reporter struct {
+	mux     sync.Mutex
+	reports []*reports.Report
+}
+
+func (r *reporter) Publish(_ context.Context, report *reports.Report) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func (r *reporter) stages(id string) map[string]string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	stages := map[string]string{}
+	for _, report := range r.reports {
+		if report.UploadID == id {
+			stages[report.StageInfo.Action] = report.StageInfo.Status
+		}
+	}
+	return stages
+}
+
+func TestPreCreateReports(t *testing.T) {
+	r := &reporter{}
+	reporters := reports.Reporters
+	reports.Reporters = append(reports.Reporters, r)
+	t.Cleanup(func() {
+		reports.Reporters = reporters
+	})
+
+	// stands in for the hooks that verify the manifest before the rate limit is checked
+	verify := func(event *handler.HookEvent, resp tusHooks.HookResponse) (tusHooks.HookResponse, error) {
+		resp.ChangeFileInfo.ID = event.Upload.MetaData["id"]
+		rb := reports.NewBuilderWithManifest[reports.MetaDataVerifyContent]("1.0.0", reports.StageMetadataVerify, resp.ChangeFileInfo.ID, event.Upload.MetaData, reports.DispositionTypeAdd)
+		reports.Publish(event.Context, rb.Build())
+		return resp, nil
+	}
+	limiter := &ratelimit.Limiter{Store: ratelimit.NewMemoryStore(), Principal: ratelimit.Limits{MaxUploadSize: 10}}
+	ph := &PrebuiltHook{}
+	ph.Register(tusHooks.HookPreCreate, verify, limiter.Check)
+
+	create := func(id string, size int64) tusHooks.HookResponse {
+		ctx := oauth.NewContext(context.Background(), oauth.Claims{Subject: "alice", Issuer: "test", Method: oauth.MethodJWT})
+		resp, err := ph.InvokeHook(tusHooks.HookRequest{Type: tusHooks.HookPreCreate, Event: handler.HookEvent{
+			Context: ctx,
+			Upload:  handler.FileInfo{Size: size, MetaData: handler.MetaData{"id": id, "data_stream_id": "dextesting", "data_stream_route": "testevent1"}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := create("limited", 100); !resp.RejectUpload {
+		t.Fatalf("expected the upload over the rate limit to be rejected, got %+v", resp.HTTPResponse)
+	}
+	if stages := r.stages("limited"); len(stages) != 0 {
+		t.Errorf("expected a rate limited upload to leave no reports, got %v", stages)
+	}
+
+	if resp := create("allowed", 5); resp.RejectUpload {
+		t.Fatalf("expected the upload within the rate limit to be created, got %+v", resp.HTTPResponse)
+	}
+	if stages := r.stages("allowed"); stages[reports.StageMetadataVerify] != reports.StatusSuccess {
+		t.Errorf("expected the reports of a created upload to be published, got %v", stages)
+	}
+}
@@ -16,6 +16,7 @@ const (
 	UploadInitiated  string = "Initiated"
 	UploadInProgress string = "In Progress"
 	UploadComplete   string = "Complete"
+	UploadTerminated string = "Terminated"
 )
 
 type InfoResponse struct {
@@ -41,3 +42,14 @@ type FileDeliveryStatus struct {
 	DeliveredAt string                `json:"delivered_at"`
 	Issues      []reports.ReportIssue `json:"issues"`
 }
+
+// ManifestValues returns the string values of a manifest read from an info file.
+func ManifestValues(manifest map[string]any) map[string]string {
+	values := make(map[string]string, len(manifest))
+	for k, v := range manifest {
+		if s, ok := v.(string); ok {
+			values[k] = s
+		}
+	}
+	return values
+}
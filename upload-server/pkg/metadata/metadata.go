@@ -23,3 +23,46 @@ func GetJurisdiction(manifest map[string]string) string {
 func GetDexIngestDatetime(manifest map[string]string) string {
 	return manifest["dex_ingest_datetime"]
 }
+
+// Server side metadata keys for the authenticated principal that created an upload.  They are set by the upload
+// server and any values a sender supplies for them are dropped.
+const (
+	PrincipalSubjectKey  = "dex_principal_subject"
+	PrincipalClientIDKey = "dex_principal_client_id"
+	PrincipalIssuerKey   = "dex_principal_issuer"
+	PrincipalMethodKey   = "dex_principal_method"
+)
+
+var PrincipalKeys = []string{PrincipalSubjectKey, PrincipalClientIDKey, PrincipalIssuerKey, PrincipalMethodKey}
+
+// Principal is who created an upload, as authenticated by the upload server.
+type Principal struct {
+	Subject  string `json:"subject"`
+	ClientID string `json:"client_id,omitempty"`
+	Issuer   string `json:"issuer"`
+	Method   string `json:"method"`
+}
+
+// GetPrincipal returns the principal recorded on the manifest, or nil for uploads that were not authenticated.
+func GetPrincipal(manifest map[string]string) *Principal {
+	method, ok := manifest[PrincipalMethodKey]
+	if !ok {
+		return nil
+	}
+	return &Principal{
+		Subject:  manifest[PrincipalSubjectKey],
+		ClientID: manifest[PrincipalClientIDKey],
+		Issuer:   manifest[PrincipalIssuerKey],
+		Method:   method,
+	}
+}
+
+// Fields returns the manifest fields that record the principal.
+func (p *Principal) Fields() map[string]string {
+	return map[string]string{
+		PrincipalSubjectKey:  p.Subject,
+		PrincipalClientIDKey: p.ClientID,
+		PrincipalIssuerKey:   p.Issuer,
+		PrincipalMethodKey:   p.Method,
+	}
+}
@@ -0,0 +1,375 @@
+package pglocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+var (
+	// LockExchangeChannel is notified with the id of an upload when another request wants its lock.
+	LockExchangeChannel = "tusd_lock_release_request"
+	// LockReleaseChannel is notified with the id of an upload when its lock is released.
+	LockReleaseChannel = "tusd_lock_released"
+	LockExpiry         = 8 * time.Second
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS tusd_locks (
+	id TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+)`
+
+// A lease is taken when there is none for the upload, or when the one there has expired because its holder stopped
+// extending it.  Lease times use the database clock, so the clocks of the servers do not have to agree.
+const (
+	acquireLease = `INSERT INTO tusd_locks (id, holder, expires_at) VALUES ($1, $2, now() + $3::interval)
+ON CONFLICT (id) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+WHERE tusd_locks.expires_at < now()
+RETURNING holder`
+	extendLease  = `UPDATE tusd_locks SET expires_at = now() + $3::interval WHERE id = $1 AND holder = $2`
+	releaseLease = `DELETE FROM tusd_locks WHERE id = $1 AND holder = $2`
+)
+
+var ErrLockLost = errors.New("lock lease expired or was taken by another holder")
+
+type LockerOption func(l *PostgresLocker)
+
+func WithLogger(logger *slog.Logger) LockerOption {
+	return func(l *PostgresLocker) {
+		l.logger = logger
+	}
+}
+
+// New connects to the database, creating the tusd_locks table if it does not exist, and starts listening for lock
+// release requests and releases.
+func New(uri string, lockerOptions ...LockerOption) (*PostgresLocker, error) {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, createTable); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	locker := &PostgresLocker{
+		pool:   pool,
+		logger: slog.Default(),
+	}
+	for _, option := range lockerOptions {
+		option(locker)
+	}
+
+	locker.notifier = &notifier{
+		config:      pool.Config().ConnConfig.Copy(),
+		logger:      locker.logger,
+		subscribers: map[string]map[chan struct{}]struct{}{},
+	}
+	conn, err := locker.notifier.connect(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	ctx, locker.cancel = context.WithCancel(ctx)
+	go locker.notifier.run(ctx, conn)
+
+	return locker, nil
+}
+
+// PostgresLocker holds tus upload locks as leases in a Postgres table.  Holders extend their leases while they
+// hold them, so the lock of a server that crashed is taken over once its lease expires.  Requests for a held lock
+// are handed off over LISTEN/NOTIFY.
+type PostgresLocker struct {
+	pool     *pgxpool.Pool
+	notifier *notifier
+	cancel   func()
+	logger   *slog.Logger
+}
+
+func (locker *PostgresLocker) UseIn(composer *handler.StoreComposer) {
+	composer.UseLocker(locker)
+}
+
+func (locker *PostgresLocker) NewLock(id string) (handler.Lock, error) {
+	return &postgresLock{
+		id:     id,
+		holder: uuid.NewString(),
+		locker: locker,
+		logger: locker.logger.With("uploadId", id),
+	}, nil
+}
+
+// Close stops listening for notifications and closes the connections to the database.
+func (locker *PostgresLocker) Close() {
+	locker.cancel()
+	locker.pool.Close()
+}
+
+func (locker *PostgresLocker) Health(ctx context.Context) models.ServiceHealthResp {
+	var shr models.ServiceHealthResp
+	shr.Service = models.POSTGRES_LOCKER
+
+	if err := locker.pool.Ping(ctx); err != nil {
+		shr.Status = models.STATUS_DOWN
+		shr.HealthIssue = err.Error()
+		return shr
+	}
+	if !locker.notifier.listening() {
+		shr.Status = models.STATUS_DOWN
+		shr.HealthIssue = "not listening for lock notifications"
+		return shr
+	}
+
+	shr.Status = models.STATUS_UP
+	shr.HealthIssue = models.HEALTH_ISSUE_NONE
+	return shr
+}
+
+func (locker *PostgresLocker) notify(ctx context.Context, channel string, id string) error {
+	_, err := locker.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, id)
+	return err
+}
+
+// notifier shares a single connection listening on the lock channels between every lock of the locker.
+type notifier struct {
+	config *pgx.ConnConfig
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	connected   bool
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+func (n *notifier) connect(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, n.config)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range []string{LockExchangeChannel, LockReleaseChannel} {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			conn.Close(ctx)
+			return nil, err
+		}
+	}
+	n.setConnected(true)
+	return conn, nil
+}
+
+// run delivers notifications until the context is canceled, reconnecting when the connection is lost.
+// Notifications sent while it is reconnecting are missed, so locks waiting on them fall back to retrying.
+func (n *notifier) run(ctx context.Context, conn *pgx.Conn) {
+	for {
+		err := n.receive(ctx, conn)
+		n.setConnected(false)
+		conn.Close(context.Background())
+		if ctx.Err() != nil {
+			return
+		}
+		n.logger.Error("lost lock notification connection", "error", err)
+
+		for conn = nil; conn == nil; {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+			if conn, err = n.connect(ctx); err != nil {
+				n.logger.Error("failed to reconnect lock notification connection", "error", err)
+			}
+		}
+	}
+}
+
+func (n *notifier) receive(ctx context.Context, conn *pgx.Conn) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		n.mu.Lock()
+		for c := range n.subscribers[key(notification.Channel, notification.Payload)] {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+		n.mu.Unlock()
+	}
+}
+
+func key(channel string, id string) string {
+	return channel + ":" + id
+}
+
+// subscribe returns a channel that receives the notifications sent on the channel for the upload, until the
+// returned func is called.
+func (n *notifier) subscribe(channel string, id string) (<-chan struct{}, func()) {
+	k := key(channel, id)
+	c := make(chan struct{}, 1)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subscribers[k] == nil {
+		n.subscribers[k] = map[chan struct{}]struct{}{}
+	}
+	n.subscribers[k][c] = struct{}{}
+	return c, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers[k], c)
+		if len(n.subscribers[k]) == 0 {
+			delete(n.subscribers, k)
+		}
+	}
+}
+
+func (n *notifier) setConnected(connected bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.connected = connected
+}
+
+func (n *notifier) listening() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.connected
+}
+
+type postgresLock struct {
+	id string
+	// holder identifies this lock's lease, so it is never extended or released by another lock of the upload.
+	holder string
+	locker *PostgresLocker
+	ctx    context.Context
+	cancel func()
+	logger *slog.Logger
+}
+
+func (l *postgresLock) Lock(ctx context.Context, releaseRequested func()) error {
+	l.logger.Debug("locking upload")
+	if err := l.requestLock(ctx); err != nil {
+		return err
+	}
+	requests, stop := l.locker.notifier.subscribe(LockExchangeChannel, l.id)
+	go func() {
+		defer stop()
+		select {
+		case <-requests:
+			if releaseRequested != nil {
+				releaseRequested()
+			}
+		case <-l.ctx.Done():
+		}
+	}()
+	go func() {
+		if err := l.keepAlive(l.ctx); err != nil {
+			l.cancel()
+			if releaseRequested != nil {
+				releaseRequested()
+			}
+		}
+	}()
+	l.logger.Debug("locked upload")
+	return nil
+}
+
+func (l *postgresLock) aquireLock(ctx context.Context) error {
+	var holder string
+	err := l.locker.pool.QueryRow(ctx, acquireLease, l.id, l.holder, LockExpiry).Scan(&holder)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return handler.ErrFileLocked
+	}
+	if err != nil {
+		return err
+	}
+
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+
+	return nil
+}
+
+func (l *postgresLock) requestLock(ctx context.Context) error {
+	err := l.aquireLock(ctx)
+	if !errors.Is(err, handler.ErrFileLocked) {
+		return err
+	}
+	released, stop := l.locker.notifier.subscribe(LockReleaseChannel, l.id)
+	defer stop()
+	if err := l.locker.notify(ctx, LockExchangeChannel, l.id); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-released:
+			l.logger.Debug("notified of lock release")
+		case <-time.After(LockExpiry / 2):
+			// the holder may have crashed, or released the lock before it was listened for
+		case <-ctx.Done():
+			return errors.Join(err, handler.ErrLockTimeout)
+		}
+		if err = l.aquireLock(ctx); !errors.Is(err, handler.ErrFileLocked) {
+			return err
+		}
+	}
+}
+
+func (l *postgresLock) keepAlive(ctx context.Context) error {
+	for {
+		select {
+		case <-time.After(LockExpiry / 2):
+			l.logger.Debug("extend lock attempt started", "time", time.Now())
+			tag, err := l.locker.pool.Exec(ctx, extendLease, l.id, l.holder, LockExpiry)
+			if err == nil && tag.RowsAffected() == 0 {
+				err = ErrLockLost
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					// unlocked in the middle of the attempt
+					return nil
+				}
+				l.logger.Error("failed to extend lock", "time", time.Now(), "error", err)
+				return err
+			}
+			l.logger.Debug("lock extended", "time", time.Now())
+		case <-ctx.Done():
+			l.logger.Debug("lock was closed")
+			return nil
+		}
+	}
+}
+
+func (l *postgresLock) Unlock() error {
+	l.logger.Debug("unlocking upload")
+	if l.cancel != nil {
+		l.cancel()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	var err error
+	tag, e := l.locker.pool.Exec(ctx, releaseLease, l.id, l.holder)
+	if e == nil && tag.RowsAffected() == 0 {
+		e = ErrLockLost
+	}
+	if e != nil {
+		l.logger.Error("failed to release lock", "err", e)
+		err = fmt.Errorf("releasing lock: %w", e)
+	}
+	l.logger.Debug("notifying of lock release")
+	if e := l.locker.notify(ctx, LockReleaseChannel, l.id); e != nil {
+		err = errors.Join(err, e)
+	}
+	if err != nil {
+		l.logger.Error("errors while unlocking", "err", err)
+	}
+	return err
+}
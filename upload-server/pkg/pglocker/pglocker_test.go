@@ -0,0 +1,189 @@
+package pglocker
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// uri is the Postgres database the tests lock uploads in.  The tests are skipped when it is not set.
+var uri = os.Getenv("PGLOCKER_TEST_CONNECTION_STRING")
+
+func newLocker(t *testing.T) *PostgresLocker {
+	t.Helper()
+	if uri == "" {
+		t.Skip("PGLOCKER_TEST_CONNECTION_STRING is not set")
+	}
+	locker, err := New(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(locker.Close)
+	return locker
+}
+
+func TestLockUnlock(t *testing.T) {
+	locker := newLocker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	l, err := locker.NewLock("test_lock_unlock")
+	if err != nil {
+		t.Error(err)
+	}
+	requestRelease := func() {
+		t.Error("shouldn't have been calld")
+	}
+	if err := l.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Error(err)
+	}
+	if err := l.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMultipleLocks(t *testing.T) {
+	locker := newLocker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	l, err := locker.NewLock("test_multiple_locks_01")
+	if err != nil {
+		t.Error(err)
+	}
+	requestRelease := func() {
+		t.Error("shouldn't have been calld")
+	}
+	if err := l.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	defer l.Unlock()
+	otherL, err := locker.NewLock("test_multiple_locks_02")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := otherL.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	defer otherL.Unlock()
+}
+
+func TestKeepAlive(t *testing.T) {
+	locker := newLocker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	l, err := locker.NewLock("test_keep_alive")
+	if err != nil {
+		t.Error(err)
+	}
+	requestRelease := func() {
+		t.Error("shouldn't have been calld")
+	}
+	if err := l.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	t.Log("wait for refresh")
+	<-time.After(1 * time.Second)
+	t.Log("done with wait")
+
+	if err := l.Unlock(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeldLockExchange(t *testing.T) {
+	locker := newLocker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	l, err := locker.NewLock("test_exchange")
+	if err != nil {
+		t.Error(err)
+	}
+	requestRelease := func() {
+		if err := l.Unlock(); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := l.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	//assert that request release is called
+	otherL, err := locker.NewLock("test_exchange")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := otherL.Lock(ctx, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := otherL.Unlock(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeldLockNoExchange(t *testing.T) {
+	locker := newLocker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	l, err := locker.NewLock("test_no_exchange")
+	if err != nil {
+		t.Error(err)
+	}
+	defer l.Unlock()
+	requestRelease := func() {
+		t.Log("release requested")
+	}
+	if err := l.Lock(ctx, requestRelease); err != nil {
+		t.Error(err)
+	}
+	//assert that request release is called
+	otherL, err := locker.NewLock("test_no_exchange")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := otherL.Lock(ctx, requestRelease); err == nil {
+		t.Error("should have errored")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestExpiredLock(t *testing.T) {
+	locker := newLocker(t)
+	l, err := locker.NewLock("test_expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := l.Lock(ctx, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	// a holder that crashed stops extending its lease without releasing it
+	l.(*postgresLock).cancel()
+
+	otherL, err := locker.NewLock("test_expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 4*LockExpiry)
+	defer cancel()
+	if err := otherL.Lock(ctx, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := otherL.Unlock(); err != nil {
+		t.Error(err)
+	}
+	if err := l.Unlock(); err == nil {
+		t.Error("expected the expired lock to not be released")
+	}
+}
+
+func TestMain(m *testing.M) {
+	LockExpiry = 200 * time.Millisecond
+	os.Exit(m.Run())
+}
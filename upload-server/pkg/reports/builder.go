@@ -1,6 +1,7 @@
 package reports
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -12,10 +13,14 @@ import (
 
 const StageMetadataVerify = "metadata-verify"
 const StageMetadataTransform = "metadata-transform"
+const StageSenderAuthorization = "sender-authorization"
 const StageFileCopy = "blob-file-copy"
 const StageUploadStatus = "upload-status"
 const StageUploadStarted = "upload-started"
 const StageUploadCompleted = "upload-completed"
+const StageUploadTerminated = "upload-terminated"
+const StageDuplicateCheck = "duplicate-check"
+const StageMalwareScan = "malware-scan"
 const DispositionTypeAdd = "add"
 const DispositionTypeReplace = "replace"
 const StatusSuccess = "SUCCESS"
@@ -36,6 +41,8 @@ type Report struct {
 	DispositionType     string          `json:"disposition_type"`
 	StageInfo           ReportStageInfo `json:"stage_info"`
 	Content             any             `json:"content"` // TODO: Can we limit this to a specific type (i.e. ReportContent or UploadStatusTYpe type?
+	// TraceContext is the W3C trace context of the span that published the report.
+	TraceContext event.TraceContext `json:"trace_context,omitempty"`
 }
 
 func (r *Report) RetryCount() int {
@@ -66,6 +73,17 @@ func (r *Report) SetIdentifier(id string) {
 	r.Event.ID = id
 }
 
+// InjectTraceContext is kept on the report itself, since the event envelope of a report is not serialized.
+func (r *Report) InjectTraceContext(ctx context.Context) {
+	if tc := event.NewTraceContext(ctx); tc != nil {
+		r.TraceContext = tc
+	}
+}
+
+func (r *Report) ExtractTraceContext(ctx context.Context) context.Context {
+	return r.TraceContext.Extract(ctx)
+}
+
 func (r *Report) SetType(t string) {
 	r.Event.Type = t
 }
@@ -75,13 +93,14 @@ func (r *Report) SetOrigMessage(_ *azservicebus.ReceivedMessage) {
 }
 
 type ReportStageInfo struct {
-	Service          string        `json:"service"`
-	Action           string        `json:"action"`
-	Version          string        `json:"version"`
-	Status           string        `json:"status"`
-	Issues           []ReportIssue `json:"issues"`
-	StartProcessTime string        `json:"start_processing_time"`
-	EndProcessTime   string        `json:"end_processing_time"`
+	Service          string              `json:"service"`
+	Principal        *metadata.Principal `json:"principal,omitempty"`
+	Action           string              `json:"action"`
+	Version          string              `json:"version"`
+	Status           string              `json:"status"`
+	Issues           []ReportIssue       `json:"issues"`
+	StartProcessTime string              `json:"start_processing_time"`
+	EndProcessTime   string              `json:"end_processing_time"`
 }
 
 type ReportIssue struct {
@@ -108,10 +127,48 @@ type UploadLifecycleContent struct {
 	Status string `json:"status"`
 }
 
+// UploadTerminatedContent keeps the manifest of a terminated upload, since it is deleted along with the upload.
+type UploadTerminatedContent struct {
+	ReportContent
+	Filename string            `json:"filename"`
+	Offset   int64             `json:"offset"`
+	Size     int64             `json:"size"`
+	Manifest map[string]string `json:"manifest"`
+}
+
+// DuplicateCheckContent names the upload a duplicate was first received as.
+type DuplicateCheckContent struct {
+	ReportContent
+	Algorithm        string `json:"algorithm"`
+	Hash             string `json:"hash"`
+	Policy           string `json:"policy"`
+	OriginalUploadID string `json:"original_upload_id,omitempty"`
+}
+
+// MalwareScanContent records the verdict of scanning an upload, which is quarantined instead of delivered when it
+// is infected or could not be scanned.
+type MalwareScanContent struct {
+	ReportContent
+	Scanner     string `json:"scanner"`
+	Verdict     string `json:"verdict"`
+	Signature   string `json:"signature,omitempty"`
+	Quarantined bool   `json:"quarantined"`
+}
+
 type MetaDataVerifyContent struct {
 	ReportContent
-	Filename string `json:"filename"`
-	Metadata any    `json:"metadata"`
+	Filename      string `json:"filename"`
+	Metadata      any    `json:"metadata"`
+	ConfigName    string `json:"config_name,omitempty"`
+	ConfigVersion string `json:"config_version,omitempty"`
+}
+
+type SenderAuthorizationContent struct {
+	ReportContent
+	ConfigName string `json:"config_name"`
+	Subject    string `json:"subject"`
+	ClientID   string `json:"client_id,omitempty"`
+	Issuer     string `json:"issuer"`
 }
 
 type MetadataTransformContent struct {
@@ -252,6 +309,7 @@ func (b *ReportBuilder[T]) Build() *Report {
 				Issues:           b.Issues,
 				Action:           b.Action,
 				Service:          "UPLOAD API",
+				Principal:        metadata.GetPrincipal(b.Manifest),
 				Version:          fmt.Sprintf("%s_%s", version.LatestReleaseVersion, version.GitShortSha),
 				Status:           b.Status,
 				StartProcessTime: b.StartTime.Format(time.RFC3339Nano),
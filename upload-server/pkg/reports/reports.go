@@ -2,6 +2,7 @@ package reports
 
 import (
 	"context"
+	"sync"
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
 )
@@ -13,9 +14,57 @@ func Register(r event.Publisher[*Report]) {
 }
 
 func Publish(ctx context.Context, r *Report) {
+	if h, ok := held(ctx); ok {
+		h.add(r)
+		return
+	}
 	Reporters.Publish(ctx, r)
 }
 
 func CloseAll() {
 	Reporters.Close()
 }
+
+type heldKey struct{}
+
+func held(ctx context.Context) (*Held, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	h, ok := ctx.Value(heldKey{}).(*Held)
+	return h, ok
+}
+
+// Held keeps the reports published with its context until they are released, so that a request that is rejected
+// part way through does not leave reports of the stages that passed before it.
+type Held struct {
+	mux     sync.Mutex
+	reports []*Report
+}
+
+// Hold returns a context whose reports are kept by the returned Held instead of being published.
+func Hold(ctx context.Context) (context.Context, *Held) {
+	h := &Held{}
+	return context.WithValue(ctx, heldKey{}, h), h
+}
+
+func (h *Held) add(r *Report) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.reports = append(h.reports, r)
+}
+
+// Release publishes the held reports.  When the request was rejected only the failed reports, which say why, are
+// published.
+func (h *Held) Release(ctx context.Context, rejected bool) {
+	h.mux.Lock()
+	held := h.reports
+	h.reports = nil
+	h.mux.Unlock()
+	for _, r := range held {
+		if rejected && r.StageInfo.Status != StatusFailed {
+			continue
+		}
+		Reporters.Publish(ctx, r)
+	}
+}
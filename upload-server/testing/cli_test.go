@@ -4,15 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
@@ -23,10 +26,16 @@ import (
 
 	"github.com/cdcgov/data-exchange-upload/upload-server/cmd/cli"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/appconfig"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/delivery"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/event"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/metadata/validation"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/oauth"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/postprocessing"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/scan"
 	"github.com/cdcgov/data-exchange-upload/upload-server/internal/ui"
+	"github.com/cdcgov/data-exchange-upload/upload-server/internal/uploadindex"
+	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/info"
 	"github.com/cdcgov/data-exchange-upload/upload-server/pkg/reports"
 )
 
@@ -174,6 +183,562 @@ func TestTus(t *testing.T) {
 	}
 }
 
+func TestTusConcatenation(t *testing.T) {
+	tusRequest := func(method string, url string, body io.Reader, headers map[string]string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	var partials []string
+	for _, chunk := range []string{"hello ", "world"} {
+		resp := tusRequest(http.MethodPost, ts.URL+"/files/", nil, map[string]string{
+			"Upload-Concat": "partial",
+			"Upload-Length": fmt.Sprint(len(chunk)),
+		})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected partial upload to be created, got %s", resp.Status)
+		}
+		location := resp.Header.Get("Location")
+		resp = tusRequest(http.MethodPatch, location, strings.NewReader(chunk), map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": "0",
+		})
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected partial upload to be written, got %s", resp.Status)
+		}
+		u, err := url.Parse(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, u.Path)
+	}
+
+	manifest := maps.Clone(Cases["good"].metadata)
+	manifest["filename"] = "test.txt"
+	var meta []string
+	for k, v := range manifest {
+		meta = append(meta, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	resp := tusRequest(http.MethodPost, ts.URL+"/files/", nil, map[string]string{
+		"Upload-Concat":   "final;" + strings.Join(partials, " "),
+		"Upload-Metadata": strings.Join(meta, ","),
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected final upload to be created, got %s", resp.Status)
+	}
+	tuid := filepath.Base(resp.Header.Get("Location"))
+	time.Sleep(2 * time.Second) // Hard delay to wait for all non-blocking hooks to finish.
+
+	for _, p := range partials {
+		id := filepath.Base(p)
+		for _, stage := range trackedStages {
+			if _, err := os.Stat(TestReportsFolder + "/" + id + event.TypeSeparator + stage); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected no %s report for partial upload %s", stage, id)
+			}
+		}
+		if _, err := readEventFile(id, event.FileReadyEventType); err == nil {
+			t.Errorf("expected partial upload %s not to be delivered", id)
+		}
+		if _, err := os.Stat(TestFolderUploadsTus + "/" + id + ".info"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected partial upload %s to be deleted once it was concatenated", id)
+		}
+	}
+
+	b, err := os.ReadFile(TestFolderUploadsTus + "/" + tuid + ".meta")
+	if err != nil {
+		t.Fatal("expected final upload to have its manifest appended", err)
+	}
+	var processedMeta map[string]string
+	if err := json.Unmarshal(b, &processedMeta); err != nil {
+		t.Fatal(err)
+	}
+	if processedMeta["upload_id"] != tuid || processedMeta["data_stream_id"] != "dextesting" {
+		t.Errorf("expected final upload manifest to be transformed, got %+v", processedMeta)
+	}
+
+	summary, err := readReportFiles(tuid, trackedStages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stage := range []string{reports.StageMetadataVerify, reports.StageUploadStarted, reports.StageUploadCompleted} {
+		if err := checkReportSummary(summary, stage, 1); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := checkReportSummary(summary, reports.StageUploadStatus, 1); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := summary.Summaries[reports.StageUploadStatus].Reports[0].Content.(map[string]any); c["size"] != float64(11) || c["offset"] != float64(11) {
+		t.Errorf("expected final upload to be reported with the combined size, got %+v", c)
+	}
+
+	events, err := readEventFile(tuid, event.FileReadyEventType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("expected final upload to be routed once, got %d events", len(events))
+	}
+	delivered, err := os.ReadFile(TestEDAVFolder + "/" + tuid + ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(delivered) != "hello world" {
+		t.Errorf("expected concatenated file to be delivered, got %q", delivered)
+	}
+}
+
+func TestStatusEvents(t *testing.T) {
+	manifest := maps.Clone(Cases["good"].metadata)
+	manifest["filename"] = "test.txt"
+	var meta []string
+	for k, v := range manifest {
+		meta = append(meta, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/files/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Length", "11")
+	req.Header.Set("Upload-Metadata", strings.Join(meta, ","))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected upload to be created, got %s", resp.Status)
+	}
+	location := resp.Header.Get("Location")
+	tuid := filepath.Base(location)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/uploads/"+tuid+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	if events.StatusCode != http.StatusOK || events.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s %s", events.Status, events.Header.Get("Content-Type"))
+	}
+	stages := make(chan string)
+	go func() {
+		defer close(stages)
+		scanner := bufio.NewScanner(events.Body)
+		for scanner.Scan() {
+			if stage, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				stages <- stage
+			}
+		}
+	}()
+
+	req, err = http.NewRequest(http.MethodPatch, location, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected upload to be written, got %s", resp.Status)
+	}
+
+	want := map[string]bool{
+		reports.StageUploadStatus:    false,
+		reports.StageUploadCompleted: false,
+		reports.StageFileCopy:        false,
+	}
+	for stage := range stages {
+		want[stage] = true
+		if !slices.Contains(slices.Collect(maps.Values(want)), false) {
+			break
+		}
+	}
+	for stage, seen := range want {
+		if !seen {
+			t.Errorf("expected a %s status update", stage)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/uploads/missing/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing upload but got %d", resp.StatusCode)
+	}
+}
+
+func TestStatusEventsCORS(t *testing.T) {
+	tuid, err := RunTusTestCase(ts.URL, "test.txt", Cases["good"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for origin, allowed := range map[string]bool{
+		"http://localhost:8081":    true,
+		"https://evil.example.com": false,
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/uploads/"+tuid+"/events", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resp.Header.Get("Access-Control-Allow-Origin")
+		credentials := resp.Header.Get("Access-Control-Allow-Credentials")
+		cancel()
+		resp.Body.Close()
+		if allowed && (got != origin || credentials != "true") {
+			t.Errorf("expected %s to be allowed with credentials but got %q %q", origin, got, credentials)
+		}
+		if !allowed && (got != "" || credentials != "") {
+			t.Errorf("expected %s to not be allowed but got %q %q", origin, got, credentials)
+		}
+	}
+}
+
+func TestDownloadEndpoint(t *testing.T) {
+	want, err := os.ReadFile("test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuid, err := RunTusTestCase(ts.URL, "test.txt", Cases["good"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second) // Hard delay to wait for all non-blocking hooks to finish.
+
+	resp, err := http.Get(ts.URL + "/uploads/" + tuid + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(b, want) {
+		t.Fatalf("expected the uploaded file, got %s %q", resp.Status, b)
+	}
+	if d := resp.Header.Get("Content-Disposition"); !strings.Contains(d, "attachment") {
+		t.Errorf("expected the upload as an attachment, got %q", d)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/uploads/"+tuid+"/file", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Range", "bytes=2-5")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(b, want[2:6]) {
+		t.Errorf("expected bytes 2-5 of the upload, got %s %q", resp.Status, b)
+	}
+
+	resp, err = http.Get(ts.URL + "/uploads/" + tuid + "/file/url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected local uploads to not be presigned but got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/uploads/missing/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing upload but got %d", resp.StatusCode)
+	}
+}
+
+// infectedScanner finds malware in every upload.
+type infectedScanner struct{}
+
+func (infectedScanner) Name() string { return "infected" }
+
+func (infectedScanner) Scan(_ context.Context, r io.Reader) (*scan.Result, error) {
+	return &scan.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+}
+
+// presigningSource hands out urls for the uploads of the source it wraps.
+type presigningSource struct {
+	delivery.Source
+}
+
+func (s presigningSource) PresignedURL(_ context.Context, id string, _ string, _ time.Duration) (string, error) {
+	return "https://storage.example.com/" + id, nil
+}
+
+func TestDownloadQuarantined(t *testing.T) {
+	tuid, err := RunTusTestCase(ts.URL, "test.txt", Cases["good"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second) // Hard delay to wait for all non-blocking hooks to finish.
+
+	uploads, ok := delivery.GetSource(delivery.UploadSrc)
+	if !ok {
+		t.Fatal("expected the upload source to be registered")
+	}
+	src := presigningSource{uploads}
+	mux := http.NewServeMux()
+	downloadHandler := &cli.DownloadHandler{Source: src, Scanner: &scan.Stage{Scanner: infectedScanner{}, Source: src}}
+	downloadHandler.Register(mux, func(h http.Handler) http.Handler { return h })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, path := range []string{"/file", "/file/url"} {
+		resp, err := http.Get(server.URL + "/uploads/" + tuid + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected 409 for %s of an infected upload but got %s %q", path, resp.Status, b)
+		}
+	}
+}
+
+func TestTerminateUpload(t *testing.T) {
+	tuid, err := RunTusTestCase(ts.URL, "test.txt", Cases["good"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second) // Hard delay to wait for all non-blocking hooks to finish.
+	metaFile := filepath.Join(TestFolderUploadsTus, tuid+".meta")
+	if _, err := os.Stat(metaFile); err != nil {
+		t.Fatal("expected the upload's metadata to be appended", err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/files/"+tuid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected upload to be terminated, got %s", resp.Status)
+	}
+	time.Sleep(1 * time.Second) // Hard delay to wait for the post-terminate hooks to finish.
+
+	if _, err := os.Stat(metaFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the upload's metadata to be removed, got %v", err)
+	}
+
+	resp, err = http.Get(ts.URL + "/info/" + tuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infoResp info.InfoResponse
+	err = json.NewDecoder(resp.Body).Decode(&infoResp)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || infoResp.UploadStatus.Status != info.UploadTerminated {
+		t.Errorf("expected a terminated upload, got %d %+v", resp.StatusCode, infoResp.UploadStatus)
+	}
+	if infoResp.Manifest["data_stream_id"] != Cases["good"].metadata["data_stream_id"] {
+		t.Errorf("expected the manifest of the terminated upload, got %+v", infoResp.Manifest)
+	}
+
+	resp, err = http.Get(ts.URL + "/uploads?status=" + uploadindex.StatusTerminated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page uploadindex.Page
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(page.Uploads, func(e uploadindex.Entry) bool { return e.ID == tuid }) {
+		t.Errorf("expected the upload to be listed as terminated, got %+v", page.Uploads)
+	}
+
+	resp, err = http.Get(ts.URL + "/uploads/" + tuid + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a terminated upload to not be downloaded but got %d", resp.StatusCode)
+	}
+}
+
+func TestDuplicateUploads(t *testing.T) {
+	path, err := metadata.NewFromManifest(map[string]string(Cases["good"].metadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.ToLower(path.Path())
+	config, err := metadata.Cache.GetConfig(testContext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warned := *config
+	warned.Duplicates = validation.DuplicateConfig{Policy: validation.DuplicatePolicyWarn, WindowDays: 7}
+	metadata.Cache.SetConfig(key, &warned)
+	t.Cleanup(func() { metadata.Cache.SetConfig(key, config) })
+
+	if _, err := RunTusTestCase(ts.URL, "test.txt", Cases["good"]); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1 * time.Second) // Hard delay to wait for the first upload to be checked.
+	tuid, err := RunTusTestCase(ts.URL, "test.txt", Cases["good"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second) // Hard delay to wait for all non-blocking hooks to finish.
+
+	reportSummary, err := readReportFiles(tuid, []string{reports.StageDuplicateCheck})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReportSummary(reportSummary, reports.StageDuplicateCheck, 1); err != nil {
+		t.Fatal(err)
+	}
+	r := reportSummary.Summaries[reports.StageDuplicateCheck].Reports[0]
+	if len(r.StageInfo.Issues) != 1 || r.StageInfo.Issues[0].Level != reports.IssueLevelWarning || !strings.HasPrefix(r.StageInfo.Issues[0].Message, "duplicate of upload ") {
+		t.Errorf("expected a duplicate warning, got %+v", r.StageInfo)
+	}
+	if r.StageInfo.Issues[0].Message == "duplicate of upload "+tuid {
+		t.Errorf("expected the upload to not be a duplicate of itself, got %+v", r.StageInfo)
+	}
+	if _, err := os.Stat(TestEDAVFolder + "/" + tuid + ".txt"); err != nil {
+		t.Error("expected a duplicate to be delivered when the policy warns", err)
+	}
+}
+
+func TestUploadMaxSize(t *testing.T) {
+	manifest := maps.Clone(Cases["good"].metadata)
+	manifest["filename"] = "test.txt"
+	path, err := metadata.NewFromManifest(map[string]string(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.ToLower(path.Path())
+	config, err := metadata.Cache.GetConfig(testContext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited := *config
+	limited.MaxSizeBytes = 1024
+	metadata.Cache.SetConfig(key, &limited)
+	t.Cleanup(func() { metadata.Cache.SetConfig(key, config) })
+
+	var meta []string
+	for k, v := range manifest {
+		meta = append(meta, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	tusRequest := func(method string, url string, body io.Reader, headers map[string]string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := tusRequest(http.MethodPost, ts.URL+"/files/", nil, map[string]string{
+		"Upload-Length":   "2048",
+		"Upload-Metadata": strings.Join(meta, ","),
+	})
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected an upload over the max size to be rejected, got %s", resp.Status)
+	}
+	if resp.Header.Get(metadata.MaxSizeHeader) != "1024" {
+		t.Errorf("expected the max size to be advertised, got %q", resp.Header.Get(metadata.MaxSizeHeader))
+	}
+
+	resp = tusRequest(http.MethodPost, ts.URL+"/files/", nil, map[string]string{
+		"Upload-Defer-Length": "1",
+		"Upload-Metadata":     strings.Join(meta, ","),
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected a deferred length upload to be created, got %s", resp.Status)
+	}
+	if resp.Header.Get(metadata.MaxSizeHeader) != "1024" {
+		t.Errorf("expected the max size to be advertised, got %q", resp.Header.Get(metadata.MaxSizeHeader))
+	}
+	location := resp.Header.Get("Location")
+
+	// the upload is only checked while data is received, so the body is held open past the progress interval
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(bytes.Repeat([]byte("a"), 4096))
+		time.Sleep(2 * time.Second)
+		pw.Close()
+	}()
+	resp = tusRequest(http.MethodPatch, location, pr, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	})
+	pr.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected an upload growing over the max size to be stopped, got %s", resp.Status)
+	}
+	time.Sleep(1 * time.Second) // Hard delay to wait for the post-terminate hooks to finish.
+
+	resp = tusRequest(http.MethodHead, location, nil, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the stopped upload to be terminated, got %s", resp.Status)
+	}
+}
+
 func TestRouteEndpoint(t *testing.T) {
 	goodCase := "good"
 	c, ok := Cases[goodCase]
@@ -222,6 +787,8 @@ func TestWellKnownEndpoints(t *testing.T) {
 	endpoints := []string{
 		"/",
 		"/health",
+		"/health/live",
+		"/health/ready",
 		"/version",
 	}
 	client := ts.Client()
@@ -323,6 +890,143 @@ func TestRouteFileNotFound(t *testing.T) {
 	}
 }
 
+func TestForgedPrincipalIsDropped(t *testing.T) {
+	c := Cases["good"]
+	forged := testCase{metadata: maps.Clone(c.metadata), deliveries: c.deliveries}
+	forged.metadata["dex_principal_subject"] = "someone-else"
+	forged.metadata["dex_principal_method"] = "jwt"
+
+	tuid, err := RunTusTestCase(ts.URL, "test.txt", forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // Wait for the pre-finish hook to write the meta file.
+
+	b, err := os.ReadFile(TestFolderUploadsTus + "/" + tuid + ".meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var processedMeta map[string]string
+	if err := json.Unmarshal(b, &processedMeta); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"dex_principal_subject", "dex_principal_method"} {
+		if v, ok := processedMeta[key]; ok {
+			t.Errorf("expected sender supplied %s to be dropped but got %s", key, v)
+		}
+	}
+}
+
+func TestDataStreamsEndpoint(t *testing.T) {
+	client := ts.Client()
+	resp, err := client.Get(ts.URL + "/data-streams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 but got", resp.StatusCode)
+	}
+
+	var body cli.DataStreamsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	found := slices.ContainsFunc(body.DataStreams, func(ds metadata.DataStreamConfig) bool {
+		return ds.DataStreamID == "dextesting" && ds.DataStreamRoute == "testevent1" && len(ds.Fields) > 0
+	})
+	if !found {
+		t.Errorf("expected dextesting testevent1 in data streams but got %+v", body.DataStreams)
+	}
+
+	// senders only see the data streams they are authorized to upload to
+	ctx := oauth.NewContext(testContext, oauth.Claims{Subject: "sender", Raw: map[string]any{oauth.DataStreamsClaim: []any{"dextesting/testevent1"}}})
+	streams, err := metadata.Cache.ListAuthorizedDataStreams(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 || streams[0].DataStreamID != "dextesting" || streams[0].DataStreamRoute != "testevent1" {
+		t.Errorf("expected only dextesting testevent1 to be listed for the sender but got %+v", streams)
+	}
+}
+
+func TestConfigsEndpoint(t *testing.T) {
+	client := ts.Client()
+	resp, err := client.Get(ts.URL + "/admin/configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 but got", resp.StatusCode)
+	}
+	var body cli.ListConfigsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(body.Configs, "dextesting_testevent1.json") {
+		t.Errorf("expected dextesting_testevent1.json in configs but got %v", body.Configs)
+	}
+
+	resp, err = client.Get(ts.URL + "/admin/configs/dextesting_testevent1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 but got", resp.StatusCode)
+	}
+
+	resp, err = client.Post(ts.URL+"/admin/configs/dextesting_testevent1.json/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 but got", resp.StatusCode)
+	}
+
+	resp, err = client.Post(ts.URL+"/admin/configs/missing_config.json/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("expected 404 but got", resp.StatusCode)
+	}
+}
+
+func TestUploadsEndpoint(t *testing.T) {
+	client := ts.Client()
+	resp, err := client.Get(ts.URL + "/uploads?data_stream_id=dextesting&limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 but got", resp.StatusCode)
+	}
+	var page uploadindex.Page
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Uploads) != 1 || page.Uploads[0].DataStreamID != "dextesting" {
+		t.Fatalf("expected one dextesting upload but got %+v", page)
+	}
+
+	resp, err = client.Get(ts.URL + "/uploads?status=" + url.QueryEscape(page.Uploads[0].Status))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 but got", resp.StatusCode)
+	}
+
+	for _, query := range []string{"status=done", "cursor=bad", "from=yesterday", "limit=0"} {
+		resp, err := client.Get(ts.URL + "/uploads?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s but got %d", query, resp.StatusCode)
+		}
+	}
+}
+
 func TestMetricsEndpointSuccess(t *testing.T) {
 	client := ts.Client()
 	resp, err := client.Get(ts.URL + "/metrics")
@@ -565,6 +1269,8 @@ func TestMain(m *testing.M) {
 		DeliveryConfigFile:    "./delivery.yml",
 		TusdHandlerBasePath:   "/files/",
 		OauthConfig:           &oauthConfig,
+
+		StatusEventsAllowedOrigins: []string{"http://localhost:8081"},
 	}
 	appconfig.LoadedConfig = &appConfig
 
@@ -577,6 +1283,8 @@ func TestMain(m *testing.M) {
 	testWaitGroup.Add(1)
 	err = cli.InitReporters(testContext, appConfig)
 	defer reports.CloseAll()
+	err = cli.InitUploadIndex(testContext, appConfig)
+	_, err = cli.InitStatusBus(testContext, appConfig)
 	err = cli.InitFileReadyPublisher(testContext, appConfig)
 	defer event.FileReadyPublisher.Close()
 	testListener, err := cli.NewEventSubscriber[*event.FileReady](testContext, appConfig)
@@ -599,7 +1307,7 @@ func TestMain(m *testing.M) {
 	ts = httptest.NewServer(serveHandler)
 
 	// Start ui server
-	uiHandler := ui.GetRouter(ts.URL+appConfig.TusdHandlerBasePath, ts.URL+appConfig.TusdHandlerInfoPath, ts.URL+appConfig.TusdHandlerBasePath, authMiddleware)
+	uiHandler := ui.GetRouter(ts.URL, ts.URL+appConfig.TusdHandlerBasePath, ts.URL+appConfig.TusdHandlerInfoPath, ts.URL+appConfig.TusdHandlerBasePath, authMiddleware)
 	testUIServer = httptest.NewServer(uiHandler)
 
 	testRes := m.Run()